@@ -0,0 +1,57 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sykell/url-crawler/internal/crawler"
+)
+
+// WorkersRequest represents a request to resize the crawler worker pool
+type WorkersRequest struct {
+	Workers int `json:"workers" binding:"required,min=1"`
+}
+
+// CrawlerStatsHandler handles inspecting workers, in-flight crawls, queue and throughput
+func CrawlerStatsHandler(crawlerService *crawler.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, crawlerService.Stats())
+	}
+}
+
+// PauseCrawlerHandler handles pausing (paused=true) or resuming the whole crawler
+func PauseCrawlerHandler(crawlerService *crawler.Service, paused bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if paused {
+			crawlerService.Pause()
+		} else {
+			crawlerService.Resume()
+		}
+
+		c.JSON(http.StatusOK, crawlerService.Stats())
+	}
+}
+
+// SetWorkersHandler handles changing the number of crawler workers at runtime
+func SetWorkersHandler(crawlerService *crawler.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req WorkersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid workers request",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if err := crawlerService.SetWorkers(req.Workers); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to resize worker pool", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, crawlerService.Stats())
+	}
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/middleware"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+// AlertRuleRequest represents an email alert rule. Without URLID the rule
+// applies to all of the user's URLs.
+type AlertRuleRequest struct {
+	URLID         *uint  `json:"url_id"`
+	Email         string `json:"email" binding:"required,email,max=255"`
+	OnError       bool   `json:"on_error"`
+	OnBrokenLinks bool   `json:"on_broken_links"`
+	Digest        bool   `json:"digest"`
+}
+
+// CreateAlertRuleHandler handles creating an email alert rule
+func CreateAlertRuleHandler(alerts service.AlertRepository, urls service.URLRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user from context
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		userCtx, ok := user.(middleware.UserContext)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+			return
+		}
+
+		req, ok := bindAlertRuleRequest(c, urls, userCtx.UserID)
+		if !ok {
+			return
+		}
+
+		rule := db.AlertRule{
+			UserID:        userCtx.UserID,
+			URLID:         req.URLID,
+			Email:         req.Email,
+			OnError:       req.OnError,
+			OnBrokenLinks: req.OnBrokenLinks,
+			Digest:        req.Digest,
+		}
+		if err := alerts.CreateRule(requestContext(c), &rule); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to create alert rule", "user_id", userCtx.UserID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert rule"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, rule)
+	}
+}
+
+// ListAlertRulesHandler handles listing the user's alert rules
+func ListAlertRulesHandler(alerts service.AlertRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user from context
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		userCtx, ok := user.(middleware.UserContext)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+			return
+		}
+
+		rules, err := alerts.ListRules(requestContext(c), userCtx.UserID)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to list alert rules", "user_id", userCtx.UserID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": rules})
+	}
+}
+
+// UpdateAlertRuleHandler handles replacing an alert rule
+func UpdateAlertRuleHandler(alerts service.AlertRepository, urls service.URLRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule, ok := getOwnedAlertRule(c, alerts)
+		if !ok {
+			return
+		}
+
+		req, ok := bindAlertRuleRequest(c, urls, rule.UserID)
+		if !ok {
+			return
+		}
+
+		rule.URLID = req.URLID
+		rule.Email = req.Email
+		rule.OnError = req.OnError
+		rule.OnBrokenLinks = req.OnBrokenLinks
+		rule.Digest = req.Digest
+		if err := alerts.UpdateRule(requestContext(c), rule); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to update alert rule", "rule_id", rule.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert rule"})
+			return
+		}
+
+		updated, err := alerts.GetRule(requestContext(c), rule.ID)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to reload alert rule", "rule_id", rule.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// DeleteAlertRuleHandler handles removing an alert rule
+func DeleteAlertRuleHandler(alerts service.AlertRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule, ok := getOwnedAlertRule(c, alerts)
+		if !ok {
+			return
+		}
+
+		if err := alerts.DeleteRule(requestContext(c), rule.ID); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to delete alert rule", "rule_id", rule.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Alert rule deleted"})
+	}
+}
+
+// bindAlertRuleRequest parses and validates an alert rule request, including
+// ownership of the referenced URL
+func bindAlertRuleRequest(c *gin.Context, urls service.URLRepository, userID uint) (*AlertRuleRequest, bool) {
+	var req AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid alert rule request",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+
+	if !req.OnError && !req.OnBrokenLinks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one of on_error and on_broken_links must be enabled"})
+		return nil, false
+	}
+
+	if req.URLID != nil {
+		if _, err := urls.GetForUser(requestContext(c), *req.URLID, userID); err != nil {
+			if err == service.ErrNotFound {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "URL not found"})
+				return nil, false
+			}
+			slog.ErrorContext(c.Request.Context(), "Failed to fetch URL", "url_id", *req.URLID, "user_id", userID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return nil, false
+		}
+	}
+
+	return &req, true
+}
+
+// getOwnedAlertRule resolves the :id parameter to an alert rule owned by the
+// authenticated user, writing the error response when it can't
+func getOwnedAlertRule(c *gin.Context, alerts service.AlertRepository) (*db.AlertRule, bool) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	userCtx, ok := user.(middleware.UserContext)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert rule ID"})
+		return nil, false
+	}
+
+	rule, err := alerts.GetRuleForUser(requestContext(c), uint(id), userCtx.UserID)
+	if err != nil {
+		if err == service.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+			return nil, false
+		}
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch alert rule", "rule_id", id, "user_id", userCtx.UserID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return nil, false
+	}
+
+	return rule, true
+}
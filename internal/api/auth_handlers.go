@@ -1,17 +1,18 @@
 package api
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 
+	"github.com/sykell/url-crawler/internal/metrics"
 	"github.com/sykell/url-crawler/internal/service"
 )
 
@@ -33,6 +34,7 @@ type LoginResponse struct {
 	ExpiresAt time.Time `json:"expires_at"`
 	UserID    uint      `json:"user_id"`
 	Username  string    `json:"username"`
+	IsAdmin   bool      `json:"is_admin"`
 }
 
 // SignupResponse represents the signup response payload
@@ -65,13 +67,14 @@ func NewAuthConfig() *Config {
 }
 
 // LoginHandler handles user authentication
-func LoginHandler(dbConn *gorm.DB) gin.HandlerFunc {
+func LoginHandler(users service.UserRepository) gin.HandlerFunc {
 	config := NewAuthConfig()
 	
 	return func(c *gin.Context) {
+		ctx := requestContext(c)
 		var req LoginRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			log.Printf("Login validation error: %v", err)
+			slog.InfoContext(c.Request.Context(), "Login validation error", "error", err)
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Invalid request format",
 				"details": err.Error(),
@@ -87,47 +90,59 @@ func LoginHandler(dbConn *gorm.DB) gin.HandlerFunc {
 		}
 
 		// Get user from database
-		user, err := service.GetUserByUsername(dbConn, req.Username)
+		user, err := users.GetByUsername(ctx, req.Username)
 		if err != nil {
-			if err == gorm.ErrRecordNotFound {
-				log.Printf("Login attempt with non-existent username: %s", req.Username)
+			if err == service.ErrNotFound {
+				slog.WarnContext(c.Request.Context(), "Login attempt with non-existent username", "username", req.Username)
+				metrics.AuthAttempt("login", false)
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 				return
 			}
-			log.Printf("Database error during login: %v", err)
+			slog.ErrorContext(c.Request.Context(), "Database error during login", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 			return
 		}
 
 		// Verify password
 		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-			log.Printf("Failed login attempt for user: %s", req.Username)
+			slog.WarnContext(c.Request.Context(), "Failed login attempt", "username", req.Username)
+			metrics.AuthAttempt("login", false)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 			return
 		}
+		if user.DisabledAt != nil {
+			slog.WarnContext(c.Request.Context(), "Login attempt for disabled user", "username", req.Username)
+			metrics.AuthAttempt("login", false)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+			return
+		}
 
 		// Generate JWT token
 		expiresAt := time.Now().Add(config.TokenDuration)
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"user_id": user.ID,
+			"user_id":  user.ID,
 			"username": user.Username,
-			"exp":     expiresAt.Unix(),
+			"is_admin": user.IsAdmin,
+			"ver":      user.TokenVersion,
+			"exp":      expiresAt.Unix(),
 			"iat":     time.Now().Unix(),
 		})
 
 		tokenStr, err := token.SignedString([]byte(config.JWTSecret))
 		if err != nil {
-			log.Printf("Failed to sign JWT token: %v", err)
+			slog.ErrorContext(c.Request.Context(), "Failed to sign JWT token", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 			return
 		}
 
-		log.Printf("Successful login for user: %s", req.Username)
+		slog.InfoContext(c.Request.Context(), "Successful login", "username", req.Username)
+		metrics.AuthAttempt("login", true)
 		c.JSON(http.StatusOK, LoginResponse{
 			Token:     tokenStr,
 			ExpiresAt: expiresAt,
 			UserID:    user.ID,
 			Username:  user.Username,
+			IsAdmin:   user.IsAdmin,
 		})
 	}
 }
@@ -153,12 +168,14 @@ func ValidateToken(tokenString string, secret string) (jwt.MapClaims, error) {
 	return nil, jwt.ErrInvalidKey
 }
 
-// SignupHandler handles user registration
-func SignupHandler(dbConn *gorm.DB) gin.HandlerFunc {
+// SignupHandler handles user registration. Reserved usernames are refused so
+// nobody can claim an admin account before it is created with the CLI
+func SignupHandler(users service.UserRepository, reserved []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := requestContext(c)
 		var req SignupRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			log.Printf("Signup validation error: %v", err)
+			slog.InfoContext(c.Request.Context(), "Signup validation error", "error", err)
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Invalid request format",
 				"details": err.Error(),
@@ -172,43 +189,51 @@ func SignupHandler(dbConn *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Username cannot be empty"})
 			return
 		}
+		if slices.Contains(reserved, req.Username) {
+			slog.WarnContext(c.Request.Context(), "Signup attempt with reserved username", "username", req.Username)
+			metrics.AuthAttempt("signup", false)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Username is reserved"})
+			return
+		}
 
 		// Check if user already exists
-		existingUser, err := service.GetUserByUsername(dbConn, req.Username)
+		existingUser, err := users.GetByUsername(ctx, req.Username)
 		if err == nil && existingUser != nil {
-			log.Printf("Signup attempt with existing username: %s", req.Username)
+			slog.InfoContext(c.Request.Context(), "Signup attempt with existing username", "username", req.Username)
+			metrics.AuthAttempt("signup", false)
 			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
 			return
-		} else if err != gorm.ErrRecordNotFound {
-			log.Printf("Database error during signup user check: %v", err)
+		} else if err != service.ErrNotFound {
+			slog.ErrorContext(c.Request.Context(), "Database error during signup user check", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 			return
 		}
 
 		// Hash password
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		hashedPassword, err := service.HashPassword(req.Password)
 		if err != nil {
-			log.Printf("Failed to hash password during signup: %v", err)
+			slog.ErrorContext(c.Request.Context(), "Failed to hash password during signup", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
 			return
 		}
 
 		// Create new user
-		if err := service.CreateUser(dbConn, req.Username, string(hashedPassword)); err != nil {
-			log.Printf("Failed to create user: %v", err)
+		if err := users.Create(ctx, req.Username, hashedPassword); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to create user", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 			return
 		}
 
 		// Get the created user to return the ID
-		newUser, err := service.GetUserByUsername(dbConn, req.Username)
+		newUser, err := users.GetByUsername(ctx, req.Username)
 		if err != nil {
-			log.Printf("Failed to fetch created user: %v", err)
+			slog.ErrorContext(c.Request.Context(), "Failed to fetch created user", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "User created but failed to fetch details"})
 			return
 		}
 
-		log.Printf("Successfully created user: %s (ID: %d)", newUser.Username, newUser.ID)
+		slog.InfoContext(c.Request.Context(), "Successfully created user", "username", newUser.Username, "user_id", newUser.ID)
+		metrics.AuthAttempt("signup", true)
 		c.JSON(http.StatusCreated, SignupResponse{
 			UserID:   newUser.ID,
 			Username: newUser.Username,
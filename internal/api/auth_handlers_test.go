@@ -0,0 +1,36 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+func TestSignupRefusesReservedUsernames(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	users := service.NewMemoryUserRepository()
+	r := gin.New()
+	r.POST("/auth/signup", SignupHandler(users, []string{"admin"}))
+
+	tests := []struct {
+		username string
+		want     int
+	}{
+		{"admin", http.StatusForbidden},
+		{" admin ", http.StatusForbidden},
+		{"alice", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		body := SignupRequest{Username: tt.username, Password: "password123"}
+		if code := serve(t, r, http.MethodPost, "/auth/signup", body, nil); code != tt.want {
+			t.Errorf("signup as %q = %d, want %d", tt.username, code, tt.want)
+		}
+	}
+	if _, err := users.GetByUsername(context.Background(), "admin"); err != service.ErrNotFound {
+		t.Errorf("reserved user was created: %v", err)
+	}
+}
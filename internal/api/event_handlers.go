@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sykell/url-crawler/internal/middleware"
+	"github.com/sykell/url-crawler/internal/realtime"
+)
+
+// sseHeartbeatInterval keeps idle connections open through proxies
+const sseHeartbeatInterval = 15 * time.Second
+
+// EventsHandler streams the user's crawl status and progress events as
+// Server-Sent Events, replaying missed events after a Last-Event-ID
+func EventsHandler(hub *realtime.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user from context
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		userCtx, ok := user.(middleware.UserContext)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+			return
+		}
+
+		// EventSource sends Last-Event-ID on reconnect; allow a query fallback
+		lastEventIDStr := c.GetHeader("Last-Event-ID")
+		if lastEventIDStr == "" {
+			lastEventIDStr = c.Query("last_event_id")
+		}
+		var lastEventID uint64
+		if lastEventIDStr != "" {
+			parsed, err := strconv.ParseUint(lastEventIDStr, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+				return
+			}
+			lastEventID = parsed
+		}
+
+		// Streams outlive the server's write timeout
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			slog.WarnContext(c.Request.Context(), "Failed to clear write deadline for event stream", "error", err)
+		}
+
+		sub, backlog := hub.Subscribe(userCtx.UserID, lastEventID)
+		defer sub.Close()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+
+		fmt.Fprint(c.Writer, "retry: 3000\n\n")
+		for _, event := range backlog {
+			if err := writeSSEEvent(c, event); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(sseHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-sub.C:
+				if !ok {
+					return // Dropped for falling behind or shutting down; the client resumes
+				}
+				if err := writeSSEEvent(c, event); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// writeSSEEvent writes a single event in text/event-stream format
+func writeSSEEvent(c *gin.Context, event realtime.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to marshal event", "event_id", event.ID, "error", err)
+		return nil
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
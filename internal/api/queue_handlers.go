@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sykell/url-crawler/internal/crawler"
+	"github.com/sykell/url-crawler/internal/middleware"
+)
+
+// QueueResponse describes the crawl queue as seen by a user
+type QueueResponse struct {
+	Length     int                      `json:"length"`      // All queued URLs
+	ByPriority map[crawler.Priority]int `json:"by_priority"` // All queued URLs per priority
+	Items      []crawler.QueueItem      `json:"items"`       // The user's queued URLs
+}
+
+// QueueHandler handles inspecting the crawl queue. Totals cover all users;
+// only the authenticated user's own queued URLs are listed.
+func QueueHandler(crawlerService *crawler.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user from context
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		userCtx, ok := user.(middleware.UserContext)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+			return
+		}
+
+		c.JSON(http.StatusOK, QueueResponse{
+			Length:     crawlerService.QueueLength(),
+			ByPriority: crawlerService.QueueCounts(),
+			Items:      crawlerService.QueuedForUser(userCtx.UserID),
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/middleware"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+// ListRunsHandler handles listing the crawl history of a URL with pagination
+func ListRunsHandler(urls service.URLRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := requestContext(c)
+		// Get user from context
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		userCtx, ok := user.(middleware.UserContext)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+			return
+		}
+
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL ID"})
+			return
+		}
+
+		// Ensure the URL belongs to the user before exposing its history
+		if _, err := urls.GetForUser(ctx, uint(id), userCtx.UserID); err != nil {
+			if err == service.ErrNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+				return
+			}
+			slog.ErrorContext(c.Request.Context(), "Failed to fetch URL", "url_id", id, "user_id", userCtx.UserID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		// Parse pagination parameters
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		pageSize, err := strconv.Atoi(c.DefaultQuery("size", "10"))
+		if err != nil || pageSize < 1 || pageSize > 100 {
+			pageSize = 10
+		}
+
+		runs, total, err := urls.ListRuns(ctx, uint(id), page, pageSize)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to fetch crawl runs", "url_id", id, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(http.StatusOK, PaginatedResponse{
+			Data:  runs,
+			Page:  page,
+			Size:  pageSize,
+			Total: total,
+			Pages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+		})
+	}
+}
+
+// DiffRunsHandler handles comparing two crawl runs of a URL.
+// Without parameters the latest run is compared to the previous completed one.
+func DiffRunsHandler(urls service.URLRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := requestContext(c)
+		// Get user from context
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		userCtx, ok := user.(middleware.UserContext)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+			return
+		}
+
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL ID"})
+			return
+		}
+
+		url, err := urls.GetForUser(ctx, uint(id), userCtx.UserID)
+		if err != nil {
+			if err == service.ErrNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+				return
+			}
+			slog.ErrorContext(c.Request.Context(), "Failed to fetch URL", "url_id", id, "user_id", userCtx.UserID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		// Resolve the target run, defaulting to the latest one
+		var to *db.CrawlRun
+		if toStr := c.Query("to"); toStr != "" {
+			toID, err := strconv.ParseUint(toStr, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'to' run ID"})
+				return
+			}
+			to, err = urls.GetRun(ctx, url.ID, uint(toID))
+			if err != nil {
+				respondRunLookupError(c, err, uint(toID))
+				return
+			}
+		} else {
+			if url.LastRunID == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "URL has not been crawled yet"})
+				return
+			}
+			to, err = urls.GetRun(ctx, url.ID, *url.LastRunID)
+			if err != nil {
+				respondRunLookupError(c, err, *url.LastRunID)
+				return
+			}
+		}
+
+		// Resolve the base run, defaulting to the previous completed one
+		var from *db.CrawlRun
+		if fromStr := c.Query("from"); fromStr != "" {
+			fromID, err := strconv.ParseUint(fromStr, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'from' run ID"})
+				return
+			}
+			from, err = urls.GetRun(ctx, url.ID, uint(fromID))
+			if err != nil {
+				respondRunLookupError(c, err, uint(fromID))
+				return
+			}
+		} else {
+			from, err = urls.PreviousRun(ctx, url.ID, to.ID)
+			if err != nil {
+				if err == service.ErrNotFound {
+					c.JSON(http.StatusNotFound, gin.H{"error": "No earlier completed run to compare with"})
+					return
+				}
+				slog.ErrorContext(c.Request.Context(), "Failed to fetch previous run", "url_id", url.ID, "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+				return
+			}
+		}
+
+		if !from.HasResults() || !to.HasResults() {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only completed runs can be compared"})
+			return
+		}
+
+		includeText, _ := strconv.ParseBool(c.DefaultQuery("text", "false"))
+		diff, err := service.DiffCrawlRuns(from, to, includeText)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to diff runs", "from_run_id", from.ID, "to_run_id", to.ID, "url_id", url.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(http.StatusOK, diff)
+	}
+}
+
+// respondRunLookupError writes the response for a failed crawl run lookup
+func respondRunLookupError(c *gin.Context, err error, runID uint) {
+	if err == service.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl run not found", "run_id": runID})
+		return
+	}
+	slog.ErrorContext(c.Request.Context(), "Failed to fetch crawl run", "run_id", runID, "error", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+// ScheduleRequest represents a recurring crawl schedule. Exactly one of
+// Interval (Go duration, e.g. "1h") or Cron (5-field expression) must be set.
+type ScheduleRequest struct {
+	Interval string `json:"interval"`
+	Cron     string `json:"cron"`
+	Timezone string `json:"timezone"`
+}
+
+// validateScheduleRequest trims the schedule fields and validates them
+func validateScheduleRequest(req *ScheduleRequest) error {
+	req.Interval = strings.TrimSpace(req.Interval)
+	req.Cron = strings.TrimSpace(req.Cron)
+	req.Timezone = strings.TrimSpace(req.Timezone)
+	return service.ValidateSchedule(req.Interval, req.Cron, req.Timezone)
+}
+
+// SetScheduleHandler handles creating or replacing the schedule of a URL
+func SetScheduleHandler(urls service.URLRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		url, ok := getOwnedURL(c, urls)
+		if !ok {
+			return
+		}
+
+		var req ScheduleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid schedule request",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if err := validateScheduleRequest(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid schedule",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if err := service.SetURLSchedule(requestContext(c), urls, url, req.Interval, req.Cron, req.Timezone); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to set schedule", "url_id", url.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save schedule"})
+			return
+		}
+
+		slog.InfoContext(c.Request.Context(), "Scheduled URL", "url_id", url.ID, "interval", req.Interval, "cron", req.Cron, "timezone", req.Timezone, "next_run_at", url.NextRunAt)
+		c.JSON(http.StatusOK, url)
+	}
+}
+
+// DeleteScheduleHandler handles removing the schedule of a URL
+func DeleteScheduleHandler(urls service.URLRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		url, ok := getOwnedURL(c, urls)
+		if !ok {
+			return
+		}
+
+		if err := service.ClearURLSchedule(requestContext(c), urls, url); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to clear schedule", "url_id", url.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove schedule"})
+			return
+		}
+
+		c.JSON(http.StatusOK, url)
+	}
+}
+
+// PauseScheduleHandler handles pausing (paused=true) or resuming a URL's schedule
+func PauseScheduleHandler(urls service.URLRepository, paused bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		url, ok := getOwnedURL(c, urls)
+		if !ok {
+			return
+		}
+
+		if !url.HasSchedule() {
+			c.JSON(http.StatusConflict, gin.H{"error": "URL has no schedule"})
+			return
+		}
+
+		if err := service.SetSchedulePaused(requestContext(c), urls, url, paused); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to update schedule pause state", "url_id", url.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update schedule"})
+			return
+		}
+
+		c.JSON(http.StatusOK, url)
+	}
+}
@@ -1,14 +1,15 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 
 	"github.com/sykell/url-crawler/internal/crawler"
 	"github.com/sykell/url-crawler/internal/db"
@@ -23,20 +24,30 @@ type PostURLRequest struct {
 
 // URLResponse represents a URL response
 type URLResponse struct {
-	ID            uint      `json:"id"`
-	Address       string    `json:"address"`
-	Title         string    `json:"title"`
-	HTMLVersion   string    `json:"html_version"`
-	HeadingCounts string    `json:"heading_counts"`
-	InternalLinks int       `json:"internal_links"`
-	ExternalLinks int       `json:"external_links"`
-	BrokenLinks   int       `json:"broken_links"`
-	BrokenList    string    `json:"broken_list"`
-	HasLoginForm  bool      `json:"has_login_form"`
-	Status        string    `json:"status"`
-	Error         string    `json:"error"`
-	CreatedAt     string    `json:"created_at"`
-	UpdatedAt     string    `json:"updated_at"`
+	ID            uint   `json:"id"`
+	Address       string `json:"address"`
+	Title         string `json:"title"`
+	HTMLVersion   string `json:"html_version"`
+	HeadingCounts string `json:"heading_counts"`
+	InternalLinks int    `json:"internal_links"`
+	ExternalLinks int    `json:"external_links"`
+	BrokenLinks   int    `json:"broken_links"`
+	BrokenList    string `json:"broken_list"`
+	HasLoginForm  bool   `json:"has_login_form"`
+	Status        string `json:"status"`
+	Error         string `json:"error"`
+	LastRunID     *uint  `json:"last_run_id"`
+	// Recurring crawl schedule
+	ScheduleInterval string     `json:"schedule_interval"`
+	ScheduleCron     string     `json:"schedule_cron"`
+	ScheduleTimezone string     `json:"schedule_timezone"`
+	SchedulePaused   bool       `json:"schedule_paused"`
+	NextRunAt        *time.Time `json:"next_run_at"`
+	LastRunAt        *time.Time `json:"last_run_at"`
+	ContentChanged   bool       `json:"content_changed"`
+	ContentChangedAt *time.Time `json:"content_changed_at"`
+	CreatedAt        string     `json:"created_at"`
+	UpdatedAt        string     `json:"updated_at"`
 }
 
 // URLDetailResponse represents a detailed URL response
@@ -55,15 +66,31 @@ type PaginatedResponse struct {
 	Pages int         `json:"pages"`
 }
 
+// TrashedURL is a deleted URL with the time it will be purged
+type TrashedURL struct {
+	db.URL
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// UpdateURLRequest represents a partial URL update; omitted fields are kept.
+// URLs have no tags yet, so they can't be changed here.
+type UpdateURLRequest struct {
+	Address *string `json:"address" binding:"omitempty,url"`
+	// Schedule replaces the recurring crawl schedule and resumes it
+	Schedule       *ScheduleRequest `json:"schedule"`
+	SchedulePaused *bool            `json:"schedule_paused"`
+}
+
 // BulkRequest represents a bulk operation request
 type BulkRequest struct {
-	Action string `json:"action" binding:"required,oneof=rerun delete"`
+	Action string `json:"action" binding:"required,oneof=rerun delete restore"`
 	IDs    []uint `json:"ids" binding:"required,min=1,max=100"`
 }
 
 // PostURLHandler handles URL creation
-func PostURLHandler(dbConn *gorm.DB, crawlerService *crawler.Service) gin.HandlerFunc {
+func PostURLHandler(urls service.URLRepository, crawlerService *crawler.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := requestContext(c)
 		// Get user from context
 		user, exists := c.Get("user")
 		if !exists {
@@ -79,7 +106,7 @@ func PostURLHandler(dbConn *gorm.DB, crawlerService *crawler.Service) gin.Handle
 
 		var req PostURLRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			log.Printf("URL creation validation error: %v", err)
+			slog.InfoContext(c.Request.Context(), "URL creation validation error", "error", err)
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Invalid URL format",
 				"details": err.Error(),
@@ -95,37 +122,37 @@ func PostURLHandler(dbConn *gorm.DB, crawlerService *crawler.Service) gin.Handle
 		}
 
 		// Check if URL already exists for this user
-		existingURL, err := service.GetURLByAddress(dbConn, userCtx.UserID, req.Address)
+		existingURL, err := urls.GetByAddress(ctx, userCtx.UserID, req.Address)
 		if err == nil {
 			c.JSON(http.StatusConflict, gin.H{"error": "URL already exists", "id": existingURL.ID})
 			return
-		} else if err != gorm.ErrRecordNotFound {
-			log.Printf("Database error checking existing URL: %v", err)
+		} else if err != service.ErrNotFound {
+			slog.ErrorContext(c.Request.Context(), "Database error checking existing URL", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 			return
 		}
 
 		// Create new URL for this user
-		url, err := service.CreateURL(dbConn, userCtx.UserID, req.Address)
+		url, err := urls.Create(ctx, userCtx.UserID, req.Address)
 		if err != nil {
-			log.Printf("Failed to create URL: %v", err)
+			slog.ErrorContext(c.Request.Context(), "Failed to create URL", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save URL"})
 			return
 		}
 
 		// Notify crawler service
-		if err := crawlerService.NotifyNewURL(url.ID); err != nil {
-			log.Printf("Failed to notify crawler service: %v", err)
+		if err := crawlerService.NotifyNewURL(c.Request.Context(), url.ID); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to notify crawler service", "url_id", url.ID, "error", err)
 			// Don't fail the request, just log the error
 		}
 
-		log.Printf("Created new URL: %s (ID: %d) for user %d", req.Address, url.ID, userCtx.UserID)
+		slog.InfoContext(c.Request.Context(), "Created new URL", "address", req.Address, "url_id", url.ID, "user_id", userCtx.UserID)
 		c.JSON(http.StatusCreated, url)
 	}
 }
 
 // ListURLsHandler handles URL listing with pagination and search
-func ListURLsHandler(dbConn *gorm.DB) gin.HandlerFunc {
+func ListURLsHandler(urls service.URLRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get user from context
 		user, exists := c.Get("user")
@@ -153,55 +180,32 @@ func ListURLsHandler(dbConn *gorm.DB) gin.HandlerFunc {
 
 		// Parse sort parameter
 		sort := c.DefaultQuery("sort", "created_at desc")
-		allowedSorts := map[string]bool{
-			"created_at desc": true,
-			"created_at asc":  true,
-			"updated_at desc": true,
-			"updated_at asc":  true,
-			"status asc":      true,
-			"status desc":     true,
-		}
-		if !allowedSorts[sort] {
+		if !service.URLSorts[sort] {
 			sort = "created_at desc"
 		}
 
-		// Parse search parameter
-		search := strings.TrimSpace(c.Query("q"))
-		status := strings.TrimSpace(c.Query("status"))
-
-		// Build query - filter by user ID
-		query := dbConn.Model(&db.URL{}).Where("user_id = ?", userCtx.UserID)
-		
-		if search != "" {
-			query = query.Where("address LIKE ? OR title LIKE ?", "%"+search+"%", "%"+search+"%")
-		}
-		
-		if status != "" {
-			query = query.Where("status = ?", status)
+		// Filter the user's URLs by search term and status
+		filter := service.URLFilter{
+			UserID: userCtx.UserID,
+			Search: strings.TrimSpace(c.Query("q")),
+			Status: db.URLStatus(strings.TrimSpace(c.Query("status"))),
+			Sort:   sort,
+			Page:   page,
+			Size:   pageSize,
 		}
 
-		// Get total count
-		var total int64
-		if err := query.Count(&total).Error; err != nil {
-			log.Printf("Failed to count URLs: %v", err)
+		list, total, err := urls.List(requestContext(c), filter)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to fetch URLs", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 			return
 		}
 
 		// Calculate pagination
-		offset := (page - 1) * pageSize
 		pages := int((total + int64(pageSize) - 1) / int64(pageSize))
 
-		// Get URLs
-		var urls []db.URL
-		if err := query.Order(sort).Limit(pageSize).Offset(offset).Find(&urls).Error; err != nil {
-			log.Printf("Failed to fetch URLs: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
-		}
-
 		response := PaginatedResponse{
-			Data:  urls,
+			Data:  list,
 			Page:  page,
 			Size:  pageSize,
 			Total: total,
@@ -212,8 +216,56 @@ func ListURLsHandler(dbConn *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// ListTrashHandler lists the user's deleted URLs, most recently deleted
+// first, with when each will be purged
+func ListTrashHandler(urls service.URLRepository, retention time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		userCtx, ok := user.(middleware.UserContext)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+			return
+		}
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		pageSize, err := strconv.Atoi(c.DefaultQuery("size", "10"))
+		if err != nil || pageSize < 1 || pageSize > 100 {
+			pageSize = 10
+		}
+
+		list, total, err := urls.ListTrash(requestContext(c), userCtx.UserID, page, pageSize)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to fetch deleted URLs", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		trashed := make([]TrashedURL, 0, len(list))
+		for _, url := range list {
+			trashed = append(trashed, TrashedURL{URL: url, PurgeAt: url.DeletedAt.Time.Add(retention)})
+		}
+
+		c.JSON(http.StatusOK, PaginatedResponse{
+			Data:  trashed,
+			Page:  page,
+			Size:  pageSize,
+			Total: total,
+			Pages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+		})
+	}
+}
+
 // GetURLHandler handles retrieving a single URL
-func GetURLHandler(dbConn *gorm.DB) gin.HandlerFunc {
+func GetURLHandler(urls service.URLRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get user from context
 		user, exists := c.Get("user")
@@ -236,13 +288,13 @@ func GetURLHandler(dbConn *gorm.DB) gin.HandlerFunc {
 		}
 
 		// Get URL by ID and user to ensure user can only access their own URLs
-		url, err := service.GetURLByIDAndUser(dbConn, uint(id), userCtx.UserID)
+		url, err := urls.GetForUser(requestContext(c), uint(id), userCtx.UserID)
 		if err != nil {
-			if err == gorm.ErrRecordNotFound {
+			if err == service.ErrNotFound {
 				c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
 				return
 			}
-			log.Printf("Failed to fetch URL %d for user %d: %v", id, userCtx.UserID, err)
+			slog.ErrorContext(c.Request.Context(), "Failed to fetch URL", "url_id", id, "user_id", userCtx.UserID, "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 			return
 		}
@@ -253,32 +305,41 @@ func GetURLHandler(dbConn *gorm.DB) gin.HandlerFunc {
 
 		if url.HeadingCounts != "" {
 			if err := json.Unmarshal([]byte(url.HeadingCounts), &headingCounts); err != nil {
-				log.Printf("Failed to parse heading counts for URL %d: %v", id, err)
+				slog.ErrorContext(c.Request.Context(), "Failed to parse heading counts", "url_id", id, "error", err)
 			}
 		}
 
 		if url.BrokenList != "" {
 			if err := json.Unmarshal([]byte(url.BrokenList), &brokenList); err != nil {
-				log.Printf("Failed to parse broken list for URL %d: %v", id, err)
+				slog.ErrorContext(c.Request.Context(), "Failed to parse broken list", "url_id", id, "error", err)
 			}
 		}
 
 		detail := URLDetailResponse{
 			URLResponse: URLResponse{
-				ID:            url.ID,
-				Address:       url.Address,
-				Title:         url.Title,
-				HTMLVersion:   url.HTMLVersion,
-				HeadingCounts: url.HeadingCounts,
-				InternalLinks: url.InternalLinks,
-				ExternalLinks: url.ExternalLinks,
-				BrokenLinks:   url.BrokenLinks,
-				BrokenList:    url.BrokenList,
-				HasLoginForm:  url.HasLoginForm,
-				Status:        string(url.Status),
-				Error:         url.Error,
-				CreatedAt:     url.CreatedAt.Format("2006-01-02T15:04:05Z"),
-				UpdatedAt:     url.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+				ID:               url.ID,
+				Address:          url.Address,
+				Title:            url.Title,
+				HTMLVersion:      url.HTMLVersion,
+				HeadingCounts:    url.HeadingCounts,
+				InternalLinks:    url.InternalLinks,
+				ExternalLinks:    url.ExternalLinks,
+				BrokenLinks:      url.BrokenLinks,
+				BrokenList:       url.BrokenList,
+				HasLoginForm:     url.HasLoginForm,
+				Status:           string(url.Status),
+				Error:            url.Error,
+				LastRunID:        url.LastRunID,
+				ScheduleInterval: url.ScheduleInterval,
+				ScheduleCron:     url.ScheduleCron,
+				ScheduleTimezone: url.ScheduleTimezone,
+				SchedulePaused:   url.SchedulePaused,
+				NextRunAt:        url.NextRunAt,
+				LastRunAt:        url.LastRunAt,
+				ContentChanged:   url.ContentChanged,
+				ContentChangedAt: url.ContentChangedAt,
+				CreatedAt:        url.CreatedAt.Format("2006-01-02T15:04:05Z"),
+				UpdatedAt:        url.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 			},
 			HeadingCounts: headingCounts,
 			BrokenList:    brokenList,
@@ -289,8 +350,9 @@ func GetURLHandler(dbConn *gorm.DB) gin.HandlerFunc {
 }
 
 // BulkHandler handles bulk operations on URLs
-func BulkHandler(dbConn *gorm.DB, crawlerService *crawler.Service) gin.HandlerFunc {
+func BulkHandler(urls service.URLRepository, crawlerService *crawler.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := requestContext(c)
 		// Get user from context
 		user, exists := c.Get("user")
 		if !exists {
@@ -306,7 +368,7 @@ func BulkHandler(dbConn *gorm.DB, crawlerService *crawler.Service) gin.HandlerFu
 
 		var req BulkRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			log.Printf("Bulk operation validation error: %v", err)
+			slog.InfoContext(c.Request.Context(), "Bulk operation validation error", "error", err)
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Invalid bulk request",
 				"details": err.Error(),
@@ -321,32 +383,39 @@ func BulkHandler(dbConn *gorm.DB, crawlerService *crawler.Service) gin.HandlerFu
 		}
 
 		var affected int64
+		var conflicts []uint
 		var err error
 
 		switch req.Action {
 		case "rerun":
 			// Reset URLs to queued status - only for URLs owned by the user
-			result := dbConn.Model(&db.URL{}).Where("id IN ? AND user_id = ?", req.IDs, userCtx.UserID).Updates(map[string]interface{}{
-				"status": db.StatusQueued,
-				"error":  "",
-			})
-			affected = result.RowsAffected
-			err = result.Error
+			var requeued []uint
+			requeued, err = urls.Requeue(ctx, userCtx.UserID, req.IDs)
+			affected = int64(len(requeued))
 
 			if err == nil && affected > 0 {
 				// Notify crawler service for each URL
-				for _, id := range req.IDs {
-					if notifyErr := crawlerService.NotifyNewURL(id); notifyErr != nil {
-						log.Printf("Failed to notify crawler for URL %d: %v", id, notifyErr)
+				for _, id := range requeued {
+					if notifyErr := crawlerService.Enqueue(c.Request.Context(), id, crawler.PriorityBulk); notifyErr != nil {
+						slog.ErrorContext(c.Request.Context(), "Failed to notify crawler", "url_id", id, "error", notifyErr)
 					}
 				}
 			}
 
 		case "delete":
-			// Delete URLs - only URLs owned by the user
-			result := dbConn.Where("id IN ? AND user_id = ?", req.IDs, userCtx.UserID).Delete(&db.URL{})
-			affected = result.RowsAffected
-			err = result.Error
+			// Move URLs to the trash and stop their crawls - only URLs owned by the user
+			var deleted []uint
+			deleted, err = urls.Delete(ctx, userCtx.UserID, req.IDs)
+			affected = int64(len(deleted))
+			if err == nil {
+				crawlerService.Discard(deleted)
+			}
+
+		case "restore":
+			// Take URLs out of the trash unless their address was added again
+			var restored []uint
+			restored, conflicts, err = urls.Restore(ctx, userCtx.UserID, req.IDs)
+			affected = int64(len(restored))
 
 		default:
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action"})
@@ -354,16 +423,272 @@ func BulkHandler(dbConn *gorm.DB, crawlerService *crawler.Service) gin.HandlerFu
 		}
 
 		if err != nil {
-			log.Printf("Bulk operation failed: %v", err)
+			slog.ErrorContext(c.Request.Context(), "Bulk operation failed", "action", req.Action, "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to perform bulk operation"})
 			return
 		}
 
-		log.Printf("Bulk %s operation completed: %d URLs affected for user %d", req.Action, affected, userCtx.UserID)
+		slog.InfoContext(c.Request.Context(), "Bulk operation completed", "action", req.Action, "affected", affected, "user_id", userCtx.UserID)
+		if req.Action == "restore" {
+			// Tell the client which URLs stayed in the trash and why
+			status := http.StatusOK
+			if affected == 0 && len(conflicts) > 0 {
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{
+				"success":   affected > 0 || len(conflicts) == 0,
+				"action":    req.Action,
+				"affected":  affected,
+				"conflicts": conflicts,
+			})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{
 			"success":  true,
 			"action":   req.Action,
 			"affected": affected,
 		})
 	}
-}
\ No newline at end of file
+}
+
+// UpdateURLHandler handles changing a URL's address and schedule. A new
+// address queues a crawl.
+func UpdateURLHandler(urls service.URLRepository, crawlerService *crawler.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := requestContext(c)
+		url, ok := getOwnedURL(c, urls)
+		if !ok {
+			return
+		}
+
+		var req UpdateURLRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			slog.InfoContext(c.Request.Context(), "URL update validation error", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid URL update",
+				"details": err.Error(),
+			})
+			return
+		}
+		if req.Address == nil && req.Schedule == nil && req.SchedulePaused == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No changes provided"})
+			return
+		}
+
+		// Validate everything and apply the schedule changes to a copy, which
+		// is saved together with the address
+		changed := *url
+		var address *string
+		if req.Address != nil {
+			trimmed := strings.TrimSpace(*req.Address)
+			if trimmed == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "URL cannot be empty"})
+				return
+			}
+			if trimmed != url.Address {
+				address = &trimmed
+			}
+		}
+		if req.Schedule != nil {
+			err := validateScheduleRequest(req.Schedule)
+			if err == nil {
+				err = service.ApplySchedule(&changed, req.Schedule.Interval, req.Schedule.Cron, req.Schedule.Timezone)
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Invalid schedule",
+					"details": err.Error(),
+				})
+				return
+			}
+		}
+		if req.SchedulePaused != nil && !changed.HasSchedule() {
+			c.JSON(http.StatusConflict, gin.H{"error": "URL has no schedule"})
+			return
+		}
+		if req.SchedulePaused != nil && *req.SchedulePaused != changed.SchedulePaused {
+			if err := service.ApplySchedulePaused(&changed, *req.SchedulePaused); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Invalid schedule",
+					"details": err.Error(),
+				})
+				return
+			}
+		}
+
+		if address != nil {
+			// The user can't have the same address twice
+			existingURL, err := urls.GetByAddress(ctx, url.UserID, *address)
+			if err == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "URL already exists", "id": existingURL.ID})
+				return
+			} else if err != service.ErrNotFound {
+				slog.ErrorContext(c.Request.Context(), "Database error checking existing URL", "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+				return
+			}
+		}
+
+		updated, err := urls.Update(ctx, &changed, address)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to update URL", "url_id", url.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update URL"})
+			return
+		}
+		if !updated {
+			c.JSON(http.StatusConflict, gin.H{"error": "URL is being crawled, cancel the crawl first"})
+			return
+		}
+		if address != nil {
+			slog.InfoContext(c.Request.Context(), "Updated URL address", "url_id", url.ID, "address", *address)
+			if err := crawlerService.Enqueue(c.Request.Context(), url.ID, crawler.PriorityInteractive); err != nil {
+				slog.ErrorContext(c.Request.Context(), "Failed to notify crawler service", "url_id", url.ID, "error", err)
+			}
+		}
+		if req.Schedule != nil {
+			slog.InfoContext(c.Request.Context(), "Scheduled URL", "url_id", url.ID, "interval", req.Schedule.Interval, "cron", req.Schedule.Cron, "timezone", req.Schedule.Timezone, "next_run_at", changed.NextRunAt)
+		}
+
+		url, err = urls.GetForUser(ctx, url.ID, url.UserID)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to fetch updated URL", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "URL updated but failed to fetch details"})
+			return
+		}
+
+		c.JSON(http.StatusOK, url)
+	}
+}
+
+// DeleteURLHandler handles moving a single URL to the trash
+func DeleteURLHandler(urls service.URLRepository, crawlerService *crawler.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		url, ok := getOwnedURL(c, urls)
+		if !ok {
+			return
+		}
+
+		deleted, err := urls.Delete(requestContext(c), url.UserID, []uint{url.ID})
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to delete URL", "url_id", url.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete URL"})
+			return
+		}
+		if len(deleted) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+			return
+		}
+		crawlerService.Discard(deleted)
+
+		slog.InfoContext(c.Request.Context(), "Deleted URL", "url_id", url.ID)
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"id":      url.ID,
+		})
+	}
+}
+
+// RerunURLHandler handles queueing a new crawl of a single URL
+func RerunURLHandler(urls service.URLRepository, crawlerService *crawler.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		url, ok := getOwnedURL(c, urls)
+		if !ok {
+			return
+		}
+
+		requeued, err := urls.RequeueIdle(requestContext(c), url.ID)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to requeue URL", "url_id", url.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rerun URL"})
+			return
+		}
+		if !requeued {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":  "URL is already queued or running",
+				"status": url.Status,
+			})
+			return
+		}
+
+		if err := crawlerService.Enqueue(c.Request.Context(), url.ID, crawler.PriorityInteractive); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to notify crawler service", "url_id", url.ID, "error", err)
+		}
+
+		slog.InfoContext(c.Request.Context(), "Queued URL rerun", "url_id", url.ID, "previous_status", url.Status)
+		c.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"id":      url.ID,
+		})
+	}
+}
+
+// CancelURLHandler handles cancelling the queued or running crawl of a URL
+func CancelURLHandler(urls service.URLRepository, crawlerService *crawler.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		url, ok := getOwnedURL(c, urls)
+		if !ok {
+			return
+		}
+
+		cancelled, err := crawlerService.CancelURL(url.ID)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to cancel URL", "url_id", url.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel crawl"})
+			return
+		}
+		if !cancelled {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":  "URL is not queued or running",
+				"status": url.Status,
+			})
+			return
+		}
+
+		slog.InfoContext(c.Request.Context(), "Cancelled crawl", "url_id", url.ID, "previous_status", url.Status)
+		c.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"id":      url.ID,
+		})
+	}
+}
+
+// requestContext carries the request's trace to storage. Cancellation is
+// dropped so a client disconnecting midway doesn't abort writes.
+func requestContext(c *gin.Context) context.Context {
+	return context.WithoutCancel(c.Request.Context())
+}
+
+// getOwnedURL resolves the :id path parameter to a URL owned by the
+// authenticated user, writing the error response itself when it can't
+func getOwnedURL(c *gin.Context, urls service.URLRepository) (*db.URL, bool) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	userCtx, ok := user.(middleware.UserContext)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL ID"})
+		return nil, false
+	}
+
+	url, err := urls.GetForUser(requestContext(c), uint(id), userCtx.UserID)
+	if err != nil {
+		if err == service.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+			return nil, false
+		}
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch URL", "url_id", id, "user_id", userCtx.UserID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return nil, false
+	}
+
+	return url, true
+}
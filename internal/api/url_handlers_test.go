@@ -0,0 +1,264 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sykell/url-crawler/internal/crawler"
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/middleware"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+// newTestRouter serves the URL endpoints for userID from an in-memory
+// repository. The crawler isn't started, so nothing is fetched.
+func newTestRouter(urls service.URLRepository, userID uint) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	crawlerService := crawler.NewService(urls, crawler.DefaultConfig())
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user", middleware.UserContext{UserID: userID, Username: "tester"})
+	})
+	r.POST("/urls", PostURLHandler(urls, crawlerService))
+	r.GET("/urls/:id", GetURLHandler(urls))
+	r.PATCH("/urls/:id", UpdateURLHandler(urls, crawlerService))
+	r.DELETE("/urls/:id", DeleteURLHandler(urls, crawlerService))
+	r.GET("/urls/:id/runs", ListRunsHandler(urls))
+	r.POST("/urls/bulk", BulkHandler(urls, crawlerService))
+	return r
+}
+
+// serve performs a request and decodes the JSON response into out
+func serve(t *testing.T, r http.Handler, method, path string, body interface{}, out interface{}) int {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: invalid response %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+// crawledURL creates a URL with a finished run whose results it stores
+func crawledURL(t *testing.T, urls *service.MemoryURLRepository, userID uint, address string) *db.URL {
+	t.Helper()
+	ctx := context.Background()
+	url, err := urls.Create(ctx, userID, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := urls.ClaimQueued(ctx, url.ID); err != nil {
+		t.Fatal(err)
+	}
+	run, err := urls.CreateRun(ctx, url.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finishedAt := time.Now()
+	changed := true
+	run.Status = db.StatusDone
+	run.FinishedAt = &finishedAt
+	run.Title = "Old Site"
+	run.InternalLinks = 3
+	run.BrokenLinks = 1
+	run.BrokenList = `[{"url":"https://example.com/gone","code":"404"}]`
+	run.TextHash = "abc"
+	run.ContentChanged = changed
+	err = urls.FinishRun(ctx, run, service.URLRunUpdate{
+		Status:           db.StatusDone,
+		LastRunID:        &run.ID,
+		LastRunAt:        &run.StartedAt,
+		Results:          true,
+		ETag:             `"v1"`,
+		ContentChanged:   &changed,
+		ContentChangedAt: &finishedAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url, err = urls.GetByID(ctx, url.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return url
+}
+
+func TestPostURL(t *testing.T) {
+	urls := service.NewMemoryURLRepository()
+	r := newTestRouter(urls, 1)
+
+	var created db.URL
+	if code := serve(t, r, http.MethodPost, "/urls", gin.H{"address": "https://example.com"}, &created); code != http.StatusCreated {
+		t.Fatalf("POST /urls = %d, want %d", code, http.StatusCreated)
+	}
+	if created.ID == 0 || created.Status != db.StatusQueued {
+		t.Errorf("created URL = %+v", created)
+	}
+
+	var conflict struct {
+		ID uint `json:"id"`
+	}
+	if code := serve(t, r, http.MethodPost, "/urls", gin.H{"address": "https://example.com"}, &conflict); code != http.StatusConflict {
+		t.Errorf("duplicate POST /urls = %d, want %d", code, http.StatusConflict)
+	}
+	if conflict.ID != created.ID {
+		t.Errorf("conflict id = %d, want %d", conflict.ID, created.ID)
+	}
+
+	if code := serve(t, r, http.MethodPost, "/urls", gin.H{"address": "not a url"}, nil); code != http.StatusBadRequest {
+		t.Errorf("invalid POST /urls = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestURLsOfOtherUsersAreNotFound(t *testing.T) {
+	urls := service.NewMemoryURLRepository()
+	url := crawledURL(t, urls, 2, "https://example.com")
+	r := newTestRouter(urls, 1)
+
+	path := "/urls/" + itoa(url.ID)
+	for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete} {
+		var body interface{}
+		if method == http.MethodPatch {
+			body = gin.H{"address": "https://example.org"}
+		}
+		if code := serve(t, r, method, path, body, nil); code != http.StatusNotFound {
+			t.Errorf("%s %s = %d, want %d", method, path, code, http.StatusNotFound)
+		}
+	}
+	if code := serve(t, r, http.MethodGet, path+"/runs", nil, nil); code != http.StatusNotFound {
+		t.Errorf("GET %s/runs = %d, want %d", path, code, http.StatusNotFound)
+	}
+}
+
+func TestUpdateURLAddressResetsResults(t *testing.T) {
+	urls := service.NewMemoryURLRepository()
+	url := crawledURL(t, urls, 1, "https://example.com")
+	r := newTestRouter(urls, 1)
+	path := "/urls/" + itoa(url.ID)
+
+	var updated db.URL
+	if code := serve(t, r, http.MethodPatch, path, gin.H{"address": "https://example.org"}, &updated); code != http.StatusOK {
+		t.Fatalf("PATCH %s = %d, want %d", path, code, http.StatusOK)
+	}
+	if updated.Address != "https://example.org" || updated.Status != db.StatusQueued {
+		t.Errorf("updated URL = %s %s", updated.Address, updated.Status)
+	}
+	if updated.Title != "" || updated.BrokenLinks != 0 || updated.ETag != "" || updated.LastRunID != nil ||
+		updated.ContentChanged || updated.ContentChangedAt != nil {
+		t.Errorf("updated URL kept results of the old address: %+v", updated)
+	}
+
+	var runs PaginatedResponse
+	serve(t, r, http.MethodGet, path+"/runs", nil, &runs)
+	if runs.Total != 0 {
+		t.Errorf("runs after address change = %d, want 0", runs.Total)
+	}
+}
+
+func TestUpdateURLSchedule(t *testing.T) {
+	urls := service.NewMemoryURLRepository()
+	url := crawledURL(t, urls, 1, "https://example.com")
+	r := newTestRouter(urls, 1)
+	path := "/urls/" + itoa(url.ID)
+
+	// Pausing needs a schedule
+	if code := serve(t, r, http.MethodPatch, path, gin.H{"schedule_paused": true}, nil); code != http.StatusConflict {
+		t.Errorf("pausing without schedule = %d, want %d", code, http.StatusConflict)
+	}
+
+	// An invalid schedule rejects the whole update
+	body := gin.H{"address": "https://example.org", "schedule": gin.H{"interval": "10s"}}
+	if code := serve(t, r, http.MethodPatch, path, body, nil); code != http.StatusBadRequest {
+		t.Errorf("invalid schedule = %d, want %d", code, http.StatusBadRequest)
+	}
+	if stored, _ := urls.GetByID(context.Background(), url.ID); stored.Address != url.Address {
+		t.Errorf("address changed to %s by a rejected update", stored.Address)
+	}
+
+	var updated db.URL
+	body = gin.H{"schedule": gin.H{"interval": "2h"}, "schedule_paused": true}
+	if code := serve(t, r, http.MethodPatch, path, body, &updated); code != http.StatusOK {
+		t.Fatalf("PATCH schedule = %d, want %d", code, http.StatusOK)
+	}
+	if updated.ScheduleInterval != "2h" || !updated.SchedulePaused || updated.NextRunAt == nil {
+		t.Errorf("schedule = %q paused %v next %v", updated.ScheduleInterval, updated.SchedulePaused, updated.NextRunAt)
+	}
+	if updated.Title != "Old Site" {
+		t.Error("schedule change dropped the URL's results")
+	}
+
+	if code := serve(t, r, http.MethodPatch, path, gin.H{"schedule_paused": false}, &updated); code != http.StatusOK || updated.SchedulePaused {
+		t.Errorf("resume = %d, paused %v", code, updated.SchedulePaused)
+	}
+}
+
+func TestUpdateURLWhileRunning(t *testing.T) {
+	urls := service.NewMemoryURLRepository()
+	url, _ := urls.Create(context.Background(), 1, "https://example.com")
+	urls.ClaimQueued(context.Background(), url.ID)
+	r := newTestRouter(urls, 1)
+
+	body := gin.H{"address": "https://example.org", "schedule": gin.H{"interval": "2h"}}
+	if code := serve(t, r, http.MethodPatch, "/urls/"+itoa(url.ID), body, nil); code != http.StatusConflict {
+		t.Errorf("PATCH running URL = %d, want %d", code, http.StatusConflict)
+	}
+	if stored, _ := urls.GetByID(context.Background(), url.ID); stored.HasSchedule() {
+		t.Error("schedule saved by a rejected update")
+	}
+}
+
+func TestBulkRestoreReportsConflicts(t *testing.T) {
+	urls := service.NewMemoryURLRepository()
+	r := newTestRouter(urls, 1)
+
+	url, _ := urls.Create(context.Background(), 1, "https://example.com")
+	if code := serve(t, r, http.MethodDelete, "/urls/"+itoa(url.ID), nil, nil); code != http.StatusOK {
+		t.Fatalf("DELETE = %d, want %d", code, http.StatusOK)
+	}
+	if code := serve(t, r, http.MethodGet, "/urls/"+itoa(url.ID), nil, nil); code != http.StatusNotFound {
+		t.Errorf("GET deleted URL = %d, want %d", code, http.StatusNotFound)
+	}
+
+	// The address is added again, so the deleted URL can't come back
+	urls.Create(context.Background(), 1, "https://example.com")
+
+	var resp struct {
+		Affected  int64  `json:"affected"`
+		Conflicts []uint `json:"conflicts"`
+	}
+	code := serve(t, r, http.MethodPost, "/urls/bulk", gin.H{"action": "restore", "ids": []uint{url.ID}}, &resp)
+	if code != http.StatusConflict || resp.Affected != 0 || len(resp.Conflicts) != 1 || resp.Conflicts[0] != url.ID {
+		t.Errorf("restore = %d %+v, want a conflict for %d", code, resp, url.ID)
+	}
+}
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,379 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/middleware"
+	"github.com/sykell/url-crawler/internal/service"
+	"github.com/sykell/url-crawler/internal/webhook"
+)
+
+// WebhookRequest represents a request to register a webhook. When Secret is
+// empty one is generated; Events defaults to all supported event types.
+type WebhookRequest struct {
+	URL    string   `json:"url" binding:"required,url"`
+	Secret string   `json:"secret" binding:"omitempty,min=16,max=255"`
+	Events []string `json:"events"`
+}
+
+// UpdateWebhookRequest represents a partial webhook update
+type UpdateWebhookRequest struct {
+	URL    *string   `json:"url" binding:"omitempty,url"`
+	Events *[]string `json:"events"`
+	Active *bool     `json:"active"`
+}
+
+// WebhookResponse represents a webhook in API responses
+type WebhookResponse struct {
+	ID           uint       `json:"id"`
+	URL          string     `json:"url"`
+	Secret       string     `json:"secret,omitempty"` // Only returned on creation
+	Events       []string   `json:"events"`
+	Active       bool       `json:"active"`
+	FailureCount int        `json:"failure_count"`
+	DisabledAt   *time.Time `json:"disabled_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// CreateWebhookHandler handles registering a webhook endpoint
+func CreateWebhookHandler(webhooks service.WebhookRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user from context
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		userCtx, ok := user.(middleware.UserContext)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+			return
+		}
+
+		var req WebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid webhook request",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		eventTypes, ok := validateWebhookEvents(c, req.Events)
+		if !ok {
+			return
+		}
+
+		secret := req.Secret
+		if secret == "" {
+			var err error
+			if secret, err = generateWebhookSecret(); err != nil {
+				slog.ErrorContext(c.Request.Context(), "Failed to generate webhook secret", "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+				return
+			}
+		}
+
+		hook, err := webhooks.Create(requestContext(c), userCtx.UserID, req.URL, secret, eventTypes)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to create webhook", "user_id", userCtx.UserID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+			return
+		}
+
+		slog.InfoContext(c.Request.Context(), "Webhook registered", "webhook_id", hook.ID, "user_id", userCtx.UserID, "url", hook.URL)
+
+		response := toWebhookResponse(hook)
+		response.Secret = secret
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// ListWebhooksHandler handles listing the user's webhooks
+func ListWebhooksHandler(webhooks service.WebhookRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user from context
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		userCtx, ok := user.(middleware.UserContext)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+			return
+		}
+
+		hooks, err := webhooks.List(requestContext(c), userCtx.UserID)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to list webhooks", "user_id", userCtx.UserID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		responses := make([]WebhookResponse, len(hooks))
+		for i := range hooks {
+			responses[i] = toWebhookResponse(&hooks[i])
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": responses})
+	}
+}
+
+// GetWebhookHandler handles retrieving a single webhook
+func GetWebhookHandler(webhooks service.WebhookRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook, ok := getOwnedWebhook(c, webhooks)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, toWebhookResponse(hook))
+	}
+}
+
+// UpdateWebhookHandler handles changing a webhook's URL, event filter or
+// active state. Re-activating a webhook resets its failure count.
+func UpdateWebhookHandler(webhooks service.WebhookRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook, ok := getOwnedWebhook(c, webhooks)
+		if !ok {
+			return
+		}
+
+		var req UpdateWebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid webhook request",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		update := service.WebhookUpdate{URL: req.URL}
+		if req.Events != nil {
+			eventTypes, ok := validateWebhookEvents(c, *req.Events)
+			if !ok {
+				return
+			}
+			update.Events = &eventTypes
+		}
+		if req.Active != nil && *req.Active != hook.Active {
+			update.Active = req.Active
+		}
+
+		if err := webhooks.Update(requestContext(c), hook.ID, update); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to update webhook", "webhook_id", hook.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+			return
+		}
+
+		updated, err := webhooks.GetByID(requestContext(c), hook.ID)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to reload webhook", "webhook_id", hook.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(http.StatusOK, toWebhookResponse(updated))
+	}
+}
+
+// DeleteWebhookHandler handles removing a webhook and its delivery log
+func DeleteWebhookHandler(webhooks service.WebhookRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook, ok := getOwnedWebhook(c, webhooks)
+		if !ok {
+			return
+		}
+
+		if err := webhooks.Delete(requestContext(c), hook.ID); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to delete webhook", "webhook_id", hook.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+	}
+}
+
+// ListDeliveriesHandler handles listing a webhook's delivery log with pagination
+func ListDeliveriesHandler(webhooks service.WebhookRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook, ok := getOwnedWebhook(c, webhooks)
+		if !ok {
+			return
+		}
+
+		// Parse pagination parameters
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		pageSize, err := strconv.Atoi(c.DefaultQuery("size", "10"))
+		if err != nil || pageSize < 1 || pageSize > 100 {
+			pageSize = 10
+		}
+
+		deliveries, total, err := webhooks.ListDeliveries(requestContext(c), hook.ID, page, pageSize)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to fetch deliveries", "webhook_id", hook.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		c.JSON(http.StatusOK, PaginatedResponse{
+			Data:  deliveries,
+			Page:  page,
+			Size:  pageSize,
+			Total: total,
+			Pages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+		})
+	}
+}
+
+// RedeliverHandler handles queueing a copy of a past delivery for immediate sending
+func RedeliverHandler(webhooks service.WebhookRepository, dispatcher *webhook.Dispatcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook, ok := getOwnedWebhook(c, webhooks)
+		if !ok {
+			return
+		}
+
+		deliveryID, err := strconv.ParseUint(c.Param("delivery_id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+			return
+		}
+
+		original, err := webhooks.GetDelivery(requestContext(c), hook.ID, uint(deliveryID))
+		if err != nil {
+			if err == service.ErrNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+				return
+			}
+			slog.ErrorContext(c.Request.Context(), "Failed to fetch delivery", "delivery_id", deliveryID, "webhook_id", hook.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+
+		if !hook.Active {
+			c.JSON(http.StatusConflict, gin.H{"error": "Webhook is disabled"})
+			return
+		}
+
+		delivery, err := webhooks.CreateDelivery(requestContext(c), hook.ID, original.EventType, original.Payload)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to redeliver delivery", "delivery_id", original.ID, "webhook_id", hook.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue redelivery"})
+			return
+		}
+		dispatcher.Wake()
+
+		c.JSON(http.StatusAccepted, delivery)
+	}
+}
+
+// getOwnedWebhook resolves the :id parameter to a webhook owned by the
+// authenticated user, writing the error response when it can't
+func getOwnedWebhook(c *gin.Context, webhooks service.WebhookRepository) (*db.Webhook, bool) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	userCtx, ok := user.(middleware.UserContext)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return nil, false
+	}
+
+	hook, err := webhooks.GetForUser(requestContext(c), uint(id), userCtx.UserID)
+	if err != nil {
+		if err == service.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return nil, false
+		}
+		slog.ErrorContext(c.Request.Context(), "Failed to fetch webhook", "webhook_id", id, "user_id", userCtx.UserID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return nil, false
+	}
+
+	return hook, true
+}
+
+// validateWebhookEvents checks the requested event types against the
+// supported ones, defaulting to all of them
+func validateWebhookEvents(c *gin.Context, requested []string) ([]string, bool) {
+	if len(requested) == 0 {
+		return webhook.SupportedEvents, true
+	}
+
+	eventTypes := make([]string, 0, len(requested))
+	for _, eventType := range requested {
+		supported := false
+		for _, s := range webhook.SupportedEvents {
+			if eventType == s {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":     "Unsupported event type: " + eventType,
+				"supported": webhook.SupportedEvents,
+			})
+			return nil, false
+		}
+		eventTypes = append(eventTypes, eventType)
+	}
+	return eventTypes, true
+}
+
+// generateWebhookSecret returns a random 32-byte hex encoded secret
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// toWebhookResponse converts a webhook model to its API representation
+func toWebhookResponse(hook *db.Webhook) WebhookResponse {
+	eventTypes := webhook.SupportedEvents
+	if hook.Events != "" {
+		eventTypes = strings.Split(hook.Events, ",")
+	}
+
+	return WebhookResponse{
+		ID:           hook.ID,
+		URL:          hook.URL,
+		Events:       eventTypes,
+		Active:       hook.Active,
+		FailureCount: hook.FailureCount,
+		DisabledAt:   hook.DisabledAt,
+		CreatedAt:    hook.CreatedAt,
+		UpdatedAt:    hook.UpdatedAt,
+	}
+}
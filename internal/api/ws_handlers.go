@@ -0,0 +1,263 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/sykell/url-crawler/internal/crawler"
+	"github.com/sykell/url-crawler/internal/middleware"
+	"github.com/sykell/url-crawler/internal/realtime"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+const (
+	// wsWriteWait is the time allowed to write a message to the peer
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is how long the peer may stay silent before it is dropped
+	wsPongWait = 60 * time.Second
+	// wsPingPeriod must be shorter than wsPongWait
+	wsPingPeriod = wsPongWait * 9 / 10
+	// wsMaxMessageSize bounds client commands
+	wsMaxMessageSize = 4096
+)
+
+// wsUpgrader accepts any origin, matching the permissive CORS policy
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// WSCommand is a message sent by a WebSocket client
+type WSCommand struct {
+	Action string `json:"action"` // subscribe, unsubscribe, cancel or rerun
+	IDs    []uint `json:"ids,omitempty"`
+	ID     uint   `json:"id,omitempty"`
+}
+
+// WSReply acknowledges a client command
+type WSReply struct {
+	Type   string `json:"type"` // "ack" or "error"
+	Action string `json:"action,omitempty"`
+	ID     uint   `json:"id,omitempty"`
+	IDs    []uint `json:"ids,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// WebSocketHandler serves a bidirectional connection streaming status and
+// progress events for the URLs a client subscribed to, and accepting
+// cancel and rerun commands
+func WebSocketHandler(urls service.URLRepository, crawlerService *crawler.Service, hub *realtime.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user from context
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		userCtx, ok := user.(middleware.UserContext)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+			return
+		}
+
+		conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "WebSocket upgrade failed", "user_id", userCtx.UserID, "error", err)
+			return
+		}
+		defer conn.Close()
+
+		sub, _ := hub.Subscribe(userCtx.UserID, 0)
+		defer sub.Close()
+
+		session := &wsSession{
+			ctx:            c.Request.Context(),
+			hub:            hub,
+			urls:           urls,
+			crawlerService: crawlerService,
+			userID:         userCtx.UserID,
+			subscribed:     make(map[uint]bool),
+			replies:        make(chan WSReply, 16),
+			done:           make(chan struct{}),
+			stopped:        make(chan struct{}),
+		}
+
+		go session.readLoop(conn)
+		session.writeLoop(conn, sub)
+	}
+}
+
+// wsSession holds the state of a single WebSocket connection
+type wsSession struct {
+	// ctx carries the request ID and trace of the upgrade request
+	ctx            context.Context
+	hub            *realtime.Hub
+	urls           service.URLRepository
+	crawlerService *crawler.Service
+	userID         uint
+
+	// subscribed is only accessed from the write loop
+	subscribed map[uint]bool
+	// replies are handed to the write loop, the connection's only writer
+	replies chan WSReply
+	// done is closed when the read loop ends, stopped when the write loop does
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// readLoop decodes client commands until the connection fails
+func (s *wsSession) readLoop(conn *websocket.Conn) {
+	defer close(s.done)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var cmd WSCommand
+		if err := conn.ReadJSON(&cmd); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				slog.WarnContext(s.ctx, "WebSocket read error", "user_id", s.userID, "error", err)
+			}
+			return
+		}
+
+		select {
+		case s.replies <- s.handleCommand(cmd):
+		case <-s.stopped:
+			return
+		}
+	}
+}
+
+// writeLoop forwards subscribed events, command replies and pings
+func (s *wsSession) writeLoop(conn *websocket.Conn, sub *realtime.Subscription) {
+	defer close(s.stopped)
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				closeMsg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow")
+				if s.hub.Closed() {
+					closeMsg = websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+				}
+				conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(wsWriteWait))
+				return
+			}
+			if !s.subscribed[event.URLID] {
+				continue
+			}
+			if err := s.write(conn, event); err != nil {
+				return
+			}
+
+		case reply := <-s.replies:
+			// Subscription changes are applied here to keep the map single-threaded
+			if reply.Type == "ack" {
+				switch reply.Action {
+				case "subscribe":
+					for _, id := range reply.IDs {
+						s.subscribed[id] = true
+					}
+				case "unsubscribe":
+					for _, id := range reply.IDs {
+						delete(s.subscribed, id)
+					}
+				}
+			}
+			if err := s.write(conn, reply); err != nil {
+				return
+			}
+
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// write sends a JSON message with a write deadline
+func (s *wsSession) write(conn *websocket.Conn, v interface{}) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	if err := conn.WriteJSON(v); err != nil {
+		slog.WarnContext(s.ctx, "WebSocket write error", "user_id", s.userID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// handleCommand executes a client command and builds its reply
+func (s *wsSession) handleCommand(cmd WSCommand) WSReply {
+	switch cmd.Action {
+	case "subscribe", "unsubscribe":
+		if len(cmd.IDs) == 0 {
+			return WSReply{Type: "error", Action: cmd.Action, Error: "ids required"}
+		}
+		return WSReply{Type: "ack", Action: cmd.Action, IDs: cmd.IDs}
+
+	case "cancel":
+		if _, err := s.urls.GetForUser(s.ctx, cmd.ID, s.userID); err != nil {
+			return s.lookupErrorReply(cmd, err)
+		}
+		cancelled, err := s.crawlerService.CancelURL(cmd.ID)
+		if err != nil {
+			slog.ErrorContext(s.ctx, "Failed to cancel URL via WebSocket", "url_id", cmd.ID, "error", err)
+			return WSReply{Type: "error", Action: cmd.Action, ID: cmd.ID, Error: "Internal server error"}
+		}
+		if !cancelled {
+			return WSReply{Type: "error", Action: cmd.Action, ID: cmd.ID, Error: "URL is not queued or running"}
+		}
+		slog.InfoContext(s.ctx, "Cancelled crawl via WebSocket", "url_id", cmd.ID, "user_id", s.userID)
+		return WSReply{Type: "ack", Action: cmd.Action, ID: cmd.ID}
+
+	case "rerun":
+		url, err := s.urls.GetForUser(s.ctx, cmd.ID, s.userID)
+		if err != nil {
+			return s.lookupErrorReply(cmd, err)
+		}
+		requeued, err := s.urls.RequeueIdle(s.ctx, url.ID)
+		if err != nil {
+			slog.ErrorContext(s.ctx, "Failed to requeue URL via WebSocket", "url_id", cmd.ID, "error", err)
+			return WSReply{Type: "error", Action: cmd.Action, ID: cmd.ID, Error: "Internal server error"}
+		}
+		if !requeued {
+			return WSReply{Type: "error", Action: cmd.Action, ID: cmd.ID, Error: "URL is already queued or running"}
+		}
+		// The URL is queued in the database, so the crawler picks it up later
+		// even if it can't be enqueued now
+		if err := s.crawlerService.NotifyNewURL(s.ctx, cmd.ID); err != nil {
+			slog.ErrorContext(s.ctx, "Failed to notify crawler", "url_id", cmd.ID, "error", err)
+		}
+		slog.InfoContext(s.ctx, "Queued URL rerun via WebSocket", "url_id", cmd.ID, "previous_status", url.Status)
+		return WSReply{Type: "ack", Action: cmd.Action, ID: cmd.ID}
+
+	default:
+		return WSReply{Type: "error", Action: cmd.Action, Error: "Unknown action"}
+	}
+}
+
+// lookupErrorReply builds the reply for a failed URL ownership lookup
+func (s *wsSession) lookupErrorReply(cmd WSCommand, err error) WSReply {
+	if err == service.ErrNotFound {
+		return WSReply{Type: "error", Action: cmd.Action, ID: cmd.ID, Error: "URL not found"}
+	}
+	slog.ErrorContext(s.ctx, "Failed to fetch URL", "url_id", cmd.ID, "user_id", s.userID, "error", err)
+	return WSReply{Type: "error", Action: cmd.Action, ID: cmd.ID, Error: "Internal server error"}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sykell/url-crawler/internal/crawler"
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+func TestWebSocketRerunOnlyRequeuesIdleURLs(t *testing.T) {
+	urls := service.NewMemoryURLRepository()
+	session := &wsSession{
+		ctx:            context.Background(),
+		urls:           urls,
+		crawlerService: crawler.NewService(urls, crawler.DefaultConfig()),
+		userID:         1,
+	}
+	idle := crawledURL(t, urls, 1, "https://example.com")
+	other := crawledURL(t, urls, 2, "https://example.org")
+
+	tests := []struct {
+		name  string
+		id    uint
+		reply string
+		err   string
+	}{
+		{"idle URL", idle.ID, "ack", ""},
+		{"queued URL", idle.ID, "error", "URL is already queued or running"},
+		{"other user's URL", other.ID, "error", "URL not found"},
+	}
+	for _, tt := range tests {
+		reply := session.handleCommand(WSCommand{Action: "rerun", ID: tt.id})
+		if reply.Type != tt.reply || reply.Error != tt.err {
+			t.Errorf("%s: reply %+v, want %s %q", tt.name, reply, tt.reply, tt.err)
+		}
+	}
+
+	if url, _ := urls.GetByID(context.Background(), other.ID); url.Status != db.StatusDone {
+		t.Errorf("other user's URL status = %s, want %s", url.Status, db.StatusDone)
+	}
+}
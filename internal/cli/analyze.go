@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/crawler"
+	"github.com/sykell/url-crawler/internal/db"
+)
+
+// analyzeUsage describes the analyze subcommand
+const analyzeUsage = `usage: url-crawler analyze -file <file> -base <url> [-skip-links]
+
+Analyzes a local HTML file, or a saved HTTP response starting with its status
+line (e.g. from curl -si), as if the crawler had fetched it from the base URL,
+and prints the crawl run it would store as JSON, in the form the API returns
+it. Links are still checked over the network unless -skip-links is set. Use -
+as the file to read from stdin.`
+
+// runAnalyze analyzes a saved document and prints the result
+func runAnalyze(args []string) int {
+	fs := newFlagSet("analyze", analyzeUsage)
+	path := fs.String("file", "", "HTML file or saved HTTP response")
+	base := fs.String("base", "", "URL the document was fetched from")
+	skipLinks := fs.Bool("skip-links", false, "count links without checking them")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *path == "" || *base == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if !validAddress(*base) {
+		fmt.Fprintf(os.Stderr, "invalid base URL %q\n", *base)
+		return exitUsage
+	}
+
+	var input io.Reader = os.Stdin
+	if *path != "-" {
+		file, err := os.Open(*path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open %s: %v\n", *path, err)
+			return exitFailure
+		}
+		defer file.Close()
+		input = file
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	crawlerService := crawler.NewService(nil, crawler.NewConfig())
+	run, err := analyzeRun(ctx, crawlerService, bufio.NewReader(input), *base, *skipLinks)
+	if err != nil {
+		slog.Error("Analysis failed", "file", *path, "error", err)
+		return exitFailure
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(run); err != nil {
+		slog.Error("Failed to write result", "error", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// analyzeRun analyzes r like analyzeInput and returns the run a crawl would
+// store, timed like one
+func analyzeRun(ctx context.Context, crawlerService *crawler.Service, r *bufio.Reader, base string, skipLinks bool) (*db.CrawlRun, error) {
+	startedAt := time.Now()
+	result, err := analyzeInput(ctx, crawlerService, r, base, skipLinks)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := result.Run()
+	if err != nil {
+		return nil, err
+	}
+	finishedAt := time.Now()
+	run.StartedAt = startedAt
+	run.FinishedAt = &finishedAt
+	run.DurationMs = finishedAt.Sub(startedAt).Milliseconds()
+	run.CreatedAt = startedAt
+	return run, nil
+}
+
+// analyzeInput analyzes r as a saved HTTP response when it starts with a
+// status line, and as a plain HTML document otherwise
+func analyzeInput(ctx context.Context, crawlerService *crawler.Service, r *bufio.Reader, base string, skipLinks bool) (*crawler.CrawlResult, error) {
+	if prefix, _ := r.Peek(5); !bytes.Equal(prefix, []byte("HTTP/")) {
+		return crawlerService.Analyze(ctx, r, base, skipLinks)
+	}
+
+	// curl saves HTTP/2 responses as "HTTP/2 200", which net/http doesn't parse
+	statusLine, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved response: %w", err)
+	}
+	if proto, rest, _ := strings.Cut(statusLine, " "); proto == "HTTP/2" || proto == "HTTP/3" {
+		statusLine = proto + ".0 " + rest
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(io.MultiReader(strings.NewReader(statusLine), r)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// The crawler's HTTP client decompresses transparently; do the same
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		body, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress saved response: %w", err)
+		}
+		defer body.Close()
+		resp.Body = body
+		resp.Header.Del("Content-Encoding")
+	}
+	return crawlerService.AnalyzeResponse(ctx, resp, base, skipLinks)
+}
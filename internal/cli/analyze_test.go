@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/sykell/url-crawler/internal/crawler"
+	"github.com/sykell/url-crawler/internal/db"
+)
+
+func analyzeFixture(t *testing.T, name string) (*db.CrawlRun, error) {
+	t.Helper()
+	file, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	crawlerService := crawler.NewService(nil, crawler.NewConfig())
+	return analyzeRun(context.Background(), crawlerService, bufio.NewReader(file), "https://example.com/", true)
+}
+
+func TestAnalyzeRunFixtures(t *testing.T) {
+	for _, name := range []string{"page.html", "response.txt"} {
+		t.Run(name, func(t *testing.T) {
+			run, err := analyzeFixture(t, name)
+			if err != nil {
+				t.Fatalf("analyzeRun: %v", err)
+			}
+
+			want := db.CrawlRun{
+				Status:        db.StatusDone,
+				Title:         "Example Shop",
+				HTMLVersion:   "HTML5",
+				HeadingCounts: `{"h1":1,"h2":2,"h3":0,"h4":0,"h5":0,"h6":0}`,
+				InternalLinks: 2,
+				ExternalLinks: 1,
+				BrokenList:    "[]",
+				HasLoginForm:  true,
+			}
+			got := db.CrawlRun{
+				Status:        run.Status,
+				Title:         run.Title,
+				HTMLVersion:   run.HTMLVersion,
+				HeadingCounts: run.HeadingCounts,
+				InternalLinks: run.InternalLinks,
+				ExternalLinks: run.ExternalLinks,
+				BrokenLinks:   run.BrokenLinks,
+				BrokenList:    run.BrokenList,
+				HasLoginForm:  run.HasLoginForm,
+			}
+			if got != want {
+				t.Errorf("run = %+v, want %+v", got, want)
+			}
+			if run.BodyHash == "" || run.TextHash == "" || run.TextSimhash == "" {
+				t.Errorf("run is missing content fingerprints: %+v", run)
+			}
+			if run.FinishedAt == nil || run.FinishedAt.Before(run.StartedAt) {
+				t.Errorf("run timing = %v to %v", run.StartedAt, run.FinishedAt)
+			}
+		})
+	}
+}
+
+func TestAnalyzeRunSavedResponseMatchesPage(t *testing.T) {
+	page, err := analyzeFixture(t, "page.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := analyzeFixture(t, "response.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The saved response carries the page gzip-encoded
+	if page.BodyHash != response.BodyHash {
+		t.Errorf("body hash of saved response = %s, want %s", response.BodyHash, page.BodyHash)
+	}
+}
+
+func TestAnalyzeRunOutputMatchesAPI(t *testing.T) {
+	run, err := analyzeFixture(t, "page.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["main_text"]; ok {
+		t.Error("output includes main_text, which the API doesn't return")
+	}
+	for _, field := range []string{"status", "title", "heading_counts", "broken_links", "broken_list", "duration_ms"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("output is missing %q", field)
+		}
+	}
+}
+
+func TestAnalyzeRunSavedErrorResponse(t *testing.T) {
+	_, err := analyzeFixture(t, "not_found.txt")
+	var statusErr *crawler.StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != 404 {
+		t.Fatalf("err = %v, want a 404 status error", err)
+	}
+}
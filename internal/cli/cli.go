@@ -0,0 +1,129 @@
+// Package cli implements the url-crawler command line: the server and the
+// administrative subcommands sharing its configuration
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/logging"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+// Exit codes returned by Run
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// usage describes the available commands
+const usage = `usage: url-crawler [command] [arguments]
+
+commands:
+  serve                  run the API server and crawler (default)
+  migrate <command>      apply, revert or list schema migrations
+  user <command>         create, list, disable or enable users and reset passwords
+  url import <file>      queue the URLs listed in a file for a user
+  crawl <url>            crawl a URL once and print the result as JSON
+  analyze                analyze a saved HTML file or HTTP response offline
+  requeue                queue URLs with a given status again
+
+Run "url-crawler <command> -h" for the options of a command.`
+
+// command runs a subcommand with its arguments and returns the exit code
+type command func(args []string) int
+
+// commands maps subcommand names to their implementation
+var commands = map[string]command{
+	"serve":   runServe,
+	"migrate": runMigrate,
+	"user":    runUser,
+	"url":     runURL,
+	"crawl":   runCrawl,
+	"analyze": runAnalyze,
+	"requeue": runRequeue,
+}
+
+// Run runs the command named by the first argument, the server when there
+// is none, and returns the process exit code
+func Run(args []string) int {
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" || isHelp(name) {
+		fmt.Fprintln(os.Stdout, usage)
+		return exitOK
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s\n", name, usage)
+		return exitUsage
+	}
+
+	// Only the server logs to stdout; commands keep it for their output
+	var logOutput io.Writer = os.Stderr
+	if name == "serve" {
+		logOutput = os.Stdout
+	}
+	logging.Init(logOutput, logging.NewConfig())
+
+	return cmd(args)
+}
+
+// isHelp reports whether arg asks for help
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// newFlagSet creates a flag set for a subcommand that reports errors
+// instead of exiting
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		if hasFlags(fs) {
+			fmt.Fprintln(os.Stderr, "\noptions:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// hasFlags reports whether any flags are defined on fs
+func hasFlags(fs *flag.FlagSet) bool {
+	found := false
+	fs.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+// parseFlags parses args into fs and returns the exit code to use when
+// parsing failed or help was requested
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// openRepositories opens the configured database, migrating it like the
+// server does, and returns the repositories on top of it
+func openRepositories() (service.URLRepository, service.UserRepository, bool) {
+	dbConn, err := db.InitDB()
+	if err != nil {
+		slog.Error("Failed to initialize database", "error", err)
+		return nil, nil, false
+	}
+	return service.NewGormURLRepository(dbConn), service.NewGormUserRepository(dbConn), true
+}
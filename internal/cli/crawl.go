@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sykell/url-crawler/internal/crawler"
+)
+
+// crawlUsage describes the crawl subcommand
+const crawlUsage = `usage: url-crawler crawl <url>
+
+Crawls a URL once, including its link checks, and prints the result as JSON.
+Nothing is read from or written to the database.`
+
+// runCrawl crawls a single URL and prints the result
+func runCrawl(args []string) int {
+	fs := newFlagSet("crawl", crawlUsage)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	address := fs.Arg(0)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	crawlerService := crawler.NewService(nil, crawler.NewConfig())
+	result, err := crawlerService.Crawl(ctx, address)
+	if err != nil {
+		slog.Error("Crawl failed", "address", address, "error", err)
+		return exitFailure
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		slog.Error("Failed to write result", "error", err)
+		return exitFailure
+	}
+	return exitOK
+}
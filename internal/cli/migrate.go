@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+)
+
+// migrateUsage describes the migrate subcommand
+const migrateUsage = `usage: url-crawler migrate <command>
+
+commands:
+  up            apply all pending migrations
+  down [steps]  revert the latest migration, or the given number of them
+  status        list migrations and when they were applied
+  to <version>  migrate up or down to a version, 0 reverts everything`
+
+// runMigrate runs the migrate subcommand and returns the exit code
+func runMigrate(args []string) int {
+	if len(args) == 0 || isHelp(args[0]) {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	dbConn, err := db.Connect(db.NewConfig())
+	if err != nil {
+		slog.Error("Failed to connect to database", "error", err)
+		return exitFailure
+	}
+	migrator := db.NewMigrator(dbConn)
+	ctx := context.Background()
+
+	var changed int
+	switch args[0] {
+	case "up":
+		changed, err = migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintf(os.Stderr, "invalid number of steps %q\n", args[1])
+				return exitUsage
+			}
+		}
+		changed, err = migrator.Down(ctx, steps)
+	case "to":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return exitUsage
+		}
+		version, parseErr := strconv.ParseUint(args[1], 10, 32)
+		if parseErr != nil {
+			fmt.Fprintf(os.Stderr, "invalid version %q\n", args[1])
+			return exitUsage
+		}
+		changed, err = migrator.To(ctx, uint(version))
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			slog.Error("Failed to load migration status", "error", err)
+			return exitFailure
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+		return exitOK
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return exitUsage
+	}
+
+	if err != nil {
+		slog.Error("Migration failed", "command", args[0], "changed", changed, "error", err)
+		return exitFailure
+	}
+	switch args[0] {
+	case "up":
+		fmt.Printf("Applied %d migrations\n", changed)
+	case "down":
+		fmt.Printf("Reverted %d migrations\n", changed)
+	default:
+		fmt.Printf("Migrated to version %s, %d migrations changed\n", args[1], changed)
+	}
+	return exitOK
+}
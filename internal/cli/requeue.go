@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/sykell/url-crawler/internal/db"
+)
+
+// requeueUsage describes the requeue subcommand
+const requeueUsage = `usage: url-crawler requeue -status <status>
+
+Sets all URLs with the given status back to queued. The status is one of
+running, error, cancelled, done or unchanged. A running server starts
+crawling them within CRAWLER_REFILL_INTERVAL (default 10s), a stopped one
+when it starts. Only requeue running URLs while the server is stopped; it
+requeues them on start by itself.`
+
+// requeueStatuses are the statuses URLs can be requeued from
+var requeueStatuses = map[db.URLStatus]bool{
+	db.StatusRunning:   true,
+	db.StatusError:     true,
+	db.StatusCancelled: true,
+	db.StatusDone:      true,
+	db.StatusUnchanged: true,
+}
+
+// runRequeue requeues URLs by status and returns the exit code
+func runRequeue(args []string) int {
+	fs := newFlagSet("requeue", requeueUsage)
+	status := fs.String("status", "", "status of the URLs to requeue")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 || *status == "" {
+		fs.Usage()
+		return exitUsage
+	}
+	if !requeueStatuses[db.URLStatus(*status)] {
+		fmt.Fprintf(os.Stderr, "invalid status %q\n", *status)
+		return exitUsage
+	}
+
+	urls, _, ok := openRepositories()
+	if !ok {
+		return exitFailure
+	}
+	requeued, err := urls.RequeueByStatus(context.Background(), db.URLStatus(*status))
+	if err != nil {
+		slog.Error("Failed to requeue URLs", "status", *status, "error", err)
+		return exitFailure
+	}
+	fmt.Printf("Requeued %d %s URLs\n", requeued, *status)
+	return exitOK
+}
@@ -0,0 +1,369 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sykell/url-crawler/internal/api"
+	"github.com/sykell/url-crawler/internal/crawler"
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/events"
+	"github.com/sykell/url-crawler/internal/health"
+	"github.com/sykell/url-crawler/internal/logging"
+	"github.com/sykell/url-crawler/internal/metrics"
+	"github.com/sykell/url-crawler/internal/middleware"
+	"github.com/sykell/url-crawler/internal/notify"
+	"github.com/sykell/url-crawler/internal/realtime"
+	"github.com/sykell/url-crawler/internal/service"
+	"github.com/sykell/url-crawler/internal/tracing"
+	"github.com/sykell/url-crawler/internal/webhook"
+)
+
+// serveUsage describes the serve subcommand
+const serveUsage = `usage: url-crawler serve
+
+Runs the API server, crawler and scheduler until interrupted. It is
+configured through environment variables, see .env.example.`
+
+// serverConfig holds the server configuration
+type serverConfig struct {
+	Port            string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	// DrainDelay keeps serving while readiness fails on shutdown, giving
+	// load balancers time to stop routing new requests here
+	DrainDelay time.Duration
+	// QueueSaturation is the queue fill ratio at which readiness fails
+	QueueSaturation float64
+	// EventOutbox persists crawl events so they survive restarts
+	EventOutbox     bool
+	OutboxRetention time.Duration
+	// AdminUsernames, when set, are the only admins; startup grants them
+	// admin privileges and revokes them from everyone else, and signup
+	// refuses these names so only the CLI can create the accounts
+	AdminUsernames []string
+}
+
+// newServerConfig creates the server configuration from environment variables
+func newServerConfig() *serverConfig {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	drainDelay := 5 * time.Second
+	if v, err := time.ParseDuration(os.Getenv("SHUTDOWN_DRAIN_DELAY")); err == nil && v >= 0 {
+		drainDelay = v
+	}
+
+	queueSaturation := 0.9
+	if v, err := strconv.ParseFloat(os.Getenv("HEALTH_QUEUE_SATURATION"), 64); err == nil && v > 0 && v <= 1 {
+		queueSaturation = v
+	}
+
+	outboxRetention := 7 * 24 * time.Hour
+	if v, err := time.ParseDuration(os.Getenv("EVENT_OUTBOX_RETENTION")); err == nil && v > 0 {
+		outboxRetention = v
+	}
+
+	var adminUsernames []string
+	for _, name := range strings.Split(os.Getenv("ADMIN_USERNAMES"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			adminUsernames = append(adminUsernames, name)
+		}
+	}
+
+	return &serverConfig{
+		Port:            port,
+		ReadTimeout:     30 * time.Second,
+		WriteTimeout:    30 * time.Second,
+		IdleTimeout:     60 * time.Second,
+		ShutdownTimeout: 30 * time.Second,
+		DrainDelay:      drainDelay,
+		QueueSaturation: queueSaturation,
+		EventOutbox:     os.Getenv("EVENT_OUTBOX_ENABLED") == "true",
+		OutboxRetention: outboxRetention,
+		AdminUsernames:  adminUsernames,
+	}
+}
+
+// runServe runs the server until SIGINT or SIGTERM and returns the exit code
+func runServe(args []string) int {
+	fs := newFlagSet("serve", serveUsage)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	// Initialize configuration
+	config := newServerConfig()
+
+	// Initialize tracing before anything creates spans
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.NewConfig())
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+
+	// Initialize database
+	slog.Info("Initializing database")
+	dbConn, err := db.InitDB()
+	if err != nil {
+		logging.Fatal("Failed to initialize database", "error", err)
+	}
+	slog.Info("Database initialized successfully")
+	if err := tracing.RegisterGORM(dbConn); err != nil {
+		logging.Fatal("Failed to register database tracing", "error", err)
+	}
+	urlRepo := service.NewGormURLRepository(dbConn)
+	userRepo := service.NewGormUserRepository(dbConn)
+	webhookRepo := service.NewGormWebhookRepository(dbConn)
+	alertRepo := service.NewGormAlertRepository(dbConn)
+
+	// Make the configured users the only admins; without a list admins are
+	// managed with the user CLI
+	if len(config.AdminUsernames) > 0 {
+		if granted, revoked, err := userRepo.SyncAdmins(context.Background(), config.AdminUsernames); err != nil {
+			slog.Error("Failed to sync admin users", "error", err)
+		} else if granted > 0 || revoked > 0 {
+			slog.Info("Synced admin privileges", "granted", granted, "revoked", revoked)
+		}
+	}
+
+	// Initialize event bus and its subscribers
+	eventBus := events.NewBus()
+	if config.EventOutbox {
+		eventBus.EnableOutbox(dbConn)
+	}
+	eventHub := realtime.NewHub(256)
+	eventBus.Subscribe("realtime", eventHub.HandleEvent)
+	eventBus.Subscribe("audit", events.AuditLog,
+		events.TypeURLQueued, events.TypeCrawlStarted, events.TypeCrawlSucceeded, events.TypeCrawlFailed, events.TypeCrawlCancelled,
+		events.TypeLinkBroken)
+	webhookDispatcher := webhook.NewDispatcher(webhookRepo, webhook.NewConfig())
+	eventBus.Subscribe("metrics", metrics.HandleEvent, metrics.CrawlerEvents...)
+	eventBus.SubscribeDurable("webhooks", webhookDispatcher.HandleEvent, webhook.SupportedEvents...)
+	var alerter *notify.Alerter
+	if smtpConfig := notify.NewSMTPConfig(); smtpConfig.Enabled() {
+		mailer, err := notify.NewMailer(smtpConfig)
+		if err != nil {
+			logging.Fatal("Invalid SMTP configuration", "error", err)
+		}
+		alerter = notify.NewAlerter(alertRepo, urlRepo, mailer, notify.NewAlerterConfig())
+		eventBus.SubscribeDurable("alerts", alerter.HandleEvent, notify.AlertEvents...)
+	} else {
+		slog.Info("SMTP_HOST not set, email alerts are disabled")
+	}
+	if err := eventBus.ReplayOutbox(config.OutboxRetention); err != nil {
+		slog.Error("Failed to replay event outbox", "error", err)
+	}
+	metrics.RegisterBus(eventBus)
+
+	// Initialize crawler service
+	slog.Info("Initializing crawler service")
+	crawlerService := crawler.NewService(urlRepo, crawler.NewConfig())
+	crawlerService.SetEventBus(eventBus)
+	if err := crawlerService.Start(); err != nil {
+		logging.Fatal("Failed to start crawler service", "error", err)
+	}
+	slog.Info("Crawler service started successfully")
+
+	// Expose crawler and connection pool state to Prometheus
+	metrics.RegisterCrawler(crawlerService)
+	if sqlDB, err := dbConn.DB(); err == nil {
+		metrics.RegisterDB(sqlDB)
+	} else {
+		slog.Error("Failed to register database metrics", "error", err)
+	}
+
+	// Initialize scheduler for recurring crawls
+	scheduler := crawler.NewScheduler(urlRepo, crawlerService, crawler.NewSchedulerConfig())
+	if err := scheduler.Start(); err != nil {
+		logging.Fatal("Failed to start scheduler", "error", err)
+	}
+
+	// Purge deleted URLs once they can no longer be restored
+	purgerConfig := crawler.NewPurgerConfig()
+	purger := crawler.NewPurger(urlRepo, purgerConfig)
+	if err := purger.Start(); err != nil {
+		logging.Fatal("Failed to start trash purger", "error", err)
+	}
+
+	// Start delivering webhooks, including retries left over from the last run
+	if err := webhookDispatcher.Start(); err != nil {
+		logging.Fatal("Failed to start webhook dispatcher", "error", err)
+	}
+	if alerter != nil {
+		if err := alerter.Start(); err != nil {
+			logging.Fatal("Failed to start email alerter", "error", err)
+		}
+	}
+
+	// Initialize Gin router
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+
+	// Add middleware
+	r.Use(logging.Middleware())
+	r.Use(gin.CustomRecoveryWithWriter(io.Discard, logging.Recovery))
+	r.Use(tracing.Middleware())
+	r.Use(metrics.Middleware())
+	r.Use(middleware.CORS())
+
+	// Health checks: liveness only needs the process, readiness its dependencies
+	healthChecker := health.NewChecker("url-crawler")
+	if sqlDB, err := dbConn.DB(); err == nil {
+		healthChecker.Register("database", health.DatabaseCheck(sqlDB))
+	}
+	healthChecker.Register("crawler", health.CrawlerCheck(crawlerService))
+	healthChecker.Register("queue", health.QueueCheck(crawlerService, config.QueueSaturation))
+	r.GET("/livez", healthChecker.LiveHandler())
+	r.GET("/readyz", healthChecker.ReadyHandler())
+	r.GET("/health", healthChecker.ReadyHandler())
+
+	// Prometheus metrics
+	r.GET("/metrics", metrics.Handler())
+
+	// Authentication endpoints
+	r.POST("/auth/login", api.LoginHandler(userRepo))
+	r.POST("/auth/signup", api.SignupHandler(userRepo, config.AdminUsernames))
+
+	// Real-time crawl events; EventSource can't send headers, so the token may be a query parameter
+	r.GET("/urls/events", middleware.StreamJWTRequired(userRepo), api.EventsHandler(eventHub))
+	r.GET("/ws", middleware.StreamJWTRequired(userRepo), api.WebSocketHandler(urlRepo, crawlerService, eventHub))
+
+	// Protected routes
+	authorized := r.Group("/")
+	authorized.Use(middleware.JWTRequired(userRepo))
+	{
+		authorized.POST("/urls", api.PostURLHandler(urlRepo, crawlerService))
+		authorized.GET("/urls", api.ListURLsHandler(urlRepo))
+		authorized.GET("/urls/trash", api.ListTrashHandler(urlRepo, purgerConfig.Retention))
+		authorized.GET("/urls/:id", api.GetURLHandler(urlRepo))
+		authorized.PATCH("/urls/:id", api.UpdateURLHandler(urlRepo, crawlerService))
+		authorized.DELETE("/urls/:id", api.DeleteURLHandler(urlRepo, crawlerService))
+		authorized.POST("/urls/:id/rerun", api.RerunURLHandler(urlRepo, crawlerService))
+		authorized.GET("/urls/:id/runs", api.ListRunsHandler(urlRepo))
+		authorized.GET("/urls/:id/diff", api.DiffRunsHandler(urlRepo))
+		authorized.POST("/urls/:id/cancel", api.CancelURLHandler(urlRepo, crawlerService))
+		authorized.PUT("/urls/:id/schedule", api.SetScheduleHandler(urlRepo))
+		authorized.DELETE("/urls/:id/schedule", api.DeleteScheduleHandler(urlRepo))
+		authorized.POST("/urls/:id/schedule/pause", api.PauseScheduleHandler(urlRepo, true))
+		authorized.POST("/urls/:id/schedule/resume", api.PauseScheduleHandler(urlRepo, false))
+		authorized.POST("/urls/bulk", api.BulkHandler(urlRepo, crawlerService))
+		authorized.GET("/queue", api.QueueHandler(crawlerService))
+		authorized.POST("/webhooks", api.CreateWebhookHandler(webhookRepo))
+		authorized.GET("/webhooks", api.ListWebhooksHandler(webhookRepo))
+		authorized.GET("/webhooks/:id", api.GetWebhookHandler(webhookRepo))
+		authorized.PATCH("/webhooks/:id", api.UpdateWebhookHandler(webhookRepo))
+		authorized.DELETE("/webhooks/:id", api.DeleteWebhookHandler(webhookRepo))
+		authorized.GET("/webhooks/:id/deliveries", api.ListDeliveriesHandler(webhookRepo))
+		authorized.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", api.RedeliverHandler(webhookRepo, webhookDispatcher))
+		authorized.POST("/alerts", api.CreateAlertRuleHandler(alertRepo, urlRepo))
+		authorized.GET("/alerts", api.ListAlertRulesHandler(alertRepo))
+		authorized.PUT("/alerts/:id", api.UpdateAlertRuleHandler(alertRepo, urlRepo))
+		authorized.DELETE("/alerts/:id", api.DeleteAlertRuleHandler(alertRepo))
+	}
+
+	// Admin routes
+	admin := r.Group("/admin")
+	admin.Use(middleware.JWTRequired(userRepo), middleware.AdminRequired(userRepo))
+	{
+		admin.GET("/crawler", api.CrawlerStatsHandler(crawlerService))
+		admin.POST("/crawler/pause", api.PauseCrawlerHandler(crawlerService, true))
+		admin.POST("/crawler/resume", api.PauseCrawlerHandler(crawlerService, false))
+		admin.PUT("/crawler/workers", api.SetWorkersHandler(crawlerService))
+	}
+
+	// Create HTTP server
+	srv := &http.Server{
+		Addr:         ":" + config.Port,
+		Handler:      r,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+		IdleTimeout:  config.IdleTimeout,
+	}
+
+	// Start server in a goroutine
+	go func() {
+		slog.Info("Starting server", "port", config.Port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logging.Fatal("Failed to start server", "error", err)
+		}
+	}()
+
+	// Wait for interrupt signal to gracefully shutdown the server
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	slog.Info("Shutting down server")
+
+	// Fail readiness first and keep serving while load balancers catch up
+	healthChecker.SetDraining()
+	if config.DrainDelay > 0 {
+		slog.Info("Draining before shutdown", "delay", config.DrainDelay)
+		time.Sleep(config.DrainDelay)
+	}
+
+	// Create shutdown context
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	// Shutdown waits for open event streams, so end them first
+	eventHub.Close()
+
+	// Shutdown server gracefully
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("Server forced to shutdown", "error", err)
+	}
+
+	// Stop scheduling new crawls before the crawler goes away
+	if err := scheduler.Stop(); err != nil {
+		slog.Error("Failed to stop scheduler", "error", err)
+	}
+	if err := purger.Stop(); err != nil {
+		slog.Error("Failed to stop trash purger", "error", err)
+	}
+
+	// Let in-flight crawls finish within CRAWLER_SHUTDOWN_GRACE; interrupted and
+	// still queued URLs are crawled after the restart
+	if err := crawlerService.Stop(); err != nil {
+		slog.Error("Failed to stop crawler service", "error", err)
+	}
+
+	// Let subscribers finish the remaining events
+	eventBus.Close()
+
+	// Undelivered webhooks stay pending and are retried after restart
+	if err := webhookDispatcher.Stop(); err != nil {
+		slog.Error("Failed to stop webhook dispatcher", "error", err)
+	}
+	if alerter != nil {
+		if err := alerter.Stop(); err != nil {
+			slog.Error("Failed to stop email alerter", "error", err)
+		}
+	}
+
+	// Flush spans of the last crawls; the shutdown timeout may be used up by now
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+
+	slog.Info("Server exited")
+	return exitOK
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+// urlUsage describes the url subcommand
+const urlUsage = `usage: url-crawler url <command>
+
+commands:
+  import -user <username> <file>  queue the URLs in file, one per line, for a user
+
+Blank lines and lines starting with # are ignored, and URLs the user already
+has are skipped. Use - as the file to read from stdin. A running server
+starts crawling imported URLs within CRAWLER_REFILL_INTERVAL (default 10s),
+a stopped one when it starts.`
+
+// runURL runs the url subcommand and returns the exit code
+func runURL(args []string) int {
+	if len(args) == 0 || isHelp(args[0]) {
+		fmt.Fprintln(os.Stderr, urlUsage)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	switch args[0] {
+	case "import":
+		return runURLImport(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown url command %q\n\n%s\n", args[0], urlUsage)
+		return exitUsage
+	}
+}
+
+// runURLImport creates queued URLs for a user from a file
+func runURLImport(args []string) int {
+	fs := newFlagSet("url import", "usage: url-crawler url import -user <username> <file>")
+	username := fs.String("user", "", "owner of the imported URLs")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *username == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	var input io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open %s: %v\n", path, err)
+			return exitFailure
+		}
+		defer file.Close()
+		input = file
+	}
+
+	urls, users, ok := openRepositories()
+	if !ok {
+		return exitFailure
+	}
+	ctx := context.Background()
+
+	user, err := users.GetByUsername(ctx, *username)
+	if err != nil {
+		if err == service.ErrNotFound {
+			fmt.Fprintf(os.Stderr, "user %q not found\n", *username)
+			return exitFailure
+		}
+		slog.Error("Failed to look up user", "username", *username, "error", err)
+		return exitFailure
+	}
+
+	var imported, existing, invalid int
+	scanner := bufio.NewScanner(input)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		address := strings.TrimSpace(scanner.Text())
+		if address == "" || strings.HasPrefix(address, "#") {
+			continue
+		}
+		if !validAddress(address) {
+			fmt.Fprintf(os.Stderr, "line %d: invalid URL %q\n", lineNo, address)
+			invalid++
+			continue
+		}
+
+		if _, err := urls.GetByAddress(ctx, user.ID, address); err == nil {
+			existing++
+			continue
+		} else if err != service.ErrNotFound {
+			slog.Error("Failed to check existing URL", "address", address, "error", err)
+			return exitFailure
+		}
+		if _, err := urls.Create(ctx, user.ID, address); err != nil {
+			slog.Error("Failed to create URL", "address", address, "error", err)
+			return exitFailure
+		}
+		imported++
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Error("Failed to read URL list", "error", err)
+		return exitFailure
+	}
+
+	fmt.Printf("Imported %d URLs, skipped %d existing and %d invalid\n", imported, existing, invalid)
+	if invalid > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// validAddress reports whether address is an absolute http or https URL
+func validAddress(address string) bool {
+	parsed, err := url.ParseRequestURI(address)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
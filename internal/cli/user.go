@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+// userUsage describes the user subcommand
+const userUsage = `usage: url-crawler user <command>
+
+commands:
+  create [-admin] [-password <password>] <username>  create a user
+  list                                               list all users
+  disable <username>                                 stop a user from logging in
+  enable <username>                                  allow a disabled user to log in again
+  reset-password [-password <password>] <username>   set a new password
+
+Without -password the password is read from the first line of stdin.
+When ADMIN_USERNAMES is set, the server revokes admin privileges from
+users it doesn't list on startup, -admin included. Disabling a user or
+resetting their password revokes the tokens issued to them.`
+
+// runUser runs the user subcommand and returns the exit code
+func runUser(args []string) int {
+	if len(args) == 0 || isHelp(args[0]) {
+		fmt.Fprintln(os.Stderr, userUsage)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	switch args[0] {
+	case "create":
+		return runUserCreate(args[1:])
+	case "list":
+		return runUserList(args[1:])
+	case "disable":
+		return runUserSetDisabled(args[1:], true)
+	case "enable":
+		return runUserSetDisabled(args[1:], false)
+	case "reset-password":
+		return runUserResetPassword(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown user command %q\n\n%s\n", args[0], userUsage)
+		return exitUsage
+	}
+}
+
+// runUserCreate creates a user, optionally with admin privileges
+func runUserCreate(args []string) int {
+	fs := newFlagSet("user create", "usage: url-crawler user create [-admin] [-password <password>] <username>")
+	admin := fs.Bool("admin", false, "grant admin privileges")
+	password := fs.String("password", "", "password; read from stdin when empty")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	username, ok := usernameArg(fs.Args())
+	if !ok {
+		fs.Usage()
+		return exitUsage
+	}
+	hashed, ok := readPassword(*password, os.Stdin)
+	if !ok {
+		return exitUsage
+	}
+
+	_, users, ok := openRepositories()
+	if !ok {
+		return exitFailure
+	}
+	ctx := context.Background()
+
+	if _, err := users.GetByUsername(ctx, username); err == nil {
+		fmt.Fprintf(os.Stderr, "user %q already exists\n", username)
+		return exitFailure
+	} else if err != service.ErrNotFound {
+		slog.Error("Failed to look up user", "username", username, "error", err)
+		return exitFailure
+	}
+	if err := users.Create(ctx, username, hashed); err != nil {
+		slog.Error("Failed to create user", "username", username, "error", err)
+		return exitFailure
+	}
+	if *admin {
+		if _, err := users.PromoteAdmins(ctx, []string{username}); err != nil {
+			slog.Error("Failed to grant admin privileges", "username", username, "error", err)
+			return exitFailure
+		}
+	}
+
+	user, err := users.GetByUsername(ctx, username)
+	if err != nil {
+		slog.Error("Failed to fetch created user", "username", username, "error", err)
+		return exitFailure
+	}
+	fmt.Printf("Created user %s with ID %d\n", user.Username, user.ID)
+	return exitOK
+}
+
+// runUserList prints all users as a table
+func runUserList(args []string) int {
+	fs := newFlagSet("user list", "usage: url-crawler user list")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	_, users, ok := openRepositories()
+	if !ok {
+		return exitFailure
+	}
+	list, err := users.List(context.Background())
+	if err != nil {
+		slog.Error("Failed to list users", "error", err)
+		return exitFailure
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tADMIN\tDISABLED AT\tCREATED AT")
+	for _, user := range list {
+		disabledAt := "-"
+		if user.DisabledAt != nil {
+			disabledAt = user.DisabledAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%t\t%s\t%s\n", user.ID, user.Username, user.IsAdmin, disabledAt, user.CreatedAt.UTC().Format(time.RFC3339))
+	}
+	w.Flush()
+	return exitOK
+}
+
+// runUserSetDisabled disables or re-enables a user's login
+func runUserSetDisabled(args []string, disabled bool) int {
+	name := "enable"
+	if disabled {
+		name = "disable"
+	}
+	fs := newFlagSet("user "+name, "usage: url-crawler user "+name+" <username>")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	username, ok := usernameArg(fs.Args())
+	if !ok {
+		fs.Usage()
+		return exitUsage
+	}
+
+	_, users, ok := openRepositories()
+	if !ok {
+		return exitFailure
+	}
+	if err := users.SetDisabled(context.Background(), username, disabled); err != nil {
+		if err == service.ErrNotFound {
+			fmt.Fprintf(os.Stderr, "user %q not found\n", username)
+			return exitFailure
+		}
+		slog.Error("Failed to update user", "username", username, "error", err)
+		return exitFailure
+	}
+	fmt.Printf("User %s %sd\n", username, name)
+	return exitOK
+}
+
+// runUserResetPassword replaces a user's password
+func runUserResetPassword(args []string) int {
+	fs := newFlagSet("user reset-password", "usage: url-crawler user reset-password [-password <password>] <username>")
+	password := fs.String("password", "", "new password; read from stdin when empty")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	username, ok := usernameArg(fs.Args())
+	if !ok {
+		fs.Usage()
+		return exitUsage
+	}
+	hashed, ok := readPassword(*password, os.Stdin)
+	if !ok {
+		return exitUsage
+	}
+
+	_, users, ok := openRepositories()
+	if !ok {
+		return exitFailure
+	}
+	if err := users.UpdatePassword(context.Background(), username, hashed); err != nil {
+		if err == service.ErrNotFound {
+			fmt.Fprintf(os.Stderr, "user %q not found\n", username)
+			return exitFailure
+		}
+		slog.Error("Failed to reset password", "username", username, "error", err)
+		return exitFailure
+	}
+	fmt.Printf("Password of %s reset\n", username)
+	return exitOK
+}
+
+// usernameArg returns the single username argument, validated like signup
+func usernameArg(args []string) (string, bool) {
+	if len(args) != 1 {
+		return "", false
+	}
+	username := strings.TrimSpace(args[0])
+	if len(username) < 3 || len(username) > 100 {
+		fmt.Fprintln(os.Stderr, "username must be 3 to 100 characters long")
+		return "", false
+	}
+	return username, true
+}
+
+// readPassword validates the password, reading it from the first line of r
+// when none was given, and returns its hash
+func readPassword(password string, r io.Reader) (string, bool) {
+	if password == "" {
+		line, err := bufio.NewReader(r).ReadString('\n')
+		if err != nil && err != io.EOF {
+			slog.Error("Failed to read password", "error", err)
+			return "", false
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if len(password) < service.MinPasswordLength {
+		fmt.Fprintf(os.Stderr, "password must be at least %d characters long\n", service.MinPasswordLength)
+		return "", false
+	}
+
+	hashed, err := service.HashPassword(password)
+	if err != nil {
+		slog.Error("Failed to hash password", "error", err)
+		return "", false
+	}
+	return hashed, true
+}
@@ -1,70 +1,182 @@
 package crawler
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
-	"gorm.io/gorm"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/events"
+	"github.com/sykell/url-crawler/internal/logging"
 	"github.com/sykell/url-crawler/internal/service"
 )
 
+// ErrCrawlCancelled is recorded when a crawl is cancelled while running
+var ErrCrawlCancelled = errors.New("crawl cancelled")
+
+// ErrCrawlInterrupted is recorded when shutdown interrupts a crawl; the URL
+// is queued again and crawled after the restart
+var ErrCrawlInterrupted = errors.New("crawl interrupted by shutdown")
+
+// tracer records spans for crawl runs and link checks
+var tracer = otel.Tracer("github.com/sykell/url-crawler/internal/crawler")
+
+// maxBodySize caps how much of a response body is read and analyzed
+const maxBodySize = 10 << 20
+
 // Service represents the crawler service
 type Service struct {
-	db       *gorm.DB
-	queue    chan uint
-	workers  int
-	timeout  time.Duration
-	ctx      context.Context
-	cancel   context.CancelFunc
-	wg       sync.WaitGroup
-	mu       sync.RWMutex
-	isRunning bool
+	urls            service.URLRepository
+	queue           *fairQueue
+	workers         int
+	timeout         time.Duration
+	ctx             context.Context
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+	mu              sync.RWMutex
+	isRunning       bool
+	bus             *events.Bus
+	inflightMu      sync.Mutex
+	inflight        map[uint]*inflightCrawl
+	workerStops     []context.CancelFunc
+	nextWorkerID    int
+	startedAt       time.Time
+	throughput      *throughput
+	linkChecks      atomic.Uint64
+	maxRuns         int
+	changeThreshold int
+	shutdownGrace   time.Duration
+	refillInterval  time.Duration
+	stopRefill      context.CancelFunc
+	refillDone      chan struct{}
 }
 
 // Config holds crawler configuration
 type Config struct {
-	Workers     int
-	QueueSize   int
-	Timeout     time.Duration
-	MaxRetries  int
+	Workers    int
+	QueueSize  int
+	Timeout    time.Duration
+	MaxRetries int
+	// MaxRunsPerURL is the number of crawl runs kept per URL (0 keeps all)
+	MaxRunsPerURL int
+	// ChangeThreshold is the simhash distance above which main content
+	// counts as changed; smaller differences are treated as noise
+	ChangeThreshold int
+	// Relative share of workers each priority gets while others are waiting
+	InteractiveWeight int
+	ScheduledWeight   int
+	BulkWeight        int
+	// ShutdownGrace is how long Stop waits for in-flight crawls before
+	// interrupting them
+	ShutdownGrace time.Duration
+	// RefillInterval is how often queued URLs that didn't fit in the queue
+	// are loaded from the database
+	RefillInterval time.Duration
 }
 
 // DefaultConfig returns default crawler configuration
 func DefaultConfig() *Config {
 	return &Config{
-		Workers:    5,
-		QueueSize:  100,
-		Timeout:    30 * time.Second,
-		MaxRetries: 3,
+		Workers:           5,
+		QueueSize:         100,
+		Timeout:           30 * time.Second,
+		MaxRetries:        3,
+		MaxRunsPerURL:     50,
+		ChangeThreshold:   3,
+		InteractiveWeight: 6,
+		ScheduledWeight:   3,
+		BulkWeight:        1,
+		ShutdownGrace:     20 * time.Second,
+		RefillInterval:    10 * time.Second,
+	}
+}
+
+// NewConfig creates a crawler configuration from environment variables,
+// falling back to the defaults for unset or invalid values
+func NewConfig() *Config {
+	config := DefaultConfig()
+
+	if v, err := strconv.Atoi(os.Getenv("CRAWLER_WORKERS")); err == nil && v > 0 {
+		config.Workers = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CRAWLER_QUEUE_SIZE")); err == nil && v > 0 {
+		config.QueueSize = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("CRAWLER_TIMEOUT")); err == nil && v > 0 {
+		config.Timeout = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CRAWLER_MAX_RETRIES")); err == nil && v >= 0 {
+		config.MaxRetries = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CRAWLER_MAX_RUNS_PER_URL")); err == nil && v >= 0 {
+		config.MaxRunsPerURL = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CRAWLER_CHANGE_THRESHOLD")); err == nil && v >= 0 {
+		config.ChangeThreshold = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CRAWLER_WEIGHT_INTERACTIVE")); err == nil && v > 0 {
+		config.InteractiveWeight = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CRAWLER_WEIGHT_SCHEDULED")); err == nil && v > 0 {
+		config.ScheduledWeight = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CRAWLER_WEIGHT_BULK")); err == nil && v > 0 {
+		config.BulkWeight = v
 	}
+	if v, err := time.ParseDuration(os.Getenv("CRAWLER_SHUTDOWN_GRACE")); err == nil && v >= 0 {
+		config.ShutdownGrace = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("CRAWLER_REFILL_INTERVAL")); err == nil && v > 0 {
+		config.RefillInterval = v
+	}
+
+	return config
 }
 
 // NewService creates a new crawler service
-func NewService(db *gorm.DB, config *Config) *Service {
+func NewService(urls service.URLRepository, config *Config) *Service {
 	if config == nil {
 		config = DefaultConfig()
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
+
+	weights := [numPriorities]int{}
+	weights[PriorityInteractive] = config.InteractiveWeight
+	weights[PriorityScheduled] = config.ScheduledWeight
+	weights[PriorityBulk] = config.BulkWeight
 	
 	return &Service{
-		db:      db,
-		queue:   make(chan uint, config.QueueSize),
-		workers: config.Workers,
-		timeout: config.Timeout,
-		ctx:     ctx,
-		cancel:  cancel,
+		urls:            urls,
+		queue:           newFairQueue(config.QueueSize, weights),
+		workers:         config.Workers,
+		timeout:         config.Timeout,
+		ctx:             ctx,
+		cancel:          cancel,
+		maxRuns:         config.MaxRunsPerURL,
+		changeThreshold: config.ChangeThreshold,
+		shutdownGrace:   config.ShutdownGrace,
+		refillInterval:  config.RefillInterval,
+		inflight:        make(map[uint]*inflightCrawl),
+		throughput:      newThroughput(),
 	}
 }
 
@@ -78,123 +190,543 @@ func (s *Service) Start() error {
 	}
 
 	s.isRunning = true
+	s.startedAt = time.Now()
 	
 	// Start worker goroutines
 	for i := 0; i < s.workers; i++ {
-		s.wg.Add(1)
-		go s.worker(i)
+		s.startWorker()
 	}
 
-	log.Printf("Crawler service started with %d workers", s.workers)
+	// Pick up the work the previous process left behind, then keep loading
+	// queued URLs that don't fit in the queue yet as it drains
+	s.recoverQueued()
+	refillCtx, stopRefill := context.WithCancel(s.ctx)
+	s.stopRefill = stopRefill
+	s.refillDone = make(chan struct{})
+	go s.refill(refillCtx)
+
+	slog.Info("Crawler service started", "workers", s.workers)
 	return nil
 }
 
-// Stop stops the crawler service gracefully
+// recoverQueued queues URLs that were queued or running when the service
+// last stopped; callers hold s.mu
+func (s *Service) recoverQueued() {
+	reset, err := s.urls.RequeueByStatus(s.ctx, db.StatusRunning)
+	if err != nil {
+		slog.Error("Failed to requeue interrupted URLs", "error", err)
+	} else if reset > 0 {
+		slog.Warn("Requeued URLs left running by an unclean shutdown", "count", reset)
+	}
+
+	if loaded := s.loadQueued(s.ctx); loaded > 0 {
+		slog.Info("Recovered queued URLs", "count", loaded)
+	}
+}
+
+// refill loads queued URLs from the database whenever the queue has room,
+// until ctx is cancelled. This covers URLs beyond the queue's capacity and
+// URLs queued without going through the service, e.g. by the CLI.
+func (s *Service) refill(ctx context.Context) {
+	defer close(s.refillDone)
+
+	ticker := time.NewTicker(s.refillInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if loaded := s.loadQueued(ctx); loaded > 0 {
+				slog.Debug("Loaded queued URLs into the crawl queue", "count", loaded)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// loadQueued pushes queued URLs from the database with the priority they
+// were queued with, highest priority and then oldest first, until the queue
+// is full and returns how many were added
+func (s *Service) loadQueued(ctx context.Context) int {
+	capacity := s.queue.Capacity()
+	if s.queue.Len() >= capacity {
+		return 0
+	}
+
+	// URLs already waiting in the queue are still queued in the database,
+	// so look at enough of them to find the ones that aren't
+	urls, err := s.urls.ListQueued(ctx, capacity)
+	if err != nil {
+		slog.Error("Failed to list queued URLs", "error", err)
+		return 0
+	}
+
+	loaded := 0
+	for _, url := range urls {
+		if s.queue.Contains(url.ID) {
+			continue
+		}
+		if err := s.queue.Push(QueueItem{URLID: url.ID, UserID: url.UserID, Priority: Priority(url.QueuePriority)}); err != nil {
+			if err != errQueueFull {
+				slog.Error("Failed to load queued URL", "url_id", url.ID, "error", err)
+			}
+			break
+		}
+		loaded++
+	}
+	return loaded
+}
+
+// Stop stops the crawler service gracefully. New URLs are rejected at once
+// and idle workers exit; crawls in progress get the shutdown grace period
+// to finish before they are interrupted and their URLs queued again. URLs
+// still waiting in the queue stay queued in the database and are picked up
+// on the next start.
 func (s *Service) Stop() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	
 	if !s.isRunning {
+		s.mu.Unlock()
 		return nil
 	}
-
 	s.isRunning = false
+	s.queue.Close()
+	s.stopRefill()
+	s.mu.Unlock()
+	<-s.refillDone
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(s.shutdownGrace)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		slog.Warn("Shutdown grace period expired, interrupting in-flight crawls", "grace", s.shutdownGrace)
+		s.cancel()
+		<-done
+	}
 	s.cancel()
-	close(s.queue)
-	
-	// Wait for all workers to finish
-	s.wg.Wait()
-	
-	log.Println("Crawler service stopped")
+
+	slog.Info("Crawler service stopped", "left_queued", s.queue.Len())
+	return nil
+}
+
+// NotifyNewURL adds a URL to the processing queue with interactive priority
+func (s *Service) NotifyNewURL(ctx context.Context, id uint) error {
+	return s.Enqueue(ctx, id, PriorityInteractive)
+}
+
+// Enqueue adds a URL to the processing queue with the given priority. The
+// crawl is traced as part of the span in ctx, if any.
+func (s *Service) Enqueue(ctx context.Context, id uint, priority Priority) error {
+	// Queueing must not fail because the submitting request went away
+	url, err := s.urls.GetByID(context.WithoutCancel(ctx), id)
+	if err != nil {
+		return fmt.Errorf("failed to load URL %d: %w", id, err)
+	}
+
+	// Record the priority first so the URL keeps it if it's loaded back
+	// from the database, because the queue is full or the service restarts
+	if err := s.urls.SetQueuePriority(context.WithoutCancel(ctx), id, int(priority)); err != nil {
+		slog.Warn("Failed to record queue priority", "url_id", id, "priority", priority, "error", err)
+	}
+
+	job := QueueItem{
+		URLID:       url.ID,
+		UserID:      url.UserID,
+		Priority:    priority,
+		SpanContext: trace.SpanContextFromContext(ctx),
+		RequestID:   logging.RequestID(ctx),
+	}
+	if err := s.enqueue(job); err != nil {
+		return err
+	}
+
+	s.publishQueued(url)
 	return nil
 }
 
-// NotifyNewURL adds a URL to the processing queue
-func (s *Service) NotifyNewURL(id uint) error {
+// enqueue pushes a job onto the queue without blocking
+func (s *Service) enqueue(job QueueItem) error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	
+
 	if !s.isRunning {
-		return fmt.Errorf("crawler service is not running")
+		return errQueueClosed
 	}
 
-	select {
-	case s.queue <- id:
-		return nil
-	default:
-		return fmt.Errorf("queue is full")
-	}
+	return s.queue.Push(job)
+}
+
+// QueueLength returns the number of URLs waiting for a worker
+func (s *Service) QueueLength() int {
+	return s.queue.Len()
+}
+
+// QueueCapacity returns the maximum number of queued URLs
+func (s *Service) QueueCapacity() int {
+	return s.queue.Capacity()
+}
+
+// IsRunning reports whether the crawler's workers are running
+func (s *Service) IsRunning() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.isRunning
+}
+
+// QueueCounts returns the number of queued URLs per priority
+func (s *Service) QueueCounts() map[Priority]int {
+	return s.queue.Counts()
+}
+
+// QueuedForUser returns a user's queued URLs in priority order
+func (s *Service) QueuedForUser(userID uint) []QueueItem {
+	return s.queue.Items(func(item *QueueItem) bool {
+		return item.UserID == userID
+	})
+}
+
+// startWorker launches a worker goroutine; callers hold s.mu
+func (s *Service) startWorker() {
+	ctx, stop := context.WithCancel(s.ctx)
+	s.workerStops = append(s.workerStops, stop)
+
+	id := s.nextWorkerID
+	s.nextWorkerID++
+
+	s.wg.Add(1)
+	go s.worker(ctx, id)
 }
 
-// worker processes URLs from the queue
-func (s *Service) worker(id int) {
+// worker processes URLs from the queue until ctx is cancelled, either on
+// shutdown or when the pool shrinks. A crawl in progress is always finished.
+func (s *Service) worker(ctx context.Context, id int) {
 	defer s.wg.Done()
 	
-	log.Printf("Worker %d started", id)
+	slog.Debug("Worker started", "worker", id)
 	
 	for {
-		select {
-		case urlID, ok := <-s.queue:
-			if !ok {
-				log.Printf("Worker %d shutting down", id)
-				return
-			}
-			s.processURL(urlID)
-		case <-s.ctx.Done():
-			log.Printf("Worker %d shutting down", id)
+		job, ok := s.queue.Pop(ctx)
+		if !ok {
+			slog.Debug("Worker shutting down", "worker", id)
 			return
 		}
+		s.processURL(id, job)
 	}
 }
 
-// processURL processes a single URL
-func (s *Service) processURL(id uint) {
-	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+// processURL processes a single queued URL on the given worker
+func (s *Service) processURL(worker int, job *QueueItem) {
+	id := job.URLID
+
+	// The run continues the trace and carries the request ID of the request
+	// that queued it. Database writes use runCtx, which is never cancelled,
+	// so a cancelled crawl is still recorded.
+	runCtx := logging.WithRequestID(trace.ContextWithSpanContext(context.Background(), job.SpanContext), job.RequestID)
+	runCtx, span := tracer.Start(runCtx, "crawler.process_url",
+		trace.WithAttributes(
+			attribute.Int("url.id", int(id)),
+			attribute.Int("crawler.worker", worker),
+			attribute.String("crawler.priority", job.Priority.String()),
+			attribute.Int64("crawler.queue_wait_ms", time.Since(job.EnqueuedAt).Milliseconds()),
+		),
+	)
+	defer span.End()
+	logger := slog.With("url_id", id, "worker", worker)
+
+	// The fetch is bounded by s.timeout; the run as a whole only ends on
+	// completion, shutdown or cancellation
+	ctx, cancel := context.WithCancel(trace.ContextWithSpan(s.ctx, span))
 	defer cancel()
+	s.trackInflight(id, worker, cancel)
+	defer s.untrackInflight(id)
 
 	// Get URL from database
-	url, err := service.GetURLByID(s.db, id)
+	url, err := s.urls.GetByID(runCtx, id)
 	if err != nil {
-		log.Printf("Failed to get URL %d: %v", id, err)
+		if err == service.ErrNotFound {
+			logger.InfoContext(runCtx, "URL was deleted, skipping")
+			return
+		}
+		logger.ErrorContext(runCtx, "Failed to get URL", "error", err)
 		return
 	}
 
 	// Check if URL is still queued
 	if url.Status != db.StatusQueued {
-		log.Printf("URL %d is not in queued status: %s", id, url.Status)
+		logger.InfoContext(runCtx, "URL is not in queued status", "status", url.Status)
+		return
+	}
+
+	// Update status to running, unless it was cancelled in the meantime
+	claimed, err := s.urls.ClaimQueued(runCtx, id)
+	if err != nil {
+		logger.ErrorContext(runCtx, "Failed to update URL status to running", "error", err)
+		return
+	}
+	if !claimed {
+		logger.InfoContext(runCtx, "URL left queued status before it could be processed")
 		return
 	}
 
-	// Update status to running
-	if err := service.UpdateURLStatus(s.db, id, db.StatusRunning, ""); err != nil {
-		log.Printf("Failed to update URL %d status to running: %v", id, err)
+	// Record a new crawl run so previous results are kept
+	run, err := s.urls.CreateRun(runCtx, id)
+	if err != nil {
+		logger.ErrorContext(runCtx, "Failed to create crawl run", "error", err)
+		span.SetStatus(codes.Error, err.Error())
+		if updateErr := s.urls.UpdateStatus(runCtx, id, db.StatusError, err.Error()); updateErr != nil {
+			logger.ErrorContext(runCtx, "Failed to update URL error status", "error", updateErr)
+		}
+		s.throughput.record(db.StatusError)
+		s.publishFailed(url, nil, err)
 		return
 	}
+	defer s.pruneRuns(runCtx, id)
+	span.SetAttributes(attribute.Int("crawl_run.id", int(run.ID)))
+	logger = logger.With("run_id", run.ID, "address", url.Address)
+	s.publishStarted(url, run)
+
+	// Load the previous run with results; it is the baseline for change
+	// detection and allows a conditional request
+	previous, err := s.urls.PreviousRun(runCtx, id, run.ID)
+	if err != nil {
+		if err != service.ErrNotFound {
+			logger.ErrorContext(runCtx, "Failed to load previous run", "error", err)
+		}
+		previous = nil
+	}
+
+	opts := crawlOptions{OnProgress: s.progressReporter(url, run)}
+	if previous != nil {
+		opts.ETag = url.ETag
+		opts.LastModified = url.LastModified
+	}
 
 	// Crawl the URL
-	result, err := s.crawlWithContext(ctx, url.Address)
+	result, err := s.crawlWithContext(ctx, url.Address, opts)
 	if err != nil {
-		log.Printf("Failed to crawl URL %d (%s): %v", id, url.Address, err)
-		if updateErr := service.UpdateURLStatus(s.db, id, db.StatusError, err.Error()); updateErr != nil {
-			log.Printf("Failed to update URL %d error status: %v", id, updateErr)
+		if ctx.Err() != nil && s.ctx.Err() != nil {
+			logger.WarnContext(runCtx, "Crawl interrupted by shutdown, URL requeued")
+			s.interruptRun(runCtx, url, run)
+			return
+		}
+		if ctx.Err() != nil {
+			logger.InfoContext(runCtx, "Crawl cancelled")
+			s.cancelRun(runCtx, url, run)
+			return
+		}
+		logger.WarnContext(runCtx, "Failed to crawl URL", "error", err, "error_class", ClassifyError(err))
+		s.failRun(runCtx, url, run, err)
+		return
+	}
+
+	// The server confirmed nothing changed since the previous run
+	if result.NotModified {
+		if err := s.updateURLUnchanged(runCtx, run, previous); err != nil {
+			logger.ErrorContext(runCtx, "Failed to record unchanged run", "error", err)
+			s.failRun(runCtx, url, run, err)
+			return
 		}
+		s.throughput.record(db.StatusUnchanged)
+		s.publishSucceeded(url, run, result, previous.BrokenLinks)
+		logger.InfoContext(runCtx, "URL not modified since previous run", "previous_run_id", previous.ID)
 		return
 	}
 
+	// Compare fingerprints with the previous run
+	result.ContentChanged = s.detectContentChange(previous, result)
+
 	// Update URL with results
-	if err := s.updateURLWithResults(id, result); err != nil {
-		log.Printf("Failed to update URL %d with results: %v", id, err)
-		if updateErr := service.UpdateURLStatus(s.db, id, db.StatusError, err.Error()); updateErr != nil {
-			log.Printf("Failed to update URL %d error status: %v", id, updateErr)
+	if err := s.updateURLWithResults(runCtx, run, result); err != nil {
+		logger.ErrorContext(runCtx, "Failed to update URL with results", "error", err)
+		s.failRun(runCtx, url, run, err)
+		return
+	}
+	s.throughput.record(db.StatusDone)
+	span.SetAttributes(attribute.Int("crawl.broken_links", len(result.BrokenList)))
+	s.publishSucceeded(url, run, result, len(result.BrokenList))
+
+	if result.ContentChanged {
+		logger.InfoContext(runCtx, "Content changed")
+	}
+	logger.InfoContext(runCtx, "Successfully processed URL", "broken_links", len(result.BrokenList))
+}
+
+// CancelURL cancels the crawl of a URL. A queued URL is marked cancelled so
+// workers skip it; a running crawl has its context cancelled, which aborts
+// the fetch and link checks and records the run as cancelled. It reports
+// whether there was anything to cancel.
+func (s *Service) CancelURL(id uint) (bool, error) {
+	cancelled, err := s.urls.CancelQueued(context.Background(), id)
+	if err != nil {
+		return false, err
+	}
+	if cancelled {
+		s.queue.Remove(id)
+		s.publishCancelled(id, nil)
+		return true, nil
+	}
+
+	// Workers register a crawl before claiming it, so a URL that is no
+	// longer queued is either in flight here or not being crawled at all
+	s.inflightMu.Lock()
+	defer s.inflightMu.Unlock()
+
+	crawl, ok := s.inflight[id]
+	if ok {
+		crawl.cancel()
+	}
+	return ok, nil
+}
+
+// Discard drops deleted URLs from the queue and aborts their running crawls.
+// The URLs are already cancelled in the database, so no events are published.
+func (s *Service) Discard(ids []uint) {
+	s.inflightMu.Lock()
+	defer s.inflightMu.Unlock()
+
+	for _, id := range ids {
+		s.queue.Remove(id)
+		if crawl, ok := s.inflight[id]; ok {
+			crawl.cancel()
 		}
+	}
+}
+
+// inflightCrawl describes a crawl being processed by a worker
+type inflightCrawl struct {
+	worker    int
+	startedAt time.Time
+	cancel    context.CancelFunc
+}
+
+// trackInflight registers a running crawl and its cancel function
+func (s *Service) trackInflight(id uint, worker int, cancel context.CancelFunc) {
+	s.inflightMu.Lock()
+	defer s.inflightMu.Unlock()
+	s.inflight[id] = &inflightCrawl{worker: worker, startedAt: time.Now(), cancel: cancel}
+}
+
+// untrackInflight removes a finished crawl from the in-flight registry
+func (s *Service) untrackInflight(id uint) {
+	s.inflightMu.Lock()
+	defer s.inflightMu.Unlock()
+	delete(s.inflight, id)
+}
+
+// failRun records a failed run on the URL and publishes the error status
+func (s *Service) failRun(ctx context.Context, url *db.URL, run *db.CrawlRun, err error) {
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	if updateErr := s.updateURLWithError(ctx, run, err.Error()); updateErr != nil {
+		slog.ErrorContext(ctx, "Failed to update URL error status", "url_id", url.ID, "error", updateErr)
+	}
+	s.throughput.record(db.StatusError)
+	s.publishFailed(url, run, err)
+}
+
+// cancelRun records a cancelled run on the URL and publishes the cancellation
+func (s *Service) cancelRun(ctx context.Context, url *db.URL, run *db.CrawlRun) {
+	trace.SpanFromContext(ctx).AddEvent("crawl cancelled")
+	if err := s.updateURLCancelled(ctx, run); err != nil {
+		slog.ErrorContext(ctx, "Failed to update URL cancelled status", "url_id", url.ID, "error", err)
+	}
+	s.throughput.record(db.StatusCancelled)
+	s.publishCancelled(url.ID, run)
+}
+
+// interruptRun records a run interrupted by shutdown and queues its URL again
+func (s *Service) interruptRun(ctx context.Context, url *db.URL, run *db.CrawlRun) {
+	trace.SpanFromContext(ctx).AddEvent("crawl interrupted")
+	if err := s.updateURLInterrupted(ctx, run); err != nil {
+		slog.ErrorContext(ctx, "Failed to requeue interrupted URL", "url_id", url.ID, "error", err)
+		return
+	}
+	s.publishQueued(url)
+}
+
+// detectContentChange reports whether the main content differs meaningfully
+// from the previous run. Markup-only changes keep the text hash stable, and
+// small text edits stay within the simhash threshold.
+func (s *Service) detectContentChange(previous *db.CrawlRun, result *CrawlResult) bool {
+	if previous == nil || previous.TextHash == "" || previous.TextHash == result.TextHash {
+		return false // Nothing to compare with, or identical text
+	}
+
+	distance, err := simhashDistance(previous.TextSimhash, result.TextSimhash)
+	if err != nil {
+		slog.Error("Failed to compare fingerprints", "url_id", previous.URLID, "error", err)
+		return true // The exact hash differs, so err on the side of reporting
+	}
+	return distance > s.changeThreshold
+}
+
+// pruneRuns applies the crawl history retention limit to a URL
+func (s *Service) pruneRuns(ctx context.Context, id uint) {
+	deleted, err := s.urls.PruneRuns(ctx, id, s.maxRuns)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to prune crawl runs", "url_id", id, "error", err)
 		return
 	}
+	if deleted > 0 {
+		slog.InfoContext(ctx, "Pruned old crawl runs", "url_id", id, "count", deleted)
+	}
+}
+
+// crawlOptions tunes a single crawl
+type crawlOptions struct {
+	// ETag and LastModified are validators for a conditional request
+	ETag         string
+	LastModified string
+	// OnProgress is called as links are checked, if set
+	OnProgress func(checked, total int)
+	// SkipLinkChecks counts links without requesting them, so no links are
+	// reported broken
+	SkipLinkChecks bool
+}
+
+// Crawl fetches and analyzes a URL once without storing the result
+func (s *Service) Crawl(ctx context.Context, address string) (*CrawlResult, error) {
+	return s.crawlWithContext(ctx, address, crawlOptions{})
+}
+
+// Analyze analyzes an HTML document as if it had been fetched from
+// baseAddress, optionally without checking its links
+func (s *Service) Analyze(ctx context.Context, r io.Reader, baseAddress string, skipLinkChecks bool) (*CrawlResult, error) {
+	body, err := io.ReadAll(io.LimitReader(r, maxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	return s.analyzeBody(ctx, body, baseAddress, crawlOptions{SkipLinkChecks: skipLinkChecks})
+}
 
-	log.Printf("Successfully processed URL %d (%s)", id, url.Address)
+// AnalyzeResponse analyzes a response, e.g. a saved one, as if the crawler
+// had received it for baseAddress, optionally without checking its links
+func (s *Service) AnalyzeResponse(ctx context.Context, resp *http.Response, baseAddress string, skipLinkChecks bool) (*CrawlResult, error) {
+	return s.analyzeResponse(ctx, resp, baseAddress, crawlOptions{SkipLinkChecks: skipLinkChecks})
 }
 
-// crawlWithContext crawls a URL with context support
-func (s *Service) crawlWithContext(ctx context.Context, address string) (*CrawlResult, error) {
+// crawlWithContext crawls a URL with context support. When validators are
+// set and the server answers 304 Not Modified, the result only has NotModified set.
+func (s *Service) crawlWithContext(ctx context.Context, address string, opts crawlOptions) (result *CrawlResult, err error) {
+	ctx, span := tracer.Start(ctx, "crawler.crawl", trace.WithAttributes(attribute.String("url.full", address)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	client := &http.Client{
 		Timeout: s.timeout,
 		Transport: &http.Transport{
@@ -204,33 +736,77 @@ func (s *Service) crawlWithContext(ctx context.Context, address string) (*CrawlR
 		},
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", address, nil)
+	fetchCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(fetchCtx, "GET", address, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("User-Agent", "URL-Crawler/1.0")
+	if opts.ETag != "" {
+		req.Header.Set("If-None-Match", opts.ETag)
+	}
+	if opts.LastModified != "" {
+		req.Header.Set("If-Modified-Since", opts.LastModified)
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch URL: %w", err)
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	return s.analyzeResponse(ctx, resp, address, opts)
+}
+
+// analyzeResponse turns a response to a request for address into a result
+func (s *Service) analyzeResponse(ctx context.Context, resp *http.Response, address string, opts crawlOptions) (*CrawlResult, error) {
+	if resp.StatusCode == http.StatusNotModified && (opts.ETag != "" || opts.LastModified != "") {
+		return &CrawlResult{NotModified: true}, nil
+	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		return nil, &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	result, err := s.analyzeBody(ctx, body, address, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result.ETag = resp.Header.Get("ETag")
+	result.LastModified = resp.Header.Get("Last-Modified")
+	return result, nil
+}
+
+// analyzeBody parses an HTML body and fingerprints its content
+func (s *Service) analyzeBody(ctx context.Context, body []byte, address string, opts crawlOptions) (*CrawlResult, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	return s.parseDocument(doc, address)
+	result, err := s.parseDocument(ctx, doc, address, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result.BodyHash = sha256Hex(body)
+	result.TextHash = sha256Hex([]byte(result.MainText))
+	result.TextSimhash = formatSimhash(simhash(result.MainText))
+	return result, nil
 }
 
 // parseDocument parses the HTML document and extracts information
-func (s *Service) parseDocument(doc *goquery.Document, baseAddress string) (*CrawlResult, error) {
+func (s *Service) parseDocument(ctx context.Context, doc *goquery.Document, baseAddress string, opts crawlOptions) (*CrawlResult, error) {
 	baseURL, err := url.Parse(baseAddress)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse base URL: %w", err)
@@ -241,10 +817,14 @@ func (s *Service) parseDocument(doc *goquery.Document, baseAddress string) (*Cra
 		HTMLVersion:   s.detectHTMLVersion(doc),
 		HeadingCounts: s.countHeadings(doc),
 		HasLoginForm:  s.detectLoginForm(doc),
+		MainText:      s.extractMainText(doc),
 	}
 
 	// Analyze links
-	internal, external, brokenLinks := s.analyzeLinks(doc, baseURL)
+	internal, external, brokenLinks := s.analyzeLinks(ctx, doc, baseURL, opts)
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("link checks aborted: %w", err)
+	}
 	result.InternalLinks = internal
 	result.ExternalLinks = external
 	result.BrokenList = brokenLinks
@@ -276,10 +856,48 @@ func (s *Service) detectLoginForm(doc *goquery.Document) bool {
 	return doc.Find("input[type='password']").Length() > 0
 }
 
-// analyzeLinks analyzes internal and external links
-func (s *Service) analyzeLinks(doc *goquery.Document, baseURL *url.URL) (internal, external int, brokenLinks []map[string]string) {
+// mainContentSelectors are tried in order to locate the page's main content
+var mainContentSelectors = []string{"main", "article", "[role='main']", "body"}
+
+// textBlockSelector matches elements whose text forms a line of main content
+const textBlockSelector = "h1, h2, h3, h4, h5, h6, p, li, pre, blockquote, td, th, dt, dd, figcaption"
+
+// extractMainText extracts the readable main content, one text block per line
+func (s *Service) extractMainText(doc *goquery.Document) string {
+	root := doc.Selection
+	for _, selector := range mainContentSelectors {
+		if sel := doc.Find(selector).First(); sel.Length() > 0 {
+			root = sel
+			break
+		}
+	}
+	root = root.Clone()
+	root.Find("script, style, noscript, template, svg").Remove()
+
+	var lines []string
+	root.Find(textBlockSelector).Each(func(i int, sel *goquery.Selection) {
+		// Only take innermost blocks so nested content isn't repeated
+		if sel.Find(textBlockSelector).Length() > 0 {
+			return
+		}
+		if line := strings.Join(strings.Fields(sel.Text()), " "); line != "" {
+			lines = append(lines, line)
+		}
+	})
+
+	if len(lines) == 0 {
+		return strings.Join(strings.Fields(root.Text()), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// analyzeLinks analyzes internal and external links, reporting link check
+// progress to opts.OnProgress and stopping early when ctx is cancelled
+func (s *Service) analyzeLinks(ctx context.Context, doc *goquery.Document, baseURL *url.URL, opts crawlOptions) (internal, external int, brokenLinks []map[string]string) {
 	brokenLinks = make([]map[string]string, 0)
-	
+
+	// Resolve all links first so progress can be reported against a total
+	var links []string
 	doc.Find("a[href]").Each(func(i int, sel *goquery.Selection) {
 		href, exists := sel.Attr("href")
 		if !exists || href == "" {
@@ -302,24 +920,45 @@ func (s *Service) analyzeLinks(doc *goquery.Document, baseURL *url.URL) (interna
 			external++
 		}
 
+		links = append(links, resolvedURL.String())
+	})
+	if opts.SkipLinkChecks {
+		return internal, external, brokenLinks
+	}
+
+	for i, link := range links {
+		if ctx.Err() != nil {
+			break
+		}
+
 		// Check if link is broken (simplified check)
-		if statusCode := s.checkLink(resolvedURL.String()); statusCode >= 400 {
+		statusCode := s.checkLink(ctx, link)
+		s.linkChecks.Add(1)
+		if statusCode >= 400 && ctx.Err() == nil {
 			brokenLinks = append(brokenLinks, map[string]string{
-				"url":  resolvedURL.String(),
+				"url":  link,
 				"code": strconv.Itoa(statusCode),
 			})
 		}
-	})
+
+		if opts.OnProgress != nil {
+			opts.OnProgress(i+1, len(links))
+		}
+	}
 
 	return internal, external, brokenLinks
 }
 
 // checkLink checks if a link is broken
-func (s *Service) checkLink(link string) int {
+func (s *Service) checkLink(ctx context.Context, link string) int {
+	ctx, span := tracer.Start(ctx, "crawler.check_link", trace.WithAttributes(attribute.String("url.full", link)))
+	defer span.End()
+
 	client := &http.Client{Timeout: 10 * time.Second}
-	
-	req, err := http.NewRequest("HEAD", link, nil)
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", link, nil)
 	if err != nil {
+		span.RecordError(err)
 		return 500
 	}
 	
@@ -327,39 +966,95 @@ func (s *Service) checkLink(link string) int {
 	
 	resp, err := client.Do(req)
 	if err != nil {
+		span.RecordError(err)
 		return 500
 	}
 	defer resp.Body.Close()
 	
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 	return resp.StatusCode
 }
 
-// updateURLWithResults updates the URL record with crawl results
-func (s *Service) updateURLWithResults(id uint, result *CrawlResult) error {
-	brokenListJSON, err := json.Marshal(result.BrokenList)
-	if err != nil {
-		return fmt.Errorf("failed to marshal broken list: %w", err)
-	}
+// finishRun sets the final status, error and timing of a run
+func finishRun(run *db.CrawlRun, status db.URLStatus, errorMsg string) time.Time {
+	finishedAt := time.Now()
+	run.Status = status
+	run.Error = errorMsg
+	run.FinishedAt = &finishedAt
+	run.DurationMs = finishedAt.Sub(run.StartedAt).Milliseconds()
+	return finishedAt
+}
 
-	headingsJSON, err := json.Marshal(result.HeadingCounts)
+// updateURLWithResults stores crawl results on the run and makes it the URL's latest run
+func (s *Service) updateURLWithResults(ctx context.Context, run *db.CrawlRun, result *CrawlResult) error {
+	stored, err := result.Run()
 	if err != nil {
-		return fmt.Errorf("failed to marshal heading counts: %w", err)
+		return err
 	}
 
-	updates := map[string]interface{}{
-		"title":          result.Title,
-		"html_version":   result.HTMLVersion,
-		"heading_counts": string(headingsJSON),
-		"internal_links": result.InternalLinks,
-		"external_links": result.ExternalLinks,
-		"broken_links":   len(result.BrokenList),
-		"broken_list":    string(brokenListJSON),
-		"has_login_form": result.HasLoginForm,
-		"status":         db.StatusDone,
-		"error":          "",
+	run.SetResults(stored)
+	run.ContentChanged = result.ContentChanged
+	finishedAt := finishRun(run, db.StatusDone, "")
+
+	update := service.URLRunUpdate{
+		Status:         db.StatusDone,
+		LastRunID:      &run.ID,
+		LastRunAt:      &run.StartedAt,
+		Results:        true,
+		ETag:           result.ETag,
+		LastModified:   result.LastModified,
+		ContentChanged: &result.ContentChanged,
+	}
+	if result.ContentChanged {
+		update.ContentChangedAt = &finishedAt
 	}
+	return s.urls.FinishRun(ctx, run, update)
+}
+
+// updateURLUnchanged records a not-modified run by carrying over the previous
+// run's results, and marks the URL done without touching its stored results
+func (s *Service) updateURLUnchanged(ctx context.Context, run *db.CrawlRun, previous *db.CrawlRun) error {
+	run.SetResults(previous)
+	run.ContentChanged = false
+	finishRun(run, db.StatusUnchanged, "")
+
+	return s.urls.FinishRun(ctx, run, service.URLRunUpdate{
+		Status:         db.StatusDone,
+		LastRunID:      &run.ID,
+		LastRunAt:      &run.StartedAt,
+		ContentChanged: &run.ContentChanged,
+	})
+}
+
+// updateURLWithError marks both the run and its URL as failed
+func (s *Service) updateURLWithError(ctx context.Context, run *db.CrawlRun, errorMsg string) error {
+	finishRun(run, db.StatusError, errorMsg)
 
-	return s.db.Model(&db.URL{}).Where("id = ?", id).Updates(updates).Error
+	return s.urls.FinishRun(ctx, run, service.URLRunUpdate{
+		Status:    db.StatusError,
+		Error:     errorMsg,
+		LastRunID: &run.ID,
+		LastRunAt: &run.StartedAt,
+	})
+}
+
+// updateURLCancelled finishes a run as cancelled; the URL keeps the results of
+// its previous run
+func (s *Service) updateURLCancelled(ctx context.Context, run *db.CrawlRun) error {
+	finishRun(run, db.StatusCancelled, ErrCrawlCancelled.Error())
+
+	return s.urls.FinishRun(ctx, run, service.URLRunUpdate{
+		Status:    db.StatusCancelled,
+		LastRunAt: &run.StartedAt,
+	})
+}
+
+// updateURLInterrupted finishes a run interrupted by shutdown and puts its URL
+// back in the queued state; the URL keeps the results of its previous run
+func (s *Service) updateURLInterrupted(ctx context.Context, run *db.CrawlRun) error {
+	finishRun(run, db.StatusCancelled, ErrCrawlInterrupted.Error())
+
+	return s.urls.FinishRun(ctx, run, service.URLRunUpdate{Status: db.StatusQueued})
 }
 
 // CrawlResult represents the result of crawling a URL
@@ -371,4 +1066,44 @@ type CrawlResult struct {
 	ExternalLinks int                 `json:"external_links"`
 	BrokenList    []map[string]string `json:"broken_list"`
 	HasLoginForm  bool                `json:"has_login_form"`
-}
\ No newline at end of file
+	MainText      string              `json:"main_text,omitempty"`
+	BodyHash      string              `json:"body_hash,omitempty"`
+	TextHash      string              `json:"text_hash,omitempty"`
+	TextSimhash   string              `json:"text_simhash,omitempty"`
+	ETag          string              `json:"etag,omitempty"`
+	LastModified  string              `json:"last_modified,omitempty"`
+	// ContentChanged is set when the main content differs from the previous run
+	ContentChanged bool `json:"content_changed"`
+	// NotModified is set when a conditional request was answered with 304
+	NotModified bool `json:"not_modified,omitempty"`
+}
+
+// Run returns the result in the form it is stored on a successful crawl run
+func (r *CrawlResult) Run() (*db.CrawlRun, error) {
+	brokenListJSON, err := json.Marshal(r.BrokenList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal broken list: %w", err)
+	}
+
+	headingsJSON, err := json.Marshal(r.HeadingCounts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal heading counts: %w", err)
+	}
+
+	return &db.CrawlRun{
+		Status:         db.StatusDone,
+		Title:          r.Title,
+		HTMLVersion:    r.HTMLVersion,
+		HeadingCounts:  string(headingsJSON),
+		InternalLinks:  r.InternalLinks,
+		ExternalLinks:  r.ExternalLinks,
+		BrokenLinks:    len(r.BrokenList),
+		BrokenList:     string(brokenListJSON),
+		HasLoginForm:   r.HasLoginForm,
+		MainText:       r.MainText,
+		BodyHash:       r.BodyHash,
+		TextHash:       r.TextHash,
+		TextSimhash:    r.TextSimhash,
+		ContentChanged: r.ContentChanged,
+	}, nil
+}
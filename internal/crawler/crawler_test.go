@@ -0,0 +1,416 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+const testPage = `<!DOCTYPE html>
+<html>
+<head><title>%s</title></head>
+<body>
+  <h1>Welcome</h1>
+  <main><p>%s</p></main>
+  <a href="/ok">OK</a>
+  <a href="/missing">Missing</a>
+</body>
+</html>`
+
+// testSite serves a page whose text can be changed, honoring If-None-Match
+type testSite struct {
+	mu     sync.Mutex
+	text   string
+	etag   string
+	status int
+}
+
+func (s *testSite) set(text, etag string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.text, s.etag, s.status = text, etag, status
+}
+
+func (s *testSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	text, etag, status := s.text, s.etag, s.status
+	s.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/ok":
+		w.WriteHeader(http.StatusOK)
+	case "/missing":
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		if etag != "" {
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, testPage, "Test Page", text)
+	}
+}
+
+func newTestService(t *testing.T, urls service.URLRepository, config *Config) *Service {
+	t.Helper()
+	if config == nil {
+		config = DefaultConfig()
+	}
+	config.Workers = 1
+	config.Timeout = 5 * time.Second
+
+	s := NewService(urls, config)
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { s.Stop() })
+	return s
+}
+
+// waitForStatus polls until the URL reaches status
+func waitForStatus(t *testing.T, urls service.URLRepository, id uint, status db.URLStatus) *db.URL {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		url, err := urls.GetByID(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if url.Status == status {
+			return url
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("URL %d status = %s, want %s", id, url.Status, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// latestRun returns the newest run of a URL
+func latestRun(t *testing.T, urls service.URLRepository, id uint) db.CrawlRun {
+	t.Helper()
+	runs, _, err := urls.ListRuns(context.Background(), id, 1, 1)
+	if err != nil || len(runs) == 0 {
+		t.Fatalf("ListRuns = %v, %v", runs, err)
+	}
+	return runs[0]
+}
+
+// crawlAgain queues a finished URL and waits until it is done again
+func crawlAgain(t *testing.T, s *Service, urls service.URLRepository, id uint, status db.URLStatus) *db.URL {
+	t.Helper()
+	if _, err := urls.RequeueIdle(context.Background(), id); err != nil {
+		t.Fatalf("RequeueIdle: %v", err)
+	}
+	if err := s.Enqueue(context.Background(), id, PriorityInteractive); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	return waitForStatus(t, urls, id, status)
+}
+
+func TestCrawlStoresResults(t *testing.T) {
+	site := &testSite{}
+	site.set("Fresh products every day.", `"v1"`, http.StatusOK)
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	urls := service.NewMemoryURLRepository()
+	s := newTestService(t, urls, nil)
+
+	url, err := urls.Create(context.Background(), 1, server.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Enqueue(context.Background(), url.ID, PriorityInteractive); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	url = waitForStatus(t, urls, url.ID, db.StatusDone)
+	if url.Title != "Test Page" || url.InternalLinks != 2 || url.BrokenLinks != 1 {
+		t.Errorf("URL results = title %q, internal %d, broken %d", url.Title, url.InternalLinks, url.BrokenLinks)
+	}
+	if url.ETag != `"v1"` {
+		t.Errorf("URL etag = %q, want %q", url.ETag, `"v1"`)
+	}
+
+	run := latestRun(t, urls, url.ID)
+	if url.LastRunID == nil || *url.LastRunID != run.ID {
+		t.Errorf("URL last run = %v, want %d", url.LastRunID, run.ID)
+	}
+	if run.Status != db.StatusDone || run.Title != "Test Page" || run.BrokenLinks != 1 || run.TextHash == "" {
+		t.Errorf("run = %+v", run)
+	}
+	if run.FinishedAt == nil {
+		t.Error("run has no finish time")
+	}
+}
+
+func TestCrawlNotModifiedCarriesOverResults(t *testing.T) {
+	site := &testSite{}
+	site.set("Fresh products every day.", `"v1"`, http.StatusOK)
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	urls := service.NewMemoryURLRepository()
+	s := newTestService(t, urls, nil)
+
+	url, _ := urls.Create(context.Background(), 1, server.URL+"/")
+	s.Enqueue(context.Background(), url.ID, PriorityInteractive)
+	waitForStatus(t, urls, url.ID, db.StatusDone)
+	first := latestRun(t, urls, url.ID)
+
+	url = crawlAgain(t, s, urls, url.ID, db.StatusDone)
+	second := latestRun(t, urls, url.ID)
+	if second.ID == first.ID || second.Status != db.StatusUnchanged {
+		t.Fatalf("second run = %d %s, want a new unchanged run", second.ID, second.Status)
+	}
+	if second.Title != first.Title || second.TextHash != first.TextHash || second.BrokenList != first.BrokenList {
+		t.Errorf("unchanged run didn't carry over results: %+v", second)
+	}
+	if url.LastRunID == nil || *url.LastRunID != second.ID || url.ContentChanged {
+		t.Errorf("URL = last run %v, content changed %v", url.LastRunID, url.ContentChanged)
+	}
+}
+
+func TestCrawlDetectsContentChange(t *testing.T) {
+	site := &testSite{}
+	site.set("Fresh products every day.", "", http.StatusOK)
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	urls := service.NewMemoryURLRepository()
+	config := DefaultConfig()
+	config.ChangeThreshold = 0
+	s := newTestService(t, urls, config)
+
+	url, _ := urls.Create(context.Background(), 1, server.URL+"/")
+	s.Enqueue(context.Background(), url.ID, PriorityInteractive)
+	url = waitForStatus(t, urls, url.ID, db.StatusDone)
+	if url.ContentChanged {
+		t.Fatal("first crawl reported a content change")
+	}
+
+	site.set("Closing down sale, everything must go.", "", http.StatusOK)
+	url = crawlAgain(t, s, urls, url.ID, db.StatusDone)
+	if !url.ContentChanged || url.ContentChangedAt == nil {
+		t.Errorf("URL content changed = %v at %v, want a change", url.ContentChanged, url.ContentChangedAt)
+	}
+	if run := latestRun(t, urls, url.ID); !run.ContentChanged {
+		t.Error("run didn't record the content change")
+	}
+}
+
+func TestCrawlErrorKeepsPreviousResults(t *testing.T) {
+	site := &testSite{}
+	site.set("Fresh products every day.", "", http.StatusOK)
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	urls := service.NewMemoryURLRepository()
+	s := newTestService(t, urls, nil)
+
+	url, _ := urls.Create(context.Background(), 1, server.URL+"/")
+	s.Enqueue(context.Background(), url.ID, PriorityInteractive)
+	waitForStatus(t, urls, url.ID, db.StatusDone)
+
+	site.set("", "", http.StatusInternalServerError)
+	url = crawlAgain(t, s, urls, url.ID, db.StatusError)
+	if url.Error == "" || url.Title != "Test Page" {
+		t.Errorf("URL = error %q, title %q; want an error and the previous title", url.Error, url.Title)
+	}
+
+	run := latestRun(t, urls, url.ID)
+	if run.Status != db.StatusError || run.Error == "" || run.Title != "" {
+		t.Errorf("run = %+v, want an error run without results", run)
+	}
+	if url.LastRunID == nil || *url.LastRunID != run.ID {
+		t.Errorf("URL last run = %v, want %d", url.LastRunID, run.ID)
+	}
+}
+
+func TestCancelQueuedURL(t *testing.T) {
+	urls := service.NewMemoryURLRepository()
+	s := newTestService(t, urls, nil)
+	s.Pause()
+
+	url, _ := urls.Create(context.Background(), 1, "https://example.com/")
+	if err := s.Enqueue(context.Background(), url.ID, PriorityInteractive); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	cancelled, err := s.CancelURL(url.ID)
+	if err != nil || !cancelled {
+		t.Fatalf("CancelURL = %v, %v", cancelled, err)
+	}
+	if s.queue.Contains(url.ID) {
+		t.Error("cancelled URL is still queued")
+	}
+	waitForStatus(t, urls, url.ID, db.StatusCancelled)
+}
+
+func TestRefillLoadsQueuedURLsBeyondCapacity(t *testing.T) {
+	site := &testSite{}
+	site.set("Fresh products every day.", "", http.StatusOK)
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	// URLs queued in the database, e.g. by the CLI, before and after start
+	urls := service.NewMemoryURLRepository()
+	var ids []uint
+	for i := 0; i < 3; i++ {
+		url, _ := urls.Create(context.Background(), 1, fmt.Sprintf("%s/?page=%d", server.URL, i))
+		ids = append(ids, url.ID)
+	}
+
+	config := DefaultConfig()
+	config.QueueSize = 1
+	config.RefillInterval = 10 * time.Millisecond
+	newTestService(t, urls, config)
+
+	for i := 3; i < 5; i++ {
+		url, _ := urls.Create(context.Background(), 1, fmt.Sprintf("%s/?page=%d", server.URL, i))
+		ids = append(ids, url.ID)
+	}
+	for _, id := range ids {
+		waitForStatus(t, urls, id, db.StatusDone)
+	}
+}
+
+func TestQueuedURLsKeepTheirPriority(t *testing.T) {
+	urls := service.NewMemoryURLRepository()
+	ctx := context.Background()
+
+	// URLs left queued by the previous process with the priority they were
+	// enqueued with; the queue only has room for two of them
+	bulk, _ := urls.Create(ctx, 1, "https://example.com/bulk")
+	interactive, _ := urls.Create(ctx, 1, "https://example.com/interactive")
+	scheduled, _ := urls.Create(ctx, 1, "https://example.com/scheduled")
+	urls.SetQueuePriority(ctx, interactive.ID, int(PriorityInteractive))
+	urls.SetQueuePriority(ctx, scheduled.ID, int(PriorityScheduled))
+
+	config := DefaultConfig()
+	config.Workers = 1
+	config.QueueSize = 2
+	config.RefillInterval = time.Hour
+	s := NewService(urls, config)
+	s.Pause()
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { s.Stop() })
+
+	want := map[uint]Priority{interactive.ID: PriorityInteractive, scheduled.ID: PriorityScheduled}
+	items := s.queue.Items(nil)
+	if len(items) != len(want) {
+		t.Fatalf("recovered %d URLs, want %d", len(items), len(want))
+	}
+	for _, item := range items {
+		if priority, ok := want[item.URLID]; !ok || item.Priority != priority {
+			t.Errorf("recovered URL %d with priority %s, want %v", item.URLID, item.Priority, want)
+		}
+	}
+
+	// A URL that doesn't fit stays queued in the database with its priority
+	late, _ := urls.Create(ctx, 1, "https://example.com/late")
+	if err := s.Enqueue(ctx, late.ID, PriorityInteractive); err != errQueueFull {
+		t.Fatalf("Enqueue into a full queue = %v, want errQueueFull", err)
+	}
+	queued, _ := urls.ListQueued(ctx, 10)
+	if len(queued) != 4 || queued[0].ID != interactive.ID || queued[1].ID != late.ID || queued[3].ID != bulk.ID {
+		t.Errorf("queued URLs in the database = %+v, want interactive ones first and bulk last", queued)
+	}
+}
+
+func TestStopGracePeriodAndRestart(t *testing.T) {
+	tests := []struct {
+		name      string
+		grace     time.Duration
+		delay     time.Duration
+		want      db.URLStatus
+		wantError string
+	}{
+		{"crawl finishes in time", 5 * time.Second, 50 * time.Millisecond, db.StatusDone, ""},
+		{"grace period expires", 50 * time.Millisecond, time.Minute, db.StatusCancelled, ErrCrawlInterrupted.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := &testSite{}
+			site.set("Fresh products every day.", "", http.StatusOK)
+			started := make(chan struct{}, 1)
+			release := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Has("slow") {
+					select {
+					case started <- struct{}{}:
+					default:
+					}
+					select {
+					case <-time.After(tt.delay):
+					case <-release:
+					case <-r.Context().Done():
+						return
+					}
+				}
+				site.ServeHTTP(w, r)
+			}))
+			defer server.Close()
+			releaseOnce := sync.OnceFunc(func() { close(release) })
+			defer releaseOnce()
+
+			ctx := context.Background()
+			urls := service.NewMemoryURLRepository()
+			config := DefaultConfig()
+			config.ShutdownGrace = tt.grace
+			config.RefillInterval = 10 * time.Millisecond
+			s := newTestService(t, urls, config)
+
+			slow, _ := urls.Create(ctx, 1, server.URL+"/?slow")
+			if err := s.Enqueue(ctx, slow.ID, PriorityInteractive); err != nil {
+				t.Fatalf("Enqueue: %v", err)
+			}
+			<-started
+			waiting, _ := urls.Create(ctx, 1, server.URL+"/?waiting")
+			if err := s.Enqueue(ctx, waiting.ID, PriorityInteractive); err != nil {
+				t.Fatalf("Enqueue: %v", err)
+			}
+
+			s.Stop()
+			if err := s.Enqueue(ctx, waiting.ID, PriorityInteractive); err != errQueueClosed {
+				t.Errorf("Enqueue after Stop = %v, want errQueueClosed", err)
+			}
+
+			// Interrupted crawls are queued again like URLs still waiting
+			url, _ := urls.GetByID(ctx, slow.ID)
+			run := latestRun(t, urls, slow.ID)
+			if tt.want == db.StatusCancelled && url.Status != db.StatusQueued {
+				t.Errorf("interrupted URL status = %s, want queued", url.Status)
+			}
+			if run.Status != tt.want || run.Error != tt.wantError {
+				t.Errorf("run = %s %q, want %s %q", run.Status, run.Error, tt.want, tt.wantError)
+			}
+			if url, _ := urls.GetByID(ctx, waiting.ID); url.Status != db.StatusQueued {
+				t.Errorf("waiting URL status = %s, want queued", url.Status)
+			}
+
+			// The next start picks up where this one stopped
+			releaseOnce()
+			newTestService(t, urls, config)
+			waitForStatus(t, urls, slow.ID, db.StatusDone)
+			waitForStatus(t, urls, waiting.ID, db.StatusDone)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// Error classes reported with failed crawls, e.g. as a metrics label
+const (
+	ErrorClassTimeout    = "timeout"
+	ErrorClassDNS        = "dns"
+	ErrorClassConnection = "connection"
+	ErrorClassTLS        = "tls"
+	ErrorClassHTTP4xx    = "http_4xx"
+	ErrorClassHTTP5xx    = "http_5xx"
+	ErrorClassHTTPOther  = "http_other"
+	ErrorClassOther      = "other"
+)
+
+// StatusError is returned when a page responds with a status other than 200
+type StatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.Code, e.Status)
+}
+
+// ClassifyError maps a crawl error to one of the ErrorClass constants
+func ClassifyError(err error) string {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.Code >= 400 && statusErr.Code < 500:
+			return ErrorClassHTTP4xx
+		case statusErr.Code >= 500:
+			return ErrorClassHTTP5xx
+		default:
+			return ErrorClassHTTPOther
+		}
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return ErrorClassTimeout
+		}
+		return ErrorClassDNS
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &certErr) || errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &recordErr) {
+		return ErrorClassTLS
+	}
+
+	var opErr *net.OpError
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.As(err, &opErr) {
+		return ErrorClassConnection
+	}
+
+	return ErrorClassOther
+}
@@ -0,0 +1,149 @@
+package crawler
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/events"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+// progressInterval throttles how often link check progress is published
+const progressInterval = 500 * time.Millisecond
+
+// SetEventBus makes the crawler publish its events to bus.
+// It must be called before Start.
+func (s *Service) SetEventBus(bus *events.Bus) {
+	s.bus = bus
+}
+
+// publish sends an event to the bus, if one is configured
+func (s *Service) publish(event events.Event) {
+	if s.bus != nil {
+		s.bus.Publish(event)
+	}
+}
+
+// publishQueued publishes URLQueued for a freshly enqueued URL
+func (s *Service) publishQueued(url *db.URL) {
+	s.publish(events.URLQueued{URLID: url.ID, UserID: url.UserID, OccurredAt: time.Now().UTC()})
+}
+
+// publishStarted publishes CrawlStarted for a new run
+func (s *Service) publishStarted(url *db.URL, run *db.CrawlRun) {
+	s.publish(events.CrawlStarted{
+		URLID:      url.ID,
+		UserID:     url.UserID,
+		RunID:      run.ID,
+		Address:    url.Address,
+		OccurredAt: time.Now().UTC(),
+	})
+}
+
+// publishSucceeded publishes CrawlSucceeded, plus LinkBroken for every broken
+// link found. brokenLinks is the run's total, carried over for unchanged runs.
+func (s *Service) publishSucceeded(url *db.URL, run *db.CrawlRun, result *CrawlResult, brokenLinks int) {
+	now := time.Now().UTC()
+
+	if !result.NotModified {
+		for _, link := range result.BrokenList {
+			code, _ := strconv.Atoi(link["code"])
+			s.publish(events.LinkBroken{
+				URLID:      url.ID,
+				UserID:     url.UserID,
+				RunID:      run.ID,
+				Link:       link["url"],
+				StatusCode: code,
+				OccurredAt: now,
+			})
+		}
+	}
+
+	s.publish(events.CrawlSucceeded{
+		URLID:          url.ID,
+		UserID:         url.UserID,
+		RunID:          run.ID,
+		Address:        url.Address,
+		BrokenLinks:    brokenLinks,
+		ContentChanged: result.ContentChanged,
+		NotModified:    result.NotModified,
+		Duration:       now.Sub(run.StartedAt),
+		OccurredAt:     now,
+	})
+}
+
+// publishFailed publishes CrawlFailed; run may be nil if none was created
+func (s *Service) publishFailed(url *db.URL, run *db.CrawlRun, err error) {
+	now := time.Now().UTC()
+	event := events.CrawlFailed{
+		URLID:      url.ID,
+		UserID:     url.UserID,
+		Address:    url.Address,
+		Error:      err.Error(),
+		ErrorClass: ClassifyError(err),
+		OccurredAt: now,
+	}
+	if run != nil {
+		event.RunID = run.ID
+		event.Duration = now.Sub(run.StartedAt)
+	}
+	s.publish(event)
+}
+
+// publishCancelled publishes CrawlCancelled for a cancelled crawl; run is nil
+// when the URL was cancelled while still queued
+func (s *Service) publishCancelled(id uint, run *db.CrawlRun) {
+	if s.bus == nil {
+		return
+	}
+
+	url, err := s.urls.GetByID(context.Background(), id)
+	if err != nil {
+		// Crawls of deleted URLs end without an event
+		if err != service.ErrNotFound {
+			slog.Error("Failed to load URL for cancelled event", "url_id", id, "error", err)
+		}
+		return
+	}
+
+	now := time.Now().UTC()
+	event := events.CrawlCancelled{
+		URLID:      url.ID,
+		UserID:     url.UserID,
+		Address:    url.Address,
+		OccurredAt: now,
+	}
+	if run != nil {
+		event.RunID = run.ID
+		event.Duration = now.Sub(run.StartedAt)
+	}
+	s.publish(event)
+}
+
+// progressReporter returns a callback publishing link check progress of a
+// run, throttled to one event per progressInterval plus the final count
+func (s *Service) progressReporter(url *db.URL, run *db.CrawlRun) func(checked, total int) {
+	if s.bus == nil {
+		return nil
+	}
+
+	var last time.Time
+	return func(checked, total int) {
+		if checked < total && time.Since(last) < progressInterval {
+			return
+		}
+		last = time.Now()
+
+		s.publish(events.CrawlProgress{
+			URLID:        url.ID,
+			UserID:       url.UserID,
+			RunID:        run.ID,
+			LinksChecked: checked,
+			LinksTotal:   total,
+			OccurredAt:   last.UTC(),
+		})
+	}
+}
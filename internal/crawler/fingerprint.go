@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// simhashShingleSize is the number of consecutive words hashed together
+const simhashShingleSize = 3
+
+// sha256Hex returns the hex encoded SHA-256 digest of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// simhash computes a 64-bit similarity hash over word shingles of text.
+// Texts differing in a few words produce hashes a small Hamming distance apart.
+func simhash(text string) uint64 {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) == 0 {
+		return 0
+	}
+
+	size := simhashShingleSize
+	if len(words) < size {
+		size = len(words)
+	}
+
+	var weights [64]int
+	for i := 0; i+size <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+size], " ")))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var hash uint64
+	for bit := 0; bit < 64; bit++ {
+		if weights[bit] > 0 {
+			hash |= 1 << uint(bit)
+		}
+	}
+	return hash
+}
+
+// formatSimhash encodes a simhash as fixed-width hex for storage
+func formatSimhash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// simhashDistance returns the Hamming distance between two stored simhashes
+func simhashDistance(a, b string) (int, error) {
+	x, err := strconv.ParseUint(a, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid simhash %q: %w", a, err)
+	}
+	y, err := strconv.ParseUint(b, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid simhash %q: %w", b, err)
+	}
+	return bits.OnesCount64(x ^ y), nil
+}
@@ -0,0 +1,151 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+// Purger periodically removes URLs that have been in the trash longer than
+// the retention period, together with their crawl history
+type Purger struct {
+	urls      service.URLRepository
+	retention time.Duration
+	interval  time.Duration
+	batchSize int
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	isRunning bool
+}
+
+// PurgerConfig holds trash purge configuration
+type PurgerConfig struct {
+	// Retention is how long deleted URLs can be restored
+	Retention time.Duration
+	// Interval is how often expired URLs are purged
+	Interval time.Duration
+	// BatchSize limits how many URLs are removed per transaction
+	BatchSize int
+}
+
+// DefaultPurgerConfig returns default trash purge configuration
+func DefaultPurgerConfig() *PurgerConfig {
+	return &PurgerConfig{
+		Retention: 30 * 24 * time.Hour,
+		Interval:  time.Hour,
+		BatchSize: 500,
+	}
+}
+
+// NewPurgerConfig creates a trash purge configuration from environment variables
+func NewPurgerConfig() *PurgerConfig {
+	config := DefaultPurgerConfig()
+
+	if v, err := time.ParseDuration(os.Getenv("URL_TRASH_RETENTION")); err == nil && v > 0 {
+		config.Retention = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("URL_TRASH_PURGE_INTERVAL")); err == nil && v > 0 {
+		config.Interval = v
+	}
+
+	return config
+}
+
+// NewPurger creates a purger for deleted URLs
+func NewPurger(urls service.URLRepository, config *PurgerConfig) *Purger {
+	if config == nil {
+		config = DefaultPurgerConfig()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Purger{
+		urls:      urls,
+		retention: config.Retention,
+		interval:  config.Interval,
+		batchSize: config.BatchSize,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start starts the purge loop
+func (p *Purger) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isRunning {
+		return fmt.Errorf("purger is already running")
+	}
+
+	p.isRunning = true
+	p.wg.Add(1)
+	go p.run()
+
+	slog.Info("Trash purger started", "retention", p.retention, "interval", p.interval)
+	return nil
+}
+
+// Stop stops the purge loop; a batch in progress is rolled back
+func (p *Purger) Stop() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.isRunning {
+		return nil
+	}
+
+	p.isRunning = false
+	p.cancel()
+	p.wg.Wait()
+
+	slog.Info("Trash purger stopped")
+	return nil
+}
+
+// run purges expired URLs until the purger is stopped
+func (p *Purger) run() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	p.purgeExpired()
+	for {
+		select {
+		case <-ticker.C:
+			p.purgeExpired()
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// purgeExpired removes all URLs deleted before the retention period, in batches
+func (p *Purger) purgeExpired() {
+	cutoff := time.Now().Add(-p.retention)
+
+	var total int64
+	for p.ctx.Err() == nil {
+		purged, err := p.urls.Purge(p.ctx, cutoff, p.batchSize)
+		if err != nil {
+			slog.Error("Failed to purge deleted URLs", "error", err)
+			break
+		}
+		total += purged
+		if purged < int64(p.batchSize) {
+			break
+		}
+	}
+
+	if total > 0 {
+		slog.Info("Purged deleted URLs", "count", total, "deleted_before", cutoff)
+	}
+}
@@ -0,0 +1,363 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Priority orders crawl jobs in the queue
+type Priority int
+
+const (
+	// PriorityBulk is used for bulk reruns and imports
+	PriorityBulk Priority = iota
+	// PriorityScheduled is used for recurring crawls
+	PriorityScheduled
+	// PriorityInteractive is used for URLs submitted or rerun one at a time
+	PriorityInteractive
+
+	numPriorities = 3
+)
+
+// String returns the priority name used in the API
+func (p Priority) String() string {
+	switch p {
+	case PriorityBulk:
+		return "bulk"
+	case PriorityScheduled:
+		return "scheduled"
+	case PriorityInteractive:
+		return "interactive"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the priority by name
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+var (
+	errQueueFull   = errors.New("queue is full")
+	errQueueClosed = errors.New("crawler service is not running")
+)
+
+// QueueItem describes a queued crawl job
+type QueueItem struct {
+	URLID      uint      `json:"url_id"`
+	UserID     uint      `json:"user_id"`
+	Priority   Priority  `json:"priority"`
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	// RequestID and SpanContext tie the crawl to the request that queued it
+	RequestID   string            `json:"request_id,omitempty"`
+	SpanContext trace.SpanContext `json:"-"`
+}
+
+// lane holds the jobs of one priority as per-user FIFOs served round-robin
+type lane struct {
+	jobs  map[uint][]*QueueItem
+	users []uint // Users with queued jobs, in round-robin order
+	next  int
+}
+
+// fairQueue is the crawl queue. Priorities are served by smooth weighted
+// round-robin so lower priorities are slowed down but never starved, and
+// within a priority users take turns so one large submission can't block
+// everyone else.
+type fairQueue struct {
+	mu       sync.Mutex
+	lanes    [numPriorities]*lane
+	weights  [numPriorities]int
+	current  [numPriorities]int
+	index    map[uint]*QueueItem
+	capacity int
+	closed   bool
+	paused   bool
+	ready    chan struct{}
+}
+
+// newFairQueue creates a queue holding at most capacity jobs
+func newFairQueue(capacity int, weights [numPriorities]int) *fairQueue {
+	q := &fairQueue{
+		weights:  weights,
+		index:    make(map[uint]*QueueItem),
+		capacity: capacity,
+		ready:    make(chan struct{}, 1),
+	}
+	for i := range q.lanes {
+		q.lanes[i] = &lane{jobs: make(map[uint][]*QueueItem)}
+		if q.weights[i] < 1 {
+			q.weights[i] = 1
+		}
+	}
+	return q
+}
+
+// Push adds a job. A URL that is already queued keeps its place, but is
+// moved up when pushed again with a higher priority.
+func (q *fairQueue) Push(job QueueItem) error {
+	if job.Priority < 0 || job.Priority >= numPriorities {
+		job.Priority = PriorityBulk
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return errQueueClosed
+	}
+
+	item := &job
+	item.EnqueuedAt = time.Now()
+	if existing, ok := q.index[job.URLID]; ok {
+		if existing.Priority >= job.Priority {
+			return nil
+		}
+		q.removeLocked(existing)
+		item.EnqueuedAt = existing.EnqueuedAt
+	} else if len(q.index) >= q.capacity {
+		return errQueueFull
+	}
+
+	l := q.lanes[job.Priority]
+	if len(l.jobs[job.UserID]) == 0 {
+		l.users = append(l.users, job.UserID)
+	}
+	l.jobs[job.UserID] = append(l.jobs[job.UserID], item)
+	q.index[job.URLID] = item
+
+	q.signal()
+	return nil
+}
+
+// Pop blocks until a job is available and returns it. It returns false once
+// the queue is closed or ctx is done.
+func (q *fairQueue) Pop(ctx context.Context) (*QueueItem, bool) {
+	for {
+		if ctx.Err() != nil {
+			q.handOff()
+			return nil, false
+		}
+
+		item, closed := q.tryPop()
+		if item != nil {
+			return item, true
+		}
+		if closed {
+			return nil, false
+		}
+
+		select {
+		case <-q.ready:
+		case <-ctx.Done():
+			q.handOff()
+			return nil, false
+		}
+	}
+}
+
+// tryPop removes the next job without blocking
+func (q *fairQueue) tryPop() (*QueueItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil, true
+	}
+	if q.paused {
+		return nil, false
+	}
+
+	priority, ok := q.pickLane()
+	if !ok {
+		return nil, false
+	}
+
+	l := q.lanes[priority]
+	l.next %= len(l.users)
+	userID := l.users[l.next]
+	item := l.jobs[userID][0]
+	q.removeLocked(item)
+
+	// Let the next user of this lane go first next time
+	if len(l.jobs[userID]) > 0 {
+		l.next++
+	}
+
+	// Wake another worker if there is more work
+	if len(q.index) > 0 {
+		q.signal()
+	}
+	return item, false
+}
+
+// pickLane selects the priority to serve next among non-empty lanes using
+// smooth weighted round-robin
+func (q *fairQueue) pickLane() (Priority, bool) {
+	best, total := -1, 0
+	for i, l := range q.lanes {
+		if len(l.users) == 0 {
+			q.current[i] = 0
+			continue
+		}
+		q.current[i] += q.weights[i]
+		total += q.weights[i]
+		if best == -1 || q.current[i] > q.current[best] {
+			best = i
+		}
+	}
+	if best == -1 {
+		return 0, false
+	}
+	q.current[best] -= total
+	return Priority(best), true
+}
+
+// Remove drops a queued job and reports whether it was queued
+func (q *fairQueue) Remove(urlID uint) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.index[urlID]
+	if ok {
+		q.removeLocked(item)
+	}
+	return ok
+}
+
+// removeLocked unlinks a job from its lane and the index
+func (q *fairQueue) removeLocked(item *QueueItem) {
+	delete(q.index, item.URLID)
+
+	l := q.lanes[item.Priority]
+	jobs := l.jobs[item.UserID]
+	for i, job := range jobs {
+		if job == item {
+			jobs = append(jobs[:i], jobs[i+1:]...)
+			break
+		}
+	}
+	if len(jobs) > 0 {
+		l.jobs[item.UserID] = jobs
+		return
+	}
+
+	delete(l.jobs, item.UserID)
+	for i, userID := range l.users {
+		if userID == item.UserID {
+			l.users = append(l.users[:i], l.users[i+1:]...)
+			if i < l.next {
+				l.next--
+			}
+			break
+		}
+	}
+}
+
+// Contains reports whether a URL is queued
+func (q *fairQueue) Contains(urlID uint) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.index[urlID]
+	return ok
+}
+
+// Len returns the number of queued jobs
+func (q *fairQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.index)
+}
+
+// Items returns the queued jobs accepted by filter, highest priority first
+// and oldest first within a priority
+func (q *fairQueue) Items(filter func(*QueueItem) bool) []QueueItem {
+	q.mu.Lock()
+	items := make([]QueueItem, 0, len(q.index))
+	for _, item := range q.index {
+		if filter == nil || filter(item) {
+			items = append(items, *item)
+		}
+	}
+	q.mu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Priority != items[j].Priority {
+			return items[i].Priority > items[j].Priority
+		}
+		return items[i].EnqueuedAt.Before(items[j].EnqueuedAt)
+	})
+	return items
+}
+
+// Counts returns the number of queued jobs per priority
+func (q *fairQueue) Counts() map[Priority]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	counts := make(map[Priority]int, numPriorities)
+	for i := Priority(0); i < numPriorities; i++ {
+		counts[i] = 0
+	}
+	for _, item := range q.index {
+		counts[item.Priority]++
+	}
+	return counts
+}
+
+// SetPaused stops or resumes handing out jobs; pushes are still accepted
+func (q *fairQueue) SetPaused(paused bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.paused = paused
+	if !paused && !q.closed && len(q.index) > 0 {
+		q.signal()
+	}
+}
+
+// Paused reports whether the queue is paused
+func (q *fairQueue) Paused() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.paused
+}
+
+// Capacity returns the maximum number of queued jobs
+func (q *fairQueue) Capacity() int {
+	return q.capacity
+}
+
+// Close rejects further pushes and makes Pop return false
+func (q *fairQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	close(q.ready)
+}
+
+// handOff passes a wake-up a leaving worker may have consumed on to the
+// remaining workers
+func (q *fairQueue) handOff() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed && !q.paused && len(q.index) > 0 {
+		q.signal()
+	}
+}
+
+// signal wakes one waiting Pop; callers hold q.mu
+func (q *fairQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,174 @@
+package crawler
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// job builds a queue item for a URL owned by userID
+func job(urlID, userID uint, priority Priority) QueueItem {
+	return QueueItem{URLID: urlID, UserID: userID, Priority: priority}
+}
+
+// drain pops every job without blocking and returns their URL IDs in order
+func drain(q *fairQueue) []uint {
+	var ids []uint
+	for {
+		item, _ := q.tryPop()
+		if item == nil {
+			return ids
+		}
+		ids = append(ids, item.URLID)
+	}
+}
+
+func TestFairQueuePopOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights [numPriorities]int
+		jobs    []QueueItem
+		want    []uint
+	}{
+		{
+			name:    "users take turns within a priority",
+			weights: [numPriorities]int{1, 1, 1},
+			jobs: []QueueItem{
+				job(1, 1, PriorityBulk), job(2, 1, PriorityBulk), job(3, 1, PriorityBulk),
+				job(4, 2, PriorityBulk), job(5, 2, PriorityBulk),
+			},
+			want: []uint{1, 4, 2, 5, 3},
+		},
+		{
+			name:    "lower priorities get their share",
+			weights: [numPriorities]int{1, 1, 2},
+			jobs: []QueueItem{
+				job(1, 1, PriorityBulk), job(2, 1, PriorityBulk), job(3, 1, PriorityBulk),
+				job(4, 1, PriorityInteractive), job(5, 1, PriorityInteractive), job(6, 1, PriorityInteractive),
+			},
+			want: []uint{4, 1, 5, 6, 2, 3},
+		},
+		{
+			name:    "weights below one count as one",
+			weights: [numPriorities]int{0, 0, 0},
+			jobs:    []QueueItem{job(1, 1, PriorityBulk), job(2, 1, PriorityScheduled), job(3, 1, PriorityInteractive)},
+			want:    []uint{1, 2, 3},
+		},
+		{
+			name:    "pushing again moves a URL up but never down",
+			weights: [numPriorities]int{1, 3, 6},
+			jobs: []QueueItem{
+				job(1, 1, PriorityBulk), job(2, 1, PriorityBulk),
+				job(2, 1, PriorityInteractive), job(2, 1, PriorityBulk),
+			},
+			want: []uint{2, 1},
+		},
+		{
+			name:    "unknown priorities are bulk",
+			weights: [numPriorities]int{1, 3, 6},
+			jobs:    []QueueItem{job(1, 1, Priority(7)), job(2, 1, PriorityScheduled), job(3, 1, Priority(-1))},
+			want:    []uint{2, 1, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newFairQueue(10, tt.weights)
+			for _, j := range tt.jobs {
+				if err := q.Push(j); err != nil {
+					t.Fatalf("Push(%d): %v", j.URLID, err)
+				}
+			}
+			if got := drain(q); !slices.Equal(got, tt.want) {
+				t.Errorf("pop order = %v, want %v", got, tt.want)
+			}
+			if q.Len() != 0 {
+				t.Errorf("Len after draining = %d", q.Len())
+			}
+		})
+	}
+}
+
+func TestFairQueuePush(t *testing.T) {
+	tests := []struct {
+		name   string
+		closed bool
+		job    QueueItem
+		want   error
+	}{
+		{"new URL into a full queue", false, job(2, 1, PriorityInteractive), errQueueFull},
+		{"queued URL into a full queue", false, job(1, 1, PriorityInteractive), nil},
+		{"closed queue", true, job(2, 1, PriorityBulk), errQueueClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newFairQueue(1, [numPriorities]int{1, 3, 6})
+			if err := q.Push(job(1, 1, PriorityBulk)); err != nil {
+				t.Fatal(err)
+			}
+			if tt.closed {
+				q.Close()
+			}
+			if err := q.Push(tt.job); err != tt.want {
+				t.Errorf("Push = %v, want %v", err, tt.want)
+			}
+			if q.Len() != 1 {
+				t.Errorf("Len = %d, want 1", q.Len())
+			}
+		})
+	}
+}
+
+func TestFairQueueRemove(t *testing.T) {
+	q := newFairQueue(10, [numPriorities]int{1, 3, 6})
+	for _, j := range []QueueItem{job(1, 1, PriorityBulk), job(2, 1, PriorityBulk), job(3, 2, PriorityBulk), job(4, 3, PriorityBulk)} {
+		q.Push(j)
+	}
+
+	// User 1 is served and user 2 is next, until all of user 2's URLs go
+	if item, _ := q.tryPop(); item.URLID != 1 {
+		t.Fatalf("first pop = %d, want 1", item.URLID)
+	}
+	if !q.Remove(3) || q.Remove(3) || q.Contains(3) {
+		t.Error("Remove didn't drop URL 3 exactly once")
+	}
+	if got := drain(q); !slices.Equal(got, []uint{4, 2}) {
+		t.Errorf("pop order after Remove = %v, want [4 2]", got)
+	}
+	if counts := q.Counts(); counts[PriorityBulk] != 0 || len(counts) != numPriorities {
+		t.Errorf("Counts of an empty queue = %v", counts)
+	}
+}
+
+func TestFairQueuePauseAndClose(t *testing.T) {
+	q := newFairQueue(10, [numPriorities]int{1, 3, 6})
+	q.SetPaused(true)
+	q.Push(job(1, 1, PriorityInteractive))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if item, ok := q.Pop(ctx); ok {
+		t.Fatalf("Pop from a paused queue returned URL %d", item.URLID)
+	}
+
+	q.SetPaused(false)
+	if item, ok := q.Pop(context.Background()); !ok || item.URLID != 1 {
+		t.Fatalf("Pop after resuming = %v, %v", item, ok)
+	}
+
+	// Waiting workers return once the queue closes
+	popped := make(chan bool)
+	go func() {
+		_, ok := q.Pop(context.Background())
+		popped <- ok
+	}()
+	q.Close()
+	select {
+	case ok := <-popped:
+		if ok {
+			t.Error("Pop returned a job from a closed queue")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Pop still blocked after Close")
+	}
+}
@@ -0,0 +1,205 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+// Scheduler periodically enqueues URLs whose recurring crawl is due
+type Scheduler struct {
+	urls      service.URLRepository
+	crawler   *Service
+	interval  time.Duration
+	maxJitter time.Duration
+	batchSize int
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	isRunning bool
+}
+
+// SchedulerConfig holds scheduler configuration
+type SchedulerConfig struct {
+	// PollInterval is how often the scheduler looks for due URLs
+	PollInterval time.Duration
+	// MaxJitter spreads enqueues of due URLs over a random delay
+	MaxJitter time.Duration
+	// BatchSize limits how many due URLs are claimed per poll
+	BatchSize int
+}
+
+// DefaultSchedulerConfig returns default scheduler configuration
+func DefaultSchedulerConfig() *SchedulerConfig {
+	return &SchedulerConfig{
+		PollInterval: 30 * time.Second,
+		MaxJitter:    30 * time.Second,
+		BatchSize:    100,
+	}
+}
+
+// NewSchedulerConfig creates a scheduler configuration from environment variables
+func NewSchedulerConfig() *SchedulerConfig {
+	config := DefaultSchedulerConfig()
+
+	if v, err := time.ParseDuration(os.Getenv("SCHEDULER_POLL_INTERVAL")); err == nil && v > 0 {
+		config.PollInterval = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("SCHEDULER_MAX_JITTER")); err == nil && v >= 0 {
+		config.MaxJitter = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("SCHEDULER_BATCH_SIZE")); err == nil && v > 0 {
+		config.BatchSize = v
+	}
+
+	return config
+}
+
+// NewScheduler creates a scheduler that enqueues due URLs through the crawler
+func NewScheduler(urls service.URLRepository, crawler *Service, config *SchedulerConfig) *Scheduler {
+	if config == nil {
+		config = DefaultSchedulerConfig()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Scheduler{
+		urls:      urls,
+		crawler:   crawler,
+		interval:  config.PollInterval,
+		maxJitter: config.MaxJitter,
+		batchSize: config.BatchSize,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start starts the scheduler loop
+func (s *Scheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isRunning {
+		return fmt.Errorf("scheduler is already running")
+	}
+
+	s.isRunning = true
+	s.wg.Add(1)
+	go s.run()
+
+	slog.Info("Scheduler started", "poll_interval", s.interval)
+	return nil
+}
+
+// Stop stops the scheduler and drops enqueues still waiting on their jitter
+func (s *Scheduler) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isRunning {
+		return nil
+	}
+
+	s.isRunning = false
+	s.cancel()
+	s.wg.Wait()
+
+	slog.Info("Scheduler stopped")
+	return nil
+}
+
+// run polls for due URLs until the scheduler is stopped
+func (s *Scheduler) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.enqueueDue()
+	for {
+		select {
+		case <-ticker.C:
+			s.enqueueDue()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// enqueueDue claims all due URLs and hands them to the crawler after a random jitter
+func (s *Scheduler) enqueueDue() {
+	now := time.Now()
+
+	urls, err := s.urls.ListDueScheduled(s.ctx, now, s.batchSize)
+	if err != nil {
+		slog.Error("Scheduler failed to list due URLs", "error", err)
+		return
+	}
+
+	for i := range urls {
+		url := &urls[i]
+
+		next, err := service.NextRunTime(url, now)
+		if err != nil {
+			slog.Error("Scheduler failed to compute next run", "url_id", url.ID, "error", err)
+			continue
+		}
+
+		claimed, err := s.urls.ClaimScheduledRun(s.ctx, url, next)
+		if err != nil {
+			slog.Error("Scheduler failed to claim URL", "url_id", url.ID, "error", err)
+			continue
+		}
+		if !claimed {
+			continue // Another scheduler instance got there first
+		}
+
+		s.wg.Add(1)
+		go s.enqueueAfterJitter(url.ID)
+	}
+}
+
+// enqueueAfterJitter waits a random delay before notifying the crawler, so
+// URLs sharing a schedule don't all hit the queue at the same moment
+func (s *Scheduler) enqueueAfterJitter(id uint) {
+	defer s.wg.Done()
+
+	var delay time.Duration
+	if s.maxJitter > 0 {
+		delay = time.Duration(rand.Int63n(int64(s.maxJitter)))
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-s.ctx.Done():
+		return
+	}
+
+	err := s.crawler.Enqueue(s.ctx, id, PriorityScheduled)
+	if errors.Is(err, errQueueFull) || errors.Is(err, errQueueClosed) {
+		// The URL stays queued in the database and is loaded once the queue
+		// has room, or when the crawler starts again
+		slog.Info("Scheduled URL waits for room in the crawl queue", "url_id", id, "reason", err)
+		return
+	}
+	if err != nil {
+		slog.Error("Scheduler failed to enqueue URL", "url_id", id, "error", err)
+		errorMsg := fmt.Sprintf("failed to enqueue scheduled crawl: %v", err)
+		if updateErr := s.urls.UpdateStatus(context.Background(), id, db.StatusError, errorMsg); updateErr != nil {
+			slog.Error("Failed to update URL error status", "url_id", id, "error", updateErr)
+		}
+	}
+}
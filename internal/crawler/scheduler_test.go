@@ -0,0 +1,60 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+func TestSchedulerLeavesURLQueuedWhenQueueIsFull(t *testing.T) {
+	site := &testSite{}
+	site.set("Fresh products every day.", "", http.StatusOK)
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	ctx := context.Background()
+	urls := service.NewMemoryURLRepository()
+
+	// A crawled URL whose recurring crawl is due
+	scheduled, _ := urls.Create(ctx, 1, server.URL+"/?scheduled")
+	urls.UpdateStatus(ctx, scheduled.ID, db.StatusDone, "")
+	due := time.Now().Add(-time.Minute)
+	scheduled.ScheduleInterval = "1h"
+	scheduled.NextRunAt = &due
+	if err := urls.SaveSchedule(ctx, scheduled); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.QueueSize = 1
+	config.RefillInterval = 10 * time.Millisecond
+	s := newTestService(t, urls, config)
+	s.Pause()
+
+	filler, _ := urls.Create(ctx, 1, server.URL+"/?filler")
+	if err := s.Enqueue(ctx, filler.ID, PriorityInteractive); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if s.QueueLength() != s.QueueCapacity() {
+		t.Fatalf("queue length = %d, want it full", s.QueueLength())
+	}
+
+	scheduler := NewScheduler(urls, s, &SchedulerConfig{PollInterval: time.Hour, BatchSize: 10})
+	scheduler.enqueueDue()
+	scheduler.wg.Wait()
+
+	url, _ := urls.GetByID(ctx, scheduled.ID)
+	if url.Status != db.StatusQueued || url.Error != "" {
+		t.Fatalf("scheduled URL = %s %q, want it left queued", url.Status, url.Error)
+	}
+
+	// Once the queue drains, the refill loop picks the URL up
+	s.Resume()
+	waitForStatus(t, urls, filler.ID, db.StatusDone)
+	waitForStatus(t, urls, scheduled.ID, db.StatusDone)
+}
@@ -0,0 +1,179 @@
+package crawler
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+)
+
+// MaxWorkers bounds the worker pool size accepted by SetWorkers
+const MaxWorkers = 100
+
+// throughputWindow is how far back crawl completions are remembered
+const throughputWindow = 5 * time.Minute
+
+// throughput counts finished crawls by outcome and over a sliding window
+type throughput struct {
+	mu     sync.Mutex
+	totals map[db.URLStatus]int64
+	recent []time.Time
+}
+
+// newThroughput creates an empty throughput counter
+func newThroughput() *throughput {
+	return &throughput{totals: make(map[db.URLStatus]int64)}
+}
+
+// record counts a finished crawl with the given outcome
+func (t *throughput) record(outcome db.URLStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.totals[outcome]++
+	t.recent = append(t.prune(now), now)
+}
+
+// prune drops completions older than the window; callers hold t.mu
+func (t *throughput) prune(now time.Time) []time.Time {
+	cutoff := now.Add(-throughputWindow)
+	i := sort.Search(len(t.recent), func(i int) bool { return t.recent[i].After(cutoff) })
+	return t.recent[i:]
+}
+
+// Throughput summarizes finished crawls
+type Throughput struct {
+	Totals          map[db.URLStatus]int64 `json:"totals"`
+	LastMinute      int                    `json:"last_minute"`
+	LastFiveMinutes int                    `json:"last_five_minutes"`
+	PerMinute       float64                `json:"per_minute"` // Average over the last five minutes
+}
+
+// snapshot returns the current throughput figures
+func (t *throughput) snapshot() Throughput {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.recent = t.prune(now)
+
+	totals := make(map[db.URLStatus]int64, len(t.totals))
+	for outcome, n := range t.totals {
+		totals[outcome] = n
+	}
+
+	minuteAgo := now.Add(-time.Minute)
+	lastMinute := len(t.recent) - sort.Search(len(t.recent), func(i int) bool { return t.recent[i].After(minuteAgo) })
+
+	return Throughput{
+		Totals:          totals,
+		LastMinute:      lastMinute,
+		LastFiveMinutes: len(t.recent),
+		PerMinute:       float64(len(t.recent)) / throughputWindow.Minutes(),
+	}
+}
+
+// InflightCrawl describes a crawl currently being processed
+type InflightCrawl struct {
+	URLID     uint      `json:"url_id"`
+	Worker    int       `json:"worker"`
+	StartedAt time.Time `json:"started_at"`
+	ElapsedMs int64     `json:"elapsed_ms"`
+}
+
+// Stats is a snapshot of the crawler state for operators
+type Stats struct {
+	Running         bool             `json:"running"`
+	Paused          bool             `json:"paused"`
+	StartedAt       time.Time        `json:"started_at"`
+	Workers         int              `json:"workers"`
+	ActiveWorkers   int              `json:"active_workers"`
+	InFlight        []InflightCrawl  `json:"in_flight"`
+	QueueLength     int              `json:"queue_length"`
+	QueueCapacity   int              `json:"queue_capacity"`
+	QueueByPriority map[Priority]int `json:"queue_by_priority"`
+	Throughput      Throughput       `json:"throughput"`
+	LinksChecked    uint64           `json:"links_checked"` // Since start
+}
+
+// Stats returns a snapshot of workers, in-flight crawls, queue and throughput
+func (s *Service) Stats() Stats {
+	s.mu.RLock()
+	stats := Stats{
+		Running:   s.isRunning,
+		StartedAt: s.startedAt,
+		Workers:   s.workers,
+	}
+	s.mu.RUnlock()
+
+	now := time.Now()
+	s.inflightMu.Lock()
+	stats.InFlight = make([]InflightCrawl, 0, len(s.inflight))
+	for id, crawl := range s.inflight {
+		stats.InFlight = append(stats.InFlight, InflightCrawl{
+			URLID:     id,
+			Worker:    crawl.worker,
+			StartedAt: crawl.startedAt,
+			ElapsedMs: now.Sub(crawl.startedAt).Milliseconds(),
+		})
+	}
+	s.inflightMu.Unlock()
+	sort.Slice(stats.InFlight, func(i, j int) bool {
+		return stats.InFlight[i].StartedAt.Before(stats.InFlight[j].StartedAt)
+	})
+
+	stats.ActiveWorkers = len(stats.InFlight)
+	stats.Paused = s.queue.Paused()
+	stats.QueueLength = s.queue.Len()
+	stats.QueueCapacity = s.queue.Capacity()
+	stats.QueueByPriority = s.queue.Counts()
+	stats.Throughput = s.throughput.snapshot()
+	stats.LinksChecked = s.linkChecks.Load()
+
+	return stats
+}
+
+// Pause stops workers from picking up queued URLs. Running crawls finish and
+// new URLs are still queued.
+func (s *Service) Pause() {
+	s.queue.SetPaused(true)
+	slog.Info("Crawler paused")
+}
+
+// Resume lets workers pick up queued URLs again
+func (s *Service) Resume() {
+	s.queue.SetPaused(false)
+	slog.Info("Crawler resumed")
+}
+
+// SetWorkers grows or shrinks the worker pool at runtime. Removed workers
+// finish their current crawl before exiting.
+func (s *Service) SetWorkers(n int) error {
+	if n < 1 || n > MaxWorkers {
+		return fmt.Errorf("worker count must be between 1 and %d", MaxWorkers)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isRunning {
+		return fmt.Errorf("crawler service is not running")
+	}
+
+	for len(s.workerStops) < n {
+		s.startWorker()
+	}
+	for len(s.workerStops) > n {
+		last := len(s.workerStops) - 1
+		s.workerStops[last]()
+		s.workerStops = s.workerStops[:last]
+	}
+
+	slog.Info("Crawler worker pool resized", "from", s.workers, "to", n)
+	s.workers = n
+	return nil
+}
@@ -1,12 +1,36 @@
 package db
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Supported database drivers
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
 )
 
+// sqliteDefaults makes concurrent writers wait for each other instead of
+// failing, since workers, the scheduler and handlers share one database file
+const sqliteDefaults = "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+
 // Config holds database configuration
 type Config struct {
+	// Driver is one of mysql, postgres or sqlite
+	Driver string
+	// DSN overrides the discrete connection fields; for sqlite it is the
+	// database file path, or :memory:
+	DSN      string
 	Host     string
 	Port     string
 	User     string
@@ -15,26 +39,93 @@ type Config struct {
 	MaxOpen  int
 	MaxIdle  int
 	Timeout  time.Duration
+	// AutoMigrate applies pending migrations on startup. It is off by
+	// default: deployments run migrate up first and startup fails unless the
+	// schema is up to date
+	AutoMigrate bool
 }
 
-// NewConfig creates a new database configuration from environment variables
+// NewConfig creates a new database configuration from environment variables.
+// The DB_* connection variables fall back to the MYSQL_* ones.
 func NewConfig() *Config {
+	driver := strings.ToLower(getEnvOrDefault("DB_DRIVER", DriverMySQL))
+
+	defaultPort := "3306"
+	if driver == DriverPostgres {
+		defaultPort = "5432"
+	}
+
+	autoMigrate := false
+	if v, err := strconv.ParseBool(os.Getenv("DB_AUTO_MIGRATE")); err == nil {
+		autoMigrate = v
+	}
+
 	return &Config{
-		Host:     getEnvOrDefault("MYSQL_HOST", "localhost"),
-		Port:     getEnvOrDefault("MYSQL_PORT", "3306"),
-		User:     getEnvOrDefault("MYSQL_USER", "root"),
-		Password: getEnvOrDefault("MYSQL_PASSWORD", ""),
-		Database: getEnvOrDefault("MYSQL_DATABASE", "url_crawler"),
+		Driver:   driver,
+		DSN:      os.Getenv("DB_DSN"),
+		Host:     getEnvOrDefault("DB_HOST", getEnvOrDefault("MYSQL_HOST", "localhost")),
+		Port:     getEnvOrDefault("DB_PORT", getEnvOrDefault("MYSQL_PORT", defaultPort)),
+		User:     getEnvOrDefault("DB_USER", getEnvOrDefault("MYSQL_USER", "root")),
+		Password: getEnvOrDefault("DB_PASSWORD", getEnvOrDefault("MYSQL_PASSWORD", "")),
+		Database: getEnvOrDefault("DB_NAME", getEnvOrDefault("MYSQL_DATABASE", "url_crawler")),
 		MaxOpen:  25,
 		MaxIdle:  5,
 		Timeout:  30 * time.Second,
+
+		AutoMigrate: autoMigrate,
 	}
 }
 
+// Dialector returns the GORM dialector for the configured driver
+func (c *Config) Dialector() (gorm.Dialector, error) {
+	switch c.Driver {
+	case DriverMySQL:
+		dsn := c.DSN
+		if dsn == "" {
+			dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4&collation=utf8mb4_unicode_ci",
+				c.User, c.Password, c.Host, c.Port, c.Database)
+		}
+		return mysql.Open(dsn), nil
+	case DriverPostgres:
+		dsn := c.DSN
+		if dsn == "" {
+			dsn = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+				c.Host, c.Port, c.User, c.Password, c.Database)
+		}
+		return postgres.Open(dsn), nil
+	case DriverSQLite:
+		return sqlite.Open(c.sqliteDSN()), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q, expected mysql, postgres or sqlite", c.Driver)
+	}
+}
+
+// sqliteDSN returns the SQLite DSN with locking defaults unless the DSN sets
+// its own pragmas
+func (c *Config) sqliteDSN() string {
+	dsn := c.DSN
+	if dsn == "" {
+		dsn = c.Database + ".db"
+	}
+	if strings.Contains(dsn, "_pragma=") {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + sqliteDefaults
+	}
+	return dsn + "?" + sqliteDefaults
+}
+
+// inMemory reports whether the database lives in memory. Every connection
+// to an in-memory SQLite database opens a new, empty one.
+func (c *Config) inMemory() bool {
+	return c.Driver == DriverSQLite && strings.Contains(c.DSN, ":memory:")
+}
+
 // getEnvOrDefault returns environment variable value or default
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
-} 
\ No newline at end of file
+}
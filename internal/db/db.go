@@ -3,38 +3,61 @@ package db
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
 	"time"
 
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+
+	"github.com/sykell/url-crawler/internal/logging"
 )
 
-// InitDB initializes the database connection with proper configuration
+// InitDB initializes the database connection configured by the environment
 func InitDB() (*gorm.DB, error) {
-	config := NewConfig()
-	
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4&collation=utf8mb4_unicode_ci",
-		config.User, config.Password, config.Host, config.Port, config.Database)
+	return Open(NewConfig())
+}
+
+// Open connects to the configured database and brings its schema up to
+// date, or verifies it is when auto-migration is disabled
+func Open(config *Config) (*gorm.DB, error) {
+	db, err := Connect(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	migrator := NewMigrator(db)
+	if config.AutoMigrate {
+		if _, err := migrator.Up(ctx); err != nil {
+			return nil, fmt.Errorf("failed to run migrations: %w", err)
+		}
+		return db, nil
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check migrations: %w", err)
+	}
+	if pending > 0 {
+		return nil, fmt.Errorf("database schema is %d migrations behind, run the migrate up command or set DB_AUTO_MIGRATE=true", pending)
+	}
+	return db, nil
+}
 
-	// Configure GORM logger
-	gormLogger := logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags),
-		logger.Config{
-			SlowThreshold:             time.Second,
-			LogLevel:                  logger.Warn,
-			IgnoreRecordNotFoundError: true,
-			Colorful:                  false,
-		},
-	)
+// Connect connects to the configured database and checks the connection,
+// leaving the schema alone
+func Connect(config *Config) (*gorm.DB, error) {
+	dialector, err := config.Dialector()
+	if err != nil {
+		return nil, err
+	}
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+	// Route GORM's logging through slog, warning about slow queries
+	gormLogger := logging.NewGormLogger(time.Second)
+
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: gormLogger,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to connect to %s database: %w", config.Driver, err)
 	}
 
 	// Configure connection pool
@@ -46,6 +69,11 @@ func InitDB() (*gorm.DB, error) {
 	sqlDB.SetMaxOpenConns(config.MaxOpen)
 	sqlDB.SetMaxIdleConns(config.MaxIdle)
 	sqlDB.SetConnMaxLifetime(config.Timeout)
+	if config.inMemory() {
+		// Keep a single connection for good, closing it drops the database
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+	}
 
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -55,10 +83,5 @@ func InitDB() (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Run migrations
-	if err := runMigrations(db); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
-	}
-
 	return db, nil
 }
\ No newline at end of file
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// likeEscape is the escape character of patterns built by ContainsPattern.
+// Backslash can't be used portably: MySQL treats it as a string escape too.
+const likeEscape = "!"
+
+// ContainsCondition returns a case-insensitive substring match on column
+// that behaves the same on MySQL, PostgreSQL and SQLite; pass the pattern
+// from ContainsPattern as its argument
+func ContainsCondition(column string) string {
+	return "LOWER(" + column + ") LIKE ? ESCAPE '" + likeEscape + "'"
+}
+
+// ContainsPattern builds the LIKE pattern matching term anywhere, with LIKE
+// wildcards in term matched literally
+func ContainsPattern(term string) string {
+	replacer := strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+	return "%" + replacer.Replace(strings.ToLower(term)) + "%"
+}
+
+// ForUpdate locks the rows a query reads until the transaction ends. SQLite
+// has no row locks and transactions already hold the database write lock
+// from the start (_txlock=immediate), so the clause is omitted there.
+func ForUpdate(tx *gorm.DB) *gorm.DB {
+	if tx.Dialector.Name() == DriverSQLite {
+		return tx
+	}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// ForUpdateSkipLocked is ForUpdate skipping rows other transactions have
+// locked, so concurrent workers claim different rows instead of waiting
+func ForUpdateSkipLocked(tx *gorm.DB) *gorm.DB {
+	if tx.Dialector.Name() == DriverSQLite {
+		return tx
+	}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+}
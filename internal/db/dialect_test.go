@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestContainsPattern(t *testing.T) {
+	tests := []struct {
+		term string
+		want string
+	}{
+		{"Shop", "%shop%"},
+		{"50%", "%50!%%"},
+		{"a_b", "%a!_b%"},
+		{"wow!", "%wow!!%"},
+	}
+	for _, tt := range tests {
+		if got := ContainsPattern(tt.term); got != tt.want {
+			t.Errorf("ContainsPattern(%q) = %q, want %q", tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestContainsConditionOnSQLite(t *testing.T) {
+	dbConn := newTestDB(t)
+	if _, err := NewMigrator(dbConn).Up(context.Background()); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	user := User{Username: "alice", Password: "hash"}
+	dbConn.Create(&user)
+	for _, address := range []string{
+		"https://example.com/SALE/50%_off",
+		"https://example.com/sale/50xoff",
+		"https://example.com/sale/50_off!",
+	} {
+		if err := dbConn.Create(&URL{UserID: user.ID, Address: address}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		term string
+		want int64
+	}{
+		{"sale", 3},
+		{"50%_OFF", 1}, // Wildcards match literally, case is ignored
+		{"50_off", 1},
+		{"off!", 1},
+		{"%", 1},
+	}
+	for _, tt := range tests {
+		var count int64
+		err := dbConn.Model(&URL{}).Where(ContainsCondition("address"), ContainsPattern(tt.term)).Count(&count).Error
+		if err != nil {
+			t.Fatalf("search %q: %v", tt.term, err)
+		}
+		if count != tt.want {
+			t.Errorf("search %q matched %d URLs, want %d", tt.term, count, tt.want)
+		}
+	}
+}
+
+func TestForUpdate(t *testing.T) {
+	pg, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		dbConn *gorm.DB
+		lock   func(*gorm.DB) *gorm.DB
+		want   string
+	}{
+		{"sqlite", newTestDB(t), ForUpdate, ""},
+		{"sqlite", newTestDB(t), ForUpdateSkipLocked, ""},
+		{"postgres", pg, ForUpdate, "FOR UPDATE"},
+		{"postgres", pg, ForUpdateSkipLocked, "FOR UPDATE SKIP LOCKED"},
+	}
+	for _, tt := range tests {
+		sql := tt.dbConn.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tt.lock(tx).Where("status = ?", StatusQueued).Find(&[]URL{})
+		})
+		if tt.want == "" && strings.Contains(sql, "FOR UPDATE") {
+			t.Errorf("%s: %s locks rows", tt.name, sql)
+		}
+		if tt.want != "" && !strings.HasSuffix(sql, tt.want) {
+			t.Errorf("%s: %s doesn't end in %s", tt.name, sql, tt.want)
+		}
+	}
+}
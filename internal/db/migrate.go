@@ -0,0 +1,357 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationLockName identifies the lock held while migrating
+const migrationLockName = "url_crawler_schema_migrations"
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   uint      `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName keeps the conventional migrations table name
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migration is a numbered, reversible schema change. Up and Down run in a
+// transaction where the database supports transactional DDL.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   uint       `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"` // Nil while pending
+}
+
+// Migrator applies migrations, holding a database lock so instances
+// starting at the same time don't migrate concurrently
+type Migrator struct {
+	db          *gorm.DB
+	migrations  []Migration
+	lockTimeout time.Duration
+}
+
+// NewMigrator creates a migrator for the application's migrations
+func NewMigrator(dbConn *gorm.DB) *Migrator {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	return &Migrator{
+		db:          dbConn,
+		migrations:  sorted,
+		lockTimeout: time.Minute,
+	}
+}
+
+// Latest returns the newest migration version
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status lists all migrations, oldest first, with when they were applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns how many migrations have not been applied yet
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Up applies all pending migrations and returns how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down reverts the given number of most recent migrations and returns how
+// many were reverted
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if steps < 1 {
+		return 0, fmt.Errorf("steps must be at least 1")
+	}
+
+	changed := 0
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && changed < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			ran, err := m.revert(conn, migration)
+			if err != nil {
+				return err
+			}
+			if ran {
+				changed++
+			}
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// To migrates up or down to the given version, applying older pending
+// migrations and reverting newer ones. Version 0 reverts everything.
+func (m *Migrator) To(ctx context.Context, version uint) (int, error) {
+	if version != 0 && !m.known(version) {
+		return 0, fmt.Errorf("unknown migration version %d", version)
+	}
+
+	changed := 0
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		// Revert newer migrations first, newest first
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+				continue
+			}
+			ran, err := m.revert(conn, migration)
+			if err != nil {
+				return err
+			}
+			if ran {
+				changed++
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+			ran, err := m.apply(conn, migration)
+			if err != nil {
+				return err
+			}
+			if ran {
+				changed++
+			}
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// known reports whether version is one of the migrations
+func (m *Migrator) known(version uint) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// applied loads the applied migrations by version
+func (m *Migrator) applied(conn *gorm.DB) (map[uint]SchemaMigration, error) {
+	applied := make(map[uint]SchemaMigration)
+	if !conn.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+
+	var records []SchemaMigration
+	if err := conn.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to load applied migrations: %w", err)
+	}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// apply runs a migration's Up and records it. It reports false when
+// another process applied it first.
+func (m *Migrator) apply(conn *gorm.DB, migration Migration) (bool, error) {
+	ran := false
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		// Without an advisory lock (SQLite) another process may have won
+		if done, err := isApplied(tx, migration.Version); err != nil || done {
+			return err
+		}
+		ran = true
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&SchemaMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now().UTC(),
+		}).Error
+	})
+	if err != nil {
+		return false, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+	}
+
+	if ran {
+		slog.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+	}
+	return ran, nil
+}
+
+// revert runs a migration's Down and removes its record. It reports false
+// when another process reverted it first.
+func (m *Migrator) revert(conn *gorm.DB, migration Migration) (bool, error) {
+	ran := false
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if done, err := isApplied(tx, migration.Version); err != nil || !done {
+			return err
+		}
+		ran = true
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&SchemaMigration{}, migration.Version).Error
+	})
+	if err != nil {
+		return false, fmt.Errorf("reverting migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+	}
+
+	if ran {
+		slog.Info("Reverted migration", "version", migration.Version, "name", migration.Name)
+	}
+	return ran, nil
+}
+
+// isApplied reports whether a migration version is recorded
+func isApplied(tx *gorm.DB, version uint) (bool, error) {
+	var count int64
+	err := tx.Model(&SchemaMigration{}).Where("version = ?", version).Count(&count).Error
+	return count > 0, err
+}
+
+// withLock runs fn on a single connection holding the migration lock, and
+// makes sure the migrations table exists
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		// Start each statement afresh while staying on this connection
+		conn = conn.Session(&gorm.Session{NewDB: true})
+
+		if err := m.lock(ctx, conn); err != nil {
+			return err
+		}
+		defer m.unlock(conn)
+
+		// Without an advisory lock another process may create it first
+		if err := conn.AutoMigrate(&SchemaMigration{}); err != nil && !conn.Migrator().HasTable(&SchemaMigration{}) {
+			return fmt.Errorf("failed to create migrations table: %w", err)
+		}
+		return fn(conn)
+	})
+}
+
+// lock waits up to the lock timeout for the migration lock. Locks are
+// session scoped, so conn must stay the same until unlock.
+func (m *Migrator) lock(ctx context.Context, conn *gorm.DB) error {
+	deadline := time.Now().Add(m.lockTimeout)
+	for {
+		acquired, err := tryLock(conn)
+		if err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if acquired {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for the migration lock", m.lockTimeout)
+		}
+
+		slog.Info("Waiting for another instance to finish migrating")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// tryLock takes the migration lock if it is free. SQLite has no advisory
+// locks; its immediate transactions serialize migrations instead.
+func tryLock(conn *gorm.DB) (bool, error) {
+	var acquired bool
+	switch conn.Dialector.Name() {
+	case DriverMySQL:
+		err := conn.Raw("SELECT COALESCE(GET_LOCK(?, 0), 0) = 1", migrationLockName).Row().Scan(&acquired)
+		return acquired, err
+	case DriverPostgres:
+		err := conn.Raw("SELECT pg_try_advisory_lock(?)", migrationLockKey()).Row().Scan(&acquired)
+		return acquired, err
+	default:
+		return true, nil
+	}
+}
+
+// unlock releases the migration lock
+func (m *Migrator) unlock(conn *gorm.DB) {
+	var err error
+	switch conn.Dialector.Name() {
+	case DriverMySQL:
+		err = conn.Exec("SELECT RELEASE_LOCK(?)", migrationLockName).Error
+	case DriverPostgres:
+		err = conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockKey()).Error
+	}
+	if err != nil {
+		slog.Error("Failed to release migration lock", "error", err)
+	}
+}
+
+// migrationLockKey derives the Postgres advisory lock key from the lock name
+func migrationLockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte(migrationLockName))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// newTestDB connects to an empty in-memory SQLite database
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dbConn, err := Connect(&Config{Driver: DriverSQLite, DSN: ":memory:", MaxOpen: 1, MaxIdle: 1, Timeout: time.Minute})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := dbConn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return dbConn
+}
+
+// indexes lists the names of a table's indexes
+func indexes(t *testing.T, dbConn *gorm.DB, table string) []string {
+	t.Helper()
+	var names []string
+	err := dbConn.Raw("SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", table).
+		Scan(&names).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestMigratorUpAndStatus(t *testing.T) {
+	dbConn := newTestDB(t)
+	migrator := NewMigrator(dbConn)
+	ctx := context.Background()
+
+	if pending, err := migrator.Pending(ctx); err != nil || pending != len(migrations) {
+		t.Fatalf("Pending on an empty database = %d, %v; want %d", pending, err, len(migrations))
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("Up applied %d migrations, want %d", applied, len(migrations))
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for i, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("migration %d (%s) not applied", status.Version, status.Name)
+		}
+		if i > 0 && status.Version <= statuses[i-1].Version {
+			t.Errorf("Status isn't ordered by version: %d after %d", status.Version, statuses[i-1].Version)
+		}
+	}
+
+	// The schema matches the models
+	for _, model := range []interface{}{&User{}, &URL{}, &CrawlRun{}, &OutboxEvent{}, &Webhook{}, &WebhookDelivery{}, &AlertRule{}, &PendingAlert{}} {
+		if !dbConn.Migrator().HasTable(model) {
+			t.Errorf("missing table for %T", model)
+		}
+	}
+	if !dbConn.Migrator().HasColumn(&User{}, "DisabledAt") || !dbConn.Migrator().HasColumn(&URL{}, "DeletedAt") ||
+		!dbConn.Migrator().HasColumn(&User{}, "TokenVersion") || !dbConn.Migrator().HasColumn(&URL{}, "QueuePriority") {
+		t.Error("columns of later migrations are missing")
+	}
+
+	if applied, err := migrator.Up(ctx); err != nil || applied != 0 {
+		t.Errorf("second Up = %d, %v; want nothing to apply", applied, err)
+	}
+}
+
+func TestMigratorDownAndTo(t *testing.T) {
+	dbConn := newTestDB(t)
+	migrator := NewMigrator(dbConn)
+	ctx := context.Background()
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	if reverted, err := migrator.Down(ctx, 1); err != nil || reverted != 1 {
+		t.Fatalf("Down(1) = %d, %v", reverted, err)
+	}
+	if pending, _ := migrator.Pending(ctx); pending != 1 {
+		t.Errorf("Pending after Down(1) = %d, want 1", pending)
+	}
+
+	if changed, err := migrator.To(ctx, 2); err != nil || changed != int(migrator.Latest())-3 {
+		t.Fatalf("To(2) = %d, %v", changed, err)
+	}
+	if dbConn.Migrator().HasColumn(&User{}, "DisabledAt") || dbConn.Migrator().HasColumn(&URL{}, "DeletedAt") {
+		t.Error("columns of migrations 3 and 4 still exist after migrating to 2")
+	}
+
+	if changed, err := migrator.To(ctx, migrator.Latest()); err != nil || changed != int(migrator.Latest())-2 {
+		t.Fatalf("To(latest) = %d, %v", changed, err)
+	}
+
+	if changed, err := migrator.To(ctx, 0); err != nil || changed != len(migrations) {
+		t.Fatalf("To(0) = %d, %v", changed, err)
+	}
+	if dbConn.Migrator().HasTable(&URL{}) || dbConn.Migrator().HasTable(&User{}) {
+		t.Error("tables remain after reverting everything")
+	}
+
+	if _, err := migrator.To(ctx, 99); err == nil {
+		t.Error("To(99) succeeded for an unknown version")
+	}
+	if _, err := migrator.Down(ctx, 0); err == nil {
+		t.Error("Down(0) succeeded")
+	}
+}
+
+func TestDropColumnKeepsIndexesAndRows(t *testing.T) {
+	dbConn := newTestDB(t)
+	migrator := NewMigrator(dbConn)
+	ctx := context.Background()
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	user := User{Username: "alice", Password: "hash"}
+	if err := dbConn.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := dbConn.Create(&URL{UserID: user.ID, Address: "https://example.com"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	userIndexes := indexes(t, dbConn, "users")
+	var urlIndexes []string
+	for _, name := range indexes(t, dbConn, "urls") {
+		if name != "idx_urls_deleted_at" {
+			urlIndexes = append(urlIndexes, name)
+		}
+	}
+
+	// Reverting migration 4 drops urls.deleted_at together with its own
+	// index, later ones drop columns without indexes
+	if _, err := migrator.To(ctx, 3); err != nil {
+		t.Fatalf("migrating to 3: %v", err)
+	}
+	if got := indexes(t, dbConn, "urls"); !slices.Equal(got, urlIndexes) {
+		t.Errorf("urls indexes = %v, want %v", got, urlIndexes)
+	}
+	if got := indexes(t, dbConn, "users"); !slices.Equal(got, userIndexes) {
+		t.Errorf("users indexes = %v, want %v", got, userIndexes)
+	}
+
+	// Migration 3 drops users.disabled_at, keeping the unique username index
+	if _, err := migrator.Down(ctx, 1); err != nil {
+		t.Fatalf("reverting migration 3: %v", err)
+	}
+	if got := indexes(t, dbConn, "users"); !slices.Equal(got, userIndexes) {
+		t.Errorf("users indexes = %v, want %v", got, userIndexes)
+	}
+	if err := dbConn.Exec("INSERT INTO users (username, password) VALUES ('alice', 'hash')").Error; err == nil {
+		t.Error("duplicate username accepted, the unique index was lost")
+	}
+
+	var users, urls int64
+	dbConn.Table("users").Count(&users)
+	dbConn.Table("urls").Count(&urls)
+	if users != 1 || urls != 1 {
+		t.Errorf("rows after dropping columns = %d users, %d urls; want 1 each", users, urls)
+	}
+}
@@ -1,52 +1,307 @@
 package db
 
 import (
-	"log"
+	"log/slog"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// runMigrations performs database migrations
-func runMigrations(db *gorm.DB) error {
-	if err := db.AutoMigrate(&User{}, &URL{}); err != nil {
+// migrations is the ordered schema history. Applied migrations must never
+// change; add a new one instead. They use frozen copies of the models so
+// later model changes don't rewrite history.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		// Adopts databases created by AutoMigrate in earlier releases
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AutoMigrate(v1Tables()...)
+		},
+		Down: func(tx *gorm.DB) error {
+			tables := v1Tables()
+			for i := len(tables) - 1; i >= 0; i-- {
+				if err := tx.Migrator().DropTable(tables[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 2,
+		Name:    "assign_orphaned_urls",
+		Up:      assignOrphanedURLs,
+		// Ownership can't be told apart from URLs created by the user since
+		Down: func(tx *gorm.DB) error { return nil },
+	},
+	{
+		Version: 3,
+		Name:    "add_users_disabled_at",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&v3User{}, "DisabledAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, &v3User{}, "DisabledAt")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_urls_deleted_at",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&v4URL{}, "DeletedAt"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&v4URL{}, "DeletedAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex(&v4URL{}, "DeletedAt"); err != nil {
+				return err
+			}
+			return dropColumn(tx, &v4URL{}, "DeletedAt")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add_users_token_version",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&v5User{}, "TokenVersion")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, &v5User{}, "TokenVersion")
+		},
+	},
+	{
+		Version: 6,
+		Name:    "add_urls_queue_priority",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&v6URL{}, "QueuePriority")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, &v6URL{}, "QueuePriority")
+		},
+	},
+}
+
+// dropColumn drops a model's column. GORM's SQLite migrator rebuilds the
+// table for this and loses its indexes, so SQLite drops it in place;
+// migrations use it instead of Migrator().DropColumn.
+func dropColumn(tx *gorm.DB, model interface{}, field string) error {
+	if tx.Dialector.Name() != DriverSQLite {
+		return tx.Migrator().DropColumn(model, field)
+	}
+
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
 		return err
 	}
-	
-	// Handle existing URLs that don't have a user_id
-	return migrateExistingURLs(db)
+	column := field
+	if f := stmt.Schema.LookUpField(field); f != nil {
+		column = f.DBName
+	}
+	return tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: stmt.Table}, clause.Column{Name: column}).Error
 }
 
-// migrateExistingURLs assigns existing URLs without user_id to the first admin user
-func migrateExistingURLs(db *gorm.DB) error {
-	// Check if there are any URLs without user_id
+// assignOrphanedURLs assigns URLs created before users existed to the first user
+func assignOrphanedURLs(tx *gorm.DB) error {
 	var count int64
-	if err := db.Model(&URL{}).Where("user_id = 0 OR user_id IS NULL").Count(&count).Error; err != nil {
+	if err := tx.Table("urls").Where("user_id = 0 OR user_id IS NULL").Count(&count).Error; err != nil {
 		return err
 	}
-	
 	if count == 0 {
-		return nil // No URLs need migration
+		return nil
 	}
-	
-	// Find the first admin user to assign orphaned URLs to
-	var adminUser User
-	if err := db.First(&adminUser).Error; err != nil {
+
+	var adminUser v1User
+	if err := tx.Order("id").First(&adminUser).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			// No users exist yet, that's fine
 			return nil
 		}
 		return err
 	}
-	
-	// Update orphaned URLs to belong to the admin user
-	result := db.Model(&URL{}).Where("user_id = 0 OR user_id IS NULL").Update("user_id", adminUser.ID)
+
+	result := tx.Table("urls").Where("user_id = 0 OR user_id IS NULL").Update("user_id", adminUser.ID)
 	if result.Error != nil {
 		return result.Error
 	}
-	
 	if result.RowsAffected > 0 {
-		log.Printf("Migrated %d orphaned URLs to user %d (%s)", result.RowsAffected, adminUser.ID, adminUser.Username)
+		slog.Info("Migrated orphaned URLs", "count", result.RowsAffected, "user_id", adminUser.ID, "username", adminUser.Username)
 	}
-	
 	return nil
-} 
\ No newline at end of file
+}
+
+// v1Tables lists the initial schema in dependency order
+func v1Tables() []interface{} {
+	return []interface{}{
+		&v1User{}, &v1URL{}, &v1CrawlRun{}, &v1OutboxEvent{},
+		&v1Webhook{}, &v1WebhookDelivery{}, &v1AlertRule{}, &v1PendingAlert{},
+	}
+}
+
+type v1User struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	Username  string `gorm:"uniqueIndex;not null;size:100"`
+	Password  string `gorm:"not null;size:255"`
+	IsAdmin   bool   `gorm:"not null;default:false"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (v1User) TableName() string { return "users" }
+
+type v1URL struct {
+	ID               uint   `gorm:"primaryKey"`
+	UserID           uint   `gorm:"index"`
+	Address          string `gorm:"not null;size:768"`
+	Title            string
+	HTMLVersion      string
+	HeadingCounts    string
+	InternalLinks    int
+	ExternalLinks    int
+	BrokenLinks      int
+	BrokenList       string
+	HasLoginForm     bool
+	Status           string `gorm:"default:'queued'"`
+	Error            string
+	LastRunID        *uint
+	ScheduleInterval string `gorm:"size:32"`
+	ScheduleCron     string `gorm:"size:100"`
+	ScheduleTimezone string `gorm:"size:64"`
+	SchedulePaused   bool
+	NextRunAt        *time.Time `gorm:"index"`
+	LastRunAt        *time.Time
+	ETag             string `gorm:"column:etag;size:255"`
+	LastModified     string `gorm:"size:64"`
+	ContentChanged   bool
+	ContentChangedAt *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	User             v1User `gorm:"foreignKey:UserID"`
+}
+
+func (v1URL) TableName() string { return "urls" }
+
+type v1CrawlRun struct {
+	ID             uint   `gorm:"primaryKey"`
+	URLID          uint   `gorm:"index;not null"`
+	Status         string `gorm:"size:20;not null"`
+	StartedAt      time.Time
+	FinishedAt     *time.Time
+	DurationMs     int64
+	Title          string
+	HTMLVersion    string
+	HeadingCounts  string
+	InternalLinks  int
+	ExternalLinks  int
+	BrokenLinks    int
+	BrokenList     string
+	HasLoginForm   bool
+	MainText       string
+	BodyHash       string `gorm:"size:64"`
+	TextHash       string `gorm:"size:64"`
+	TextSimhash    string `gorm:"size:16"`
+	ContentChanged bool
+	Error          string
+	CreatedAt      time.Time
+}
+
+func (v1CrawlRun) TableName() string { return "crawl_runs" }
+
+type v1OutboxEvent struct {
+	ID           uint   `gorm:"primaryKey"`
+	EventType    string `gorm:"size:50;not null"`
+	Payload      string `gorm:"not null"`
+	CreatedAt    time.Time
+	DispatchedAt *time.Time `gorm:"index"`
+}
+
+func (v1OutboxEvent) TableName() string { return "outbox_events" }
+
+type v1Webhook struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"index;not null"`
+	URL          string `gorm:"not null;size:768"`
+	Secret       string `gorm:"not null;size:255"`
+	Events       string `gorm:"size:255"`
+	Active       bool   `gorm:"not null;default:true"`
+	FailureCount int
+	DisabledAt   *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (v1Webhook) TableName() string { return "webhooks" }
+
+type v1WebhookDelivery struct {
+	ID            uint   `gorm:"primaryKey"`
+	WebhookID     uint   `gorm:"index;not null"`
+	EventType     string `gorm:"size:50;not null"`
+	Payload       string `gorm:"not null"`
+	Status        string `gorm:"size:20;not null;index"`
+	Attempts      int
+	ResponseCode  int
+	Error         string
+	NextAttemptAt *time.Time `gorm:"index"`
+	DeliveredAt   *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (v1WebhookDelivery) TableName() string { return "webhook_deliveries" }
+
+type v1AlertRule struct {
+	ID            uint   `gorm:"primaryKey"`
+	UserID        uint   `gorm:"index;not null"`
+	URLID         *uint  `gorm:"index"`
+	Email         string `gorm:"not null;size:255"`
+	OnError       bool
+	OnBrokenLinks bool
+	Digest        bool
+	LastSentAt    *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (v1AlertRule) TableName() string { return "alert_rules" }
+
+type v1PendingAlert struct {
+	ID        uint `gorm:"primaryKey"`
+	RuleID    uint `gorm:"index;not null"`
+	URLID     uint `gorm:"not null"`
+	RunID     uint
+	Kind      string `gorm:"size:20;not null"`
+	Details   string
+	CreatedAt time.Time
+}
+
+func (v1PendingAlert) TableName() string { return "pending_alerts" }
+
+// v3User describes the disabled_at column added in migration 3
+type v3User struct {
+	DisabledAt *time.Time
+}
+
+func (v3User) TableName() string { return "users" }
+
+// v4URL describes the soft delete column added in migration 4
+type v4URL struct {
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (v4URL) TableName() string { return "urls" }
+
+// v5User describes the token_version column added in migration 5
+type v5User struct {
+	TokenVersion uint `gorm:"not null;default:0"`
+}
+
+func (v5User) TableName() string { return "users" }
+
+// v6URL describes the queue_priority column added in migration 6
+type v6URL struct {
+	QueuePriority int `gorm:"not null;default:0"`
+}
+
+func (v6URL) TableName() string { return "urls" }
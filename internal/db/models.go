@@ -1,6 +1,10 @@
 package db
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type URLStatus string
 
@@ -9,6 +13,12 @@ const (
 	StatusRunning URLStatus = "running"
 	StatusDone    URLStatus = "done"
 	StatusError   URLStatus = "error"
+
+	// StatusCancelled marks a URL whose queued or running crawl was cancelled
+	StatusCancelled URLStatus = "cancelled"
+
+	// StatusUnchanged marks a crawl run answered with 304 Not Modified
+	StatusUnchanged URLStatus = "unchanged"
 )
 
 // URL represents a web page to be crawled
@@ -26,16 +36,169 @@ type URL struct {
 	HasLoginForm  bool      `json:"has_login_form"`
 	Status        URLStatus `gorm:"default:'queued'" json:"status"`
 	Error         string    `json:"error"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	User          User      `gorm:"foreignKey:UserID" json:"-"`
+	LastRunID     *uint     `json:"last_run_id"`
+	// Crawl queue priority the URL was last queued with, so URLs left in the
+	// database are loaded back in order
+	QueuePriority int       `gorm:"not null;default:0" json:"-"`
+	// Recurring crawl schedule: either a Go duration interval or a cron expression
+	ScheduleInterval string     `gorm:"size:32" json:"schedule_interval"`
+	ScheduleCron     string     `gorm:"size:100" json:"schedule_cron"`
+	ScheduleTimezone string     `gorm:"size:64" json:"schedule_timezone"`
+	SchedulePaused   bool       `json:"schedule_paused"`
+	NextRunAt        *time.Time `gorm:"index" json:"next_run_at"`
+	LastRunAt        *time.Time `json:"last_run_at"`
+	// Validators from the last full response, sent on the next crawl
+	ETag             string     `gorm:"column:etag;size:255" json:"etag"`
+	LastModified     string     `gorm:"size:64" json:"last_modified"`
+	// Set when the latest run's main content differs from the previous run
+	ContentChanged   bool       `json:"content_changed"`
+	ContentChangedAt *time.Time `json:"content_changed_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	// Set while the URL is in the trash; GORM hides such URLs from queries
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	User      User           `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// HasSchedule reports whether the URL is configured for recurring crawls
+func (u *URL) HasSchedule() bool {
+	return u.ScheduleInterval != "" || u.ScheduleCron != ""
+}
+
+// CrawlRun records the outcome of a single crawl attempt for a URL
+type CrawlRun struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	URLID         uint       `gorm:"index;not null" json:"url_id"`
+	Status        URLStatus  `gorm:"size:20;not null" json:"status"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+	DurationMs    int64      `json:"duration_ms"`
+	Title         string     `json:"title"`
+	HTMLVersion   string     `json:"html_version"`
+	HeadingCounts string     `json:"heading_counts"` // JSON: {"h1":2,"h2":1...}
+	InternalLinks int        `json:"internal_links"`
+	ExternalLinks int        `json:"external_links"`
+	BrokenLinks   int        `json:"broken_links"`
+	BrokenList    string     `json:"broken_list"` // JSON: [{"url":"...","code":404}]
+	HasLoginForm  bool       `json:"has_login_form"`
+	MainText      string     `json:"-"` // Extracted main content, one block per line
+	// Content fingerprints used for change detection
+	BodyHash       string    `gorm:"size:64" json:"body_hash"`
+	TextHash       string    `gorm:"size:64" json:"text_hash"`
+	TextSimhash    string    `gorm:"size:16" json:"text_simhash"`
+	ContentChanged bool      `json:"content_changed"`
+	Error          string    `json:"error"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// HasResults reports whether the run carries crawl results
+func (r *CrawlRun) HasResults() bool {
+	return r.Status == StatusDone || r.Status == StatusUnchanged
+}
+
+// SetResults copies the crawl results and fingerprints of another run
+func (r *CrawlRun) SetResults(from *CrawlRun) {
+	r.Title = from.Title
+	r.HTMLVersion = from.HTMLVersion
+	r.HeadingCounts = from.HeadingCounts
+	r.InternalLinks = from.InternalLinks
+	r.ExternalLinks = from.ExternalLinks
+	r.BrokenLinks = from.BrokenLinks
+	r.BrokenList = from.BrokenList
+	r.HasLoginForm = from.HasLoginForm
+	r.MainText = from.MainText
+	r.BodyHash = from.BodyHash
+	r.TextHash = from.TextHash
+	r.TextSimhash = from.TextSimhash
 }
 
 // User represents an authenticated user
 type User struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	Username  string    `gorm:"uniqueIndex;not null;size:100" json:"username"`
-	Password  string    `gorm:"not null;size:255" json:"-"`
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Username     string     `gorm:"uniqueIndex;not null;size:100" json:"username"`
+	Password     string     `gorm:"not null;size:255" json:"-"`
+	IsAdmin      bool       `gorm:"not null;default:false" json:"is_admin"`
+	DisabledAt   *time.Time `json:"disabled_at"`                     // Set while the account may not log in
+	TokenVersion uint       `gorm:"not null;default:0" json:"-"` // Signed into tokens; bumping it revokes them
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// OutboxEvent persists a published event until all bus subscribers handled it
+type OutboxEvent struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	EventType    string     `gorm:"size:50;not null" json:"event_type"`
+	Payload      string     `gorm:"not null" json:"payload"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DispatchedAt *time.Time `gorm:"index" json:"dispatched_at"`
+}
+
+// WebhookDeliveryStatus represents the state of a webhook delivery
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "pending"
+	DeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	DeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// Webhook is a user-registered endpoint notified about crawl events
+type Webhook struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"index;not null" json:"user_id"`
+	URL          string     `gorm:"not null;size:768" json:"url"`
+	Secret       string     `gorm:"not null;size:255" json:"-"`
+	Events       string     `gorm:"size:255" json:"-"` // Comma separated event types, empty for all
+	Active       bool       `gorm:"not null;default:true" json:"active"`
+	FailureCount int        `json:"failure_count"` // Consecutive failed attempts
+	DisabledAt   *time.Time `json:"disabled_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// WebhookDelivery logs a single event delivery to a webhook, including retries
+type WebhookDelivery struct {
+	ID            uint                  `gorm:"primaryKey" json:"id"`
+	WebhookID     uint                  `gorm:"index;not null" json:"webhook_id"`
+	EventType     string                `gorm:"size:50;not null" json:"event_type"`
+	Payload       string                `gorm:"not null" json:"payload"`
+	Status        WebhookDeliveryStatus `gorm:"size:20;not null;index" json:"status"`
+	Attempts      int                   `json:"attempts"`
+	ResponseCode  int                   `json:"response_code"`
+	Error         string                `json:"error"`
+	NextAttemptAt *time.Time            `gorm:"index" json:"next_attempt_at"`
+	DeliveredAt   *time.Time            `json:"delivered_at"`
+	CreatedAt     time.Time             `json:"created_at"`
+	UpdatedAt     time.Time             `json:"updated_at"`
+}
+
+// Alert kinds recorded on pending alerts
+const (
+	AlertKindError       = "error"
+	AlertKindBrokenLinks = "broken_links"
+)
+
+// AlertRule describes when a user is emailed about problems with their URLs
+type AlertRule struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"index;not null" json:"user_id"`
+	URLID         *uint      `gorm:"index" json:"url_id"` // Nil applies the rule to all of the user's URLs
+	Email         string     `gorm:"not null;size:255" json:"email"`
+	OnError       bool       `json:"on_error"`        // URL starts failing
+	OnBrokenLinks bool       `json:"on_broken_links"` // URL gains broken links
+	Digest        bool       `json:"digest"`          // Batch alerts into an hourly email
+	LastSentAt    *time.Time `json:"last_sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// PendingAlert is an alert waiting to be sent with the next digest
+type PendingAlert struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	RuleID    uint      `gorm:"index;not null" json:"rule_id"`
+	URLID     uint      `gorm:"not null" json:"url_id"`
+	RunID     uint      `json:"run_id"`
+	Kind      string    `gorm:"size:20;not null" json:"kind"`
+	Details   string    `json:"details"` // JSON: error message or newly broken links
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-} 
\ No newline at end of file
+}
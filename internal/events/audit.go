@@ -0,0 +1,24 @@
+package events
+
+import "log/slog"
+
+// AuditLog is a bus handler writing an audit trail record for every durable event
+func AuditLog(event Event) {
+	logger := slog.With("component", "audit", "event", event.EventType())
+
+	switch e := event.(type) {
+	case URLQueued:
+		logger.Info("Audit", "url_id", e.URLID, "user_id", e.UserID)
+	case CrawlStarted:
+		logger.Info("Audit", "url_id", e.URLID, "user_id", e.UserID, "run_id", e.RunID, "address", e.Address)
+	case CrawlSucceeded:
+		logger.Info("Audit", "url_id", e.URLID, "user_id", e.UserID, "run_id", e.RunID, "broken_links", e.BrokenLinks,
+			"changed", e.ContentChanged, "not_modified", e.NotModified, "duration", e.Duration)
+	case CrawlFailed:
+		logger.Info("Audit", "url_id", e.URLID, "user_id", e.UserID, "run_id", e.RunID, "error", e.Error, "error_class", e.ErrorClass, "duration", e.Duration)
+	case CrawlCancelled:
+		logger.Info("Audit", "url_id", e.URLID, "user_id", e.UserID, "run_id", e.RunID, "duration", e.Duration)
+	case LinkBroken:
+		logger.Info("Audit", "url_id", e.URLID, "user_id", e.UserID, "run_id", e.RunID, "link", e.Link, "code", e.StatusCode)
+	}
+}
@@ -0,0 +1,301 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/sykell/url-crawler/internal/db"
+)
+
+// subscriberBuffer is how many events may wait for a subscriber before
+// further events for it are dropped
+const subscriberBuffer = 256
+
+// Handler consumes events delivered by the bus
+type Handler func(Event)
+
+// subscriber runs a handler on its own goroutine so slow consumers don't
+// delay each other
+type subscriber struct {
+	name    string
+	types   map[string]bool
+	handler Handler
+	queue   chan envelope
+	dropped atomic.Uint64
+	// durable subscribers must see every stored event, so the outbox waits
+	// for them and replays what they missed
+	durable bool
+}
+
+// envelope carries an event to subscribers; done, if set, is signalled once
+// per durable subscriber so the outbox row can be marked dispatched
+type envelope struct {
+	event Event
+	done  *sync.WaitGroup
+}
+
+// Bus is an in-process publish/subscribe bus for crawl events. With an
+// outbox enabled, durable events are stored before dispatch and replayed to
+// durable subscribers on the next start if the process exited before all of
+// them handled the events.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []*subscriber
+	outbox      *gorm.DB
+	wg          sync.WaitGroup
+	// sending counts dispatches in progress; Close waits for them before
+	// closing subscriber queues
+	sending sync.WaitGroup
+	closed  bool
+}
+
+// NewBus creates an empty bus
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// EnableOutbox makes the bus persist durable events to the outbox_events table
+func (b *Bus) EnableOutbox(dbConn *gorm.DB) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.outbox = dbConn
+}
+
+// Subscribe registers a handler for the given event types, or for all
+// events if none are given. Subscribers should be registered before the
+// first Publish or ReplayOutbox.
+func (b *Bus) Subscribe(name string, handler Handler, types ...string) {
+	b.subscribe(name, false, handler, types)
+}
+
+// SubscribeDurable is Subscribe for handlers with lasting side effects, such
+// as webhooks. Stored events stay in the outbox until durable subscribers
+// handled them and are replayed to them, at least once, after a restart.
+func (b *Bus) SubscribeDurable(name string, handler Handler, types ...string) {
+	b.subscribe(name, true, handler, types)
+}
+
+// subscribe registers a subscriber and starts its goroutine
+func (b *Bus) subscribe(name string, durable bool, handler Handler, types []string) {
+	sub := &subscriber{
+		name:    name,
+		handler: handler,
+		queue:   make(chan envelope, subscriberBuffer),
+		durable: durable,
+	}
+	if len(types) > 0 {
+		sub.types = make(map[string]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, sub)
+	b.mu.Unlock()
+
+	b.wg.Add(1)
+	go b.run(sub)
+}
+
+// Publish delivers an event to all interested subscribers without blocking.
+// A subscriber whose buffer is full misses the event; if it is a durable
+// subscriber, the stored event stays undispatched and is replayed on the next
+// start.
+func (b *Bus) Publish(event Event) {
+	b.mu.RLock()
+	outbox := b.outbox
+	b.mu.RUnlock()
+
+	var outboxID uint
+	if outbox != nil && durable(event.EventType()) {
+		id, err := b.store(outbox, event)
+		if err != nil {
+			// Still deliver in-process, only durability is lost
+			slog.Error("Failed to write event to outbox", "event", event.EventType(), "error", err)
+		}
+		outboxID = id
+	}
+
+	b.dispatch(event, outboxID, false)
+}
+
+// ReplayOutbox dispatches durable events that were stored but not handled
+// by every durable subscriber before the last shutdown to the durable
+// subscribers, then purges dispatched events older than retention
+func (b *Bus) ReplayOutbox(retention time.Duration) error {
+	b.mu.RLock()
+	outbox := b.outbox
+	b.mu.RUnlock()
+
+	if outbox == nil {
+		return nil
+	}
+
+	var pending []db.OutboxEvent
+	if err := outbox.Where("dispatched_at IS NULL").Order("id asc").Find(&pending).Error; err != nil {
+		return fmt.Errorf("failed to load outbox: %w", err)
+	}
+
+	for _, row := range pending {
+		event, err := decode(row.EventType, []byte(row.Payload))
+		if err != nil {
+			slog.Warn("Skipping undecodable outbox event", "outbox_id", row.ID, "error", err)
+			b.markDispatched(outbox, row.ID)
+			continue
+		}
+		// Startup can wait for subscribers to catch up with the backlog
+		b.dispatch(event, row.ID, true)
+	}
+	if len(pending) > 0 {
+		slog.Info("Replayed undispatched events from outbox", "count", len(pending))
+	}
+
+	cutoff := time.Now().Add(-retention)
+	if err := outbox.Where("dispatched_at < ?", cutoff).Delete(&db.OutboxEvent{}).Error; err != nil {
+		return fmt.Errorf("failed to purge outbox: %w", err)
+	}
+	return nil
+}
+
+// Close stops accepting events and waits for subscribers to drain their queues
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	// Dispatches in progress still hand their events to the subscribers
+	b.sending.Wait()
+	for _, sub := range b.subscribers {
+		close(sub.queue)
+	}
+	b.wg.Wait()
+}
+
+// dispatch hands an event to every matching subscriber, or only to durable
+// ones when replaying, and marks its outbox row dispatched once all durable
+// subscribers have handled it. Replays wait for room in the subscribers'
+// buffers; otherwise subscribers with a full buffer miss the event.
+func (b *Bus) dispatch(event Event, outboxID uint, replay bool) {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		slog.Warn("Dropping event published after bus close", "event", event.EventType())
+		return
+	}
+	subscribers := b.subscribers
+	outbox := b.outbox
+	b.sending.Add(1)
+	b.mu.RUnlock()
+	defer b.sending.Done()
+
+	done := &sync.WaitGroup{}
+	complete := true
+	for _, sub := range subscribers {
+		if sub.types != nil && !sub.types[event.EventType()] {
+			continue
+		}
+		if replay && !sub.durable {
+			continue
+		}
+		env := envelope{event: event}
+		if sub.durable {
+			done.Add(1)
+			env.done = done
+		}
+		if replay {
+			sub.queue <- env
+			continue
+		}
+		select {
+		case sub.queue <- env:
+		default:
+			// Never let a slow subscriber stall the publisher
+			if sub.durable {
+				done.Done()
+				complete = false
+			}
+			sub.dropped.Add(1)
+			slog.Warn("Dropping event for slow subscriber", "subscriber", sub.name, "event", event.EventType())
+		}
+	}
+
+	if outboxID != 0 && complete {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			done.Wait()
+			b.markDispatched(outbox, outboxID)
+		}()
+	}
+}
+
+// Dropped returns how many events each subscriber missed because its
+// buffer was full
+func (b *Bus) Dropped() map[string]uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	dropped := make(map[string]uint64, len(b.subscribers))
+	for _, sub := range b.subscribers {
+		dropped[sub.name] = sub.dropped.Load()
+	}
+	return dropped
+}
+
+// run delivers queued events to a subscriber's handler
+func (b *Bus) run(sub *subscriber) {
+	defer b.wg.Done()
+
+	for env := range sub.queue {
+		b.handle(sub, env)
+	}
+}
+
+// handle invokes a handler, isolating the bus from handler panics
+func (b *Bus) handle(sub *subscriber, env envelope) {
+	if env.done != nil {
+		defer env.done.Done()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Event subscriber panicked", "subscriber", sub.name, "event", env.event.EventType(), "panic", r)
+		}
+	}()
+
+	sub.handler(env.event)
+}
+
+// store writes an event to the outbox and returns its row ID
+func (b *Bus) store(outbox *gorm.DB, event Event) (uint, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+
+	row := db.OutboxEvent{
+		EventType: event.EventType(),
+		Payload:   string(payload),
+	}
+	if err := outbox.Create(&row).Error; err != nil {
+		return 0, err
+	}
+	return row.ID, nil
+}
+
+// markDispatched records that every subscriber handled an outbox event
+func (b *Bus) markDispatched(outbox *gorm.DB, id uint) {
+	if err := outbox.Model(&db.OutboxEvent{}).Where("id = ?", id).Update("dispatched_at", time.Now()).Error; err != nil {
+		slog.Error("Failed to mark outbox event dispatched", "outbox_id", id, "error", err)
+	}
+}
@@ -0,0 +1,156 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/sykell/url-crawler/internal/db"
+)
+
+// newTestOutbox opens a migrated in-memory SQLite database for the outbox
+func newTestOutbox(t *testing.T) *gorm.DB {
+	t.Helper()
+	dbConn, err := db.Open(&db.Config{
+		Driver:      db.DriverSQLite,
+		DSN:         ":memory:",
+		MaxOpen:     1,
+		MaxIdle:     1,
+		Timeout:     time.Minute,
+		AutoMigrate: true,
+	})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := dbConn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return dbConn
+}
+
+// recorder counts the events a subscriber handled
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) handle(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+// undispatched counts the outbox rows not handled by every durable subscriber
+func undispatched(t *testing.T, outbox *gorm.DB) int64 {
+	t.Helper()
+	var count int64
+	if err := outbox.Model(&db.OutboxEvent{}).Where("dispatched_at IS NULL").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestReplayOutboxOnlyReachesDurableSubscribers(t *testing.T) {
+	outbox := newTestOutbox(t)
+	bus := NewBus()
+	bus.EnableOutbox(outbox)
+
+	// Left over from a previous run
+	if _, err := bus.store(outbox, CrawlSucceeded{URLID: 1, UserID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bus.store(outbox, CrawlFailed{URLID: 2, UserID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	realtime, webhooks, alerts := &recorder{}, &recorder{}, &recorder{}
+	bus.Subscribe("realtime", realtime.handle)
+	bus.SubscribeDurable("webhooks", webhooks.handle, TypeCrawlSucceeded, TypeCrawlFailed)
+	bus.SubscribeDurable("alerts", alerts.handle, TypeCrawlFailed)
+
+	if err := bus.ReplayOutbox(time.Hour); err != nil {
+		t.Fatalf("ReplayOutbox: %v", err)
+	}
+	bus.Close()
+
+	tests := []struct {
+		name string
+		got  *recorder
+		want int
+	}{
+		{"realtime", realtime, 0},
+		{"webhooks", webhooks, 2},
+		{"alerts", alerts, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.got.count(); got != tt.want {
+			t.Errorf("%s handled %d replayed events, want %d", tt.name, got, tt.want)
+		}
+	}
+	if left := undispatched(t, outbox); left != 0 {
+		t.Errorf("%d outbox events left undispatched after replay", left)
+	}
+}
+
+func TestPublishWaitsOnlyForDurableSubscribers(t *testing.T) {
+	tests := []struct {
+		name             string
+		slowDurable      bool
+		wantUndispatched int64
+	}{
+		{"slow realtime subscriber", false, 0},
+		{"slow durable subscriber", true, 1},
+	}
+	for _, tt := range tests {
+		outbox := newTestOutbox(t)
+		bus := NewBus()
+		bus.EnableOutbox(outbox)
+
+		started, release := make(chan struct{}, 1), make(chan struct{})
+		slow := func(Event) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-release
+		}
+		fast := &recorder{}
+		if tt.slowDurable {
+			bus.SubscribeDurable("slow", slow)
+			bus.Subscribe("fast", fast.handle)
+		} else {
+			bus.Subscribe("slow", slow)
+			bus.SubscribeDurable("fast", fast.handle)
+		}
+
+		// One event blocks the slow handler, the buffer holds the next ones
+		// and the last is dropped
+		bus.Publish(CrawlSucceeded{URLID: 1, UserID: 1})
+		<-started
+		for i := 1; i < subscriberBuffer+2; i++ {
+			bus.Publish(CrawlSucceeded{URLID: uint(i + 1), UserID: 1})
+		}
+		if dropped := bus.Dropped()["slow"]; dropped != 1 {
+			t.Errorf("%s: dropped %d events, want 1", tt.name, dropped)
+		}
+		close(release)
+		bus.Close()
+
+		if got := fast.count(); got != subscriberBuffer+2 {
+			t.Errorf("%s: fast subscriber handled %d events, want %d", tt.name, got, subscriberBuffer+2)
+		}
+		if left := undispatched(t, outbox); left != tt.wantUndispatched {
+			t.Errorf("%s: %d outbox events left undispatched, want %d", tt.name, left, tt.wantUndispatched)
+		}
+	}
+}
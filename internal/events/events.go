@@ -0,0 +1,166 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Event type names, also used as the outbox event_type column
+const (
+	TypeURLQueued      = "url.queued"
+	TypeCrawlStarted   = "crawl.started"
+	TypeCrawlProgress  = "crawl.progress"
+	TypeCrawlSucceeded = "crawl.succeeded"
+	TypeCrawlFailed    = "crawl.failed"
+	TypeCrawlCancelled = "crawl.cancelled"
+	TypeLinkBroken     = "link.broken"
+)
+
+// Event is implemented by every event published on the bus
+type Event interface {
+	// EventType returns one of the Type* constants
+	EventType() string
+	// Owner returns the user the affected URL belongs to
+	Owner() uint
+}
+
+// URLQueued is published when a URL is put on the crawl queue
+type URLQueued struct {
+	URLID      uint      `json:"url_id"`
+	UserID     uint      `json:"user_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// CrawlStarted is published when a worker starts a crawl run
+type CrawlStarted struct {
+	URLID      uint      `json:"url_id"`
+	UserID     uint      `json:"user_id"`
+	RunID      uint      `json:"run_id"`
+	Address    string    `json:"address"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// CrawlProgress reports link check progress of a running crawl.
+// It is transient and never written to the outbox.
+type CrawlProgress struct {
+	URLID        uint      `json:"url_id"`
+	UserID       uint      `json:"user_id"`
+	RunID        uint      `json:"run_id"`
+	LinksChecked int       `json:"links_checked"`
+	LinksTotal   int       `json:"links_total"`
+	OccurredAt   time.Time `json:"occurred_at"`
+}
+
+// CrawlSucceeded is published when a crawl run completes
+type CrawlSucceeded struct {
+	URLID          uint          `json:"url_id"`
+	UserID         uint          `json:"user_id"`
+	RunID          uint          `json:"run_id"`
+	Address        string        `json:"address"`
+	BrokenLinks    int           `json:"broken_links"`
+	ContentChanged bool          `json:"content_changed"`
+	NotModified    bool          `json:"not_modified"`
+	Duration       time.Duration `json:"duration"`
+	OccurredAt     time.Time     `json:"occurred_at"`
+}
+
+// CrawlFailed is published when a crawl run ends with an error
+type CrawlFailed struct {
+	URLID      uint          `json:"url_id"`
+	UserID     uint          `json:"user_id"`
+	RunID      uint          `json:"run_id"`
+	Address    string        `json:"address"`
+	Error      string        `json:"error"`
+	ErrorClass string        `json:"error_class"`
+	Duration   time.Duration `json:"duration"`
+	OccurredAt time.Time     `json:"occurred_at"`
+}
+
+// CrawlCancelled is published when a queued or running crawl is cancelled.
+// RunID is zero when the URL was still waiting in the queue.
+type CrawlCancelled struct {
+	URLID      uint          `json:"url_id"`
+	UserID     uint          `json:"user_id"`
+	RunID      uint          `json:"run_id"`
+	Address    string        `json:"address"`
+	Duration   time.Duration `json:"duration"`
+	OccurredAt time.Time     `json:"occurred_at"`
+}
+
+// LinkBroken is published for each broken link found by a crawl run
+type LinkBroken struct {
+	URLID      uint      `json:"url_id"`
+	UserID     uint      `json:"user_id"`
+	RunID      uint      `json:"run_id"`
+	Link       string    `json:"link"`
+	StatusCode int       `json:"status_code"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func (e URLQueued) EventType() string      { return TypeURLQueued }
+func (e CrawlStarted) EventType() string   { return TypeCrawlStarted }
+func (e CrawlProgress) EventType() string  { return TypeCrawlProgress }
+func (e CrawlSucceeded) EventType() string { return TypeCrawlSucceeded }
+func (e CrawlFailed) EventType() string    { return TypeCrawlFailed }
+func (e CrawlCancelled) EventType() string { return TypeCrawlCancelled }
+func (e LinkBroken) EventType() string     { return TypeLinkBroken }
+
+func (e URLQueued) Owner() uint      { return e.UserID }
+func (e CrawlStarted) Owner() uint   { return e.UserID }
+func (e CrawlProgress) Owner() uint  { return e.UserID }
+func (e CrawlSucceeded) Owner() uint { return e.UserID }
+func (e CrawlFailed) Owner() uint    { return e.UserID }
+func (e CrawlCancelled) Owner() uint { return e.UserID }
+func (e LinkBroken) Owner() uint     { return e.UserID }
+
+// durable reports whether an event type is persisted to the outbox
+func durable(eventType string) bool {
+	return eventType != TypeCrawlProgress
+}
+
+// decode rebuilds a typed event from its outbox representation
+func decode(eventType string, payload []byte) (Event, error) {
+	var event Event
+	switch eventType {
+	case TypeURLQueued:
+		var e URLQueued
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		event = e
+	case TypeCrawlStarted:
+		var e CrawlStarted
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		event = e
+	case TypeCrawlSucceeded:
+		var e CrawlSucceeded
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		event = e
+	case TypeCrawlFailed:
+		var e CrawlFailed
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		event = e
+	case TypeCrawlCancelled:
+		var e CrawlCancelled
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		event = e
+	case TypeLinkBroken:
+		var e LinkBroken
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		event = e
+	default:
+		return nil, fmt.Errorf("unknown event type %q", eventType)
+	}
+	return event, nil
+}
@@ -0,0 +1,44 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// CrawlerState is the part of the crawler the checks inspect
+type CrawlerState interface {
+	IsRunning() bool
+	QueueLength() int
+	QueueCapacity() int
+}
+
+// DatabaseCheck pings the database
+func DatabaseCheck(sqlDB *sql.DB) CheckFunc {
+	return func(ctx context.Context) error {
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// CrawlerCheck fails when the crawler's workers are not running
+func CrawlerCheck(crawler CrawlerState) CheckFunc {
+	return func(ctx context.Context) error {
+		if !crawler.IsRunning() {
+			return errors.New("crawler service is not running")
+		}
+		return nil
+	}
+}
+
+// QueueCheck fails when the crawl queue is filled beyond threshold (0-1),
+// since new submissions would soon be rejected
+func QueueCheck(crawler CrawlerState, threshold float64) CheckFunc {
+	return func(ctx context.Context) error {
+		length, capacity := crawler.QueueLength(), crawler.QueueCapacity()
+		if capacity > 0 && float64(length) >= threshold*float64(capacity) {
+			return fmt.Errorf("crawl queue saturated: %d of %d slots used", length, capacity)
+		}
+		return nil
+	}
+}
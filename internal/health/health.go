@@ -0,0 +1,160 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Status values reported for checks and overall health
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDraining = "draining"
+)
+
+// checkTimeout bounds how long a single check may take
+const checkTimeout = 2 * time.Second
+
+// CheckFunc reports an error when a dependency is unhealthy
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the response body of the health endpoints
+type Report struct {
+	Status    string                 `json:"status"`
+	Service   string                 `json:"service"`
+	Timestamp time.Time              `json:"timestamp"`
+	Checks    map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// namedCheck is a registered readiness check
+type namedCheck struct {
+	name  string
+	check CheckFunc
+}
+
+// Checker runs readiness checks and tracks whether the server is draining
+type Checker struct {
+	service  string
+	mu       sync.RWMutex
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// NewChecker creates a checker for the named service
+func NewChecker(service string) *Checker {
+	return &Checker{service: service}
+}
+
+// Register adds a readiness check
+func (h *Checker) Register(name string, check CheckFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+}
+
+// SetDraining marks the server as shutting down; readiness fails from then on
+// so load balancers stop routing new requests to it
+func (h *Checker) SetDraining() {
+	h.draining.Store(true)
+}
+
+// Draining reports whether the server is shutting down
+func (h *Checker) Draining() bool {
+	return h.draining.Load()
+}
+
+// Ready runs all checks concurrently and reports the combined result
+func (h *Checker) Ready(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := append([]namedCheck(nil), h.checks...)
+	h.mu.RUnlock()
+
+	report := Report{
+		Status:    StatusUp,
+		Service:   h.service,
+		Timestamp: time.Now().UTC(),
+		Checks:    make(map[string]CheckResult, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c namedCheck) {
+			defer wg.Done()
+			result := run(ctx, c.check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = result
+		}(c)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	if h.Draining() {
+		report.Status = StatusDraining
+	}
+
+	return report
+}
+
+// run executes a check with a timeout and measures its latency
+func run(ctx context.Context, check CheckFunc) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{
+		Status:    StatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LiveHandler reports that the process is up and serving requests. It does
+// not check dependencies, so an outage doesn't get the process restarted.
+func (h *Checker) LiveHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, Report{
+			Status:    StatusUp,
+			Service:   h.service,
+			Timestamp: time.Now().UTC(),
+		})
+	}
+}
+
+// ReadyHandler reports whether the server can take traffic: all checks pass
+// and it isn't draining. It answers 503 otherwise.
+func (h *Checker) ReadyHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := h.Ready(c.Request.Context())
+
+		status := http.StatusOK
+		if report.Status != StatusUp {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// GormLogger routes GORM's logging through slog. Failed queries are logged
+// as errors, slow queries as warnings and, at debug level, every query.
+type GormLogger struct {
+	level         logger.LogLevel
+	slowThreshold time.Duration
+}
+
+// NewGormLogger creates a GORM logger reporting queries slower than slowThreshold
+func NewGormLogger(slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{level: logger.Info, slowThreshold: slowThreshold}
+}
+
+// LogMode returns a copy of the logger using the given GORM log level
+func (l *GormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+// Info logs a GORM info message
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, args...), "component", "gorm")
+	}
+}
+
+// Warn logs a GORM warning
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, args...), "component", "gorm")
+	}
+}
+
+// Error logs a GORM error
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, args...), "component", "gorm")
+	}
+}
+
+// Trace logs a finished query
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "Query failed", "component", "gorm", "error", err, "sql", sql, "rows", rows, "elapsed", elapsed)
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= logger.Warn:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "Slow query", "component", "gorm", "sql", sql, "rows", rows, "elapsed", elapsed, "threshold", l.slowThreshold)
+	case l.level >= logger.Info && slog.Default().Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		slog.DebugContext(ctx, "Query", "component", "gorm", "sql", sql, "rows", rows, "elapsed", elapsed)
+	}
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config holds logging configuration
+type Config struct {
+	Level  slog.Level
+	Format string // json or text
+}
+
+// NewConfig creates a logging configuration from environment variables
+func NewConfig() *Config {
+	config := &Config{
+		Level:  slog.LevelInfo,
+		Format: strings.ToLower(os.Getenv("LOG_FORMAT")),
+	}
+
+	if config.Format != "text" {
+		config.Format = "json"
+	}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := config.Level.UnmarshalText([]byte(v)); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid LOG_LEVEL %q, using info\n", v)
+		}
+	}
+
+	return config
+}
+
+// Init installs the default slog logger writing to w. Output of the standard
+// log package is routed through it as well.
+func Init(w io.Writer, config *Config) *slog.Logger {
+	logger := slog.New(NewHandler(w, config))
+	slog.SetDefault(logger)
+	return logger
+}
+
+// NewHandler creates a handler writing to w in the configured format that
+// adds correlation IDs from the context to every record
+func NewHandler(w io.Writer, config *Config) slog.Handler {
+	opts := &slog.HandlerOptions{Level: config.Level}
+
+	var handler slog.Handler
+	if config.Format == "text" {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	return &contextHandler{Handler: handler}
+}
+
+// Fatal logs an error and exits the process
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// contextHandler adds the request ID and trace IDs found in the context
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds correlation attributes before passing the record on
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler that keeps adding correlation attributes
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a handler that keeps adding correlation attributes
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
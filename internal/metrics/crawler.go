@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sykell/url-crawler/internal/crawler"
+	"github.com/sykell/url-crawler/internal/events"
+)
+
+// CrawlerEvents are the event types HandleEvent records
+var CrawlerEvents = []string{
+	events.TypeCrawlSucceeded, events.TypeCrawlFailed, events.TypeCrawlCancelled, events.TypeLinkBroken,
+}
+
+var (
+	crawlsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "crawls_total",
+		Help:      "Finished crawls by outcome (done, unchanged, error, cancelled) and error class.",
+	}, []string{"outcome", "error_class"})
+
+	crawlDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "crawl_duration_seconds",
+		Help:      "Duration of crawl runs including link checks, by outcome.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"outcome"})
+
+	brokenLinksFound = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "broken_links_found_total",
+		Help:      "Broken links found by crawl runs.",
+	})
+)
+
+// HandleEvent is a bus handler recording crawl outcomes
+func HandleEvent(event events.Event) {
+	switch e := event.(type) {
+	case events.CrawlSucceeded:
+		outcome := "done"
+		if e.NotModified {
+			outcome = "unchanged"
+		}
+		crawlsTotal.WithLabelValues(outcome, "").Inc()
+		crawlDuration.WithLabelValues(outcome).Observe(e.Duration.Seconds())
+	case events.CrawlFailed:
+		crawlsTotal.WithLabelValues("error", e.ErrorClass).Inc()
+		crawlDuration.WithLabelValues("error").Observe(e.Duration.Seconds())
+	case events.CrawlCancelled:
+		crawlsTotal.WithLabelValues("cancelled", "").Inc()
+		if e.RunID != 0 {
+			crawlDuration.WithLabelValues("cancelled").Observe(e.Duration.Seconds())
+		}
+	case events.LinkBroken:
+		brokenLinksFound.Inc()
+	}
+}
+
+// crawlerCollector reads the crawler state at scrape time
+type crawlerCollector struct {
+	service *crawler.Service
+
+	queueLength   *prometheus.Desc
+	queueCapacity *prometheus.Desc
+	workers       *prometheus.Desc
+	activeWorkers *prometheus.Desc
+	paused        *prometheus.Desc
+	linkChecks    *prometheus.Desc
+}
+
+// RegisterCrawler exposes queue depth, worker usage and link check counts
+func RegisterCrawler(service *crawler.Service) {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "crawler", name), help, labels, nil)
+	}
+
+	Registry.MustRegister(&crawlerCollector{
+		service:       service,
+		queueLength:   desc("queue_length", "URLs waiting for a worker, by priority.", "priority"),
+		queueCapacity: desc("queue_capacity", "Maximum number of queued URLs."),
+		workers:       desc("workers", "Configured number of workers."),
+		activeWorkers: desc("active_workers", "Workers currently crawling a URL."),
+		paused:        desc("paused", "1 if the crawler is paused."),
+		linkChecks:    desc("link_checks_total", "Links checked since start."),
+	})
+}
+
+// Describe implements prometheus.Collector
+func (c *crawlerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.queueLength
+	ch <- c.queueCapacity
+	ch <- c.workers
+	ch <- c.activeWorkers
+	ch <- c.paused
+	ch <- c.linkChecks
+}
+
+// Collect implements prometheus.Collector
+func (c *crawlerCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.service.Stats()
+
+	for priority, n := range stats.QueueByPriority {
+		ch <- prometheus.MustNewConstMetric(c.queueLength, prometheus.GaugeValue, float64(n), priority.String())
+	}
+	ch <- prometheus.MustNewConstMetric(c.queueCapacity, prometheus.GaugeValue, float64(stats.QueueCapacity))
+	ch <- prometheus.MustNewConstMetric(c.workers, prometheus.GaugeValue, float64(stats.Workers))
+	ch <- prometheus.MustNewConstMetric(c.activeWorkers, prometheus.GaugeValue, float64(stats.ActiveWorkers))
+
+	paused := 0.0
+	if stats.Paused {
+		paused = 1
+	}
+	ch <- prometheus.MustNewConstMetric(c.paused, prometheus.GaugeValue, paused)
+	ch <- prometheus.MustNewConstMetric(c.linkChecks, prometheus.CounterValue, float64(stats.LinksChecked))
+}
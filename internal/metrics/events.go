@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sykell/url-crawler/internal/events"
+)
+
+// busCollector reads the event bus counters at scrape time
+type busCollector struct {
+	bus *events.Bus
+
+	dropped *prometheus.Desc
+}
+
+// RegisterBus exposes the events each bus subscriber dropped
+func RegisterBus(bus *events.Bus) {
+	Registry.MustRegister(&busCollector{
+		bus: bus,
+		dropped: prometheus.NewDesc(prometheus.BuildFQName(namespace, "events", "dropped_total"),
+			"Events dropped because the subscriber's buffer was full, by subscriber.", []string{"subscriber"}, nil),
+	})
+}
+
+// Describe implements prometheus.Collector
+func (c *busCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.dropped
+}
+
+// Collect implements prometheus.Collector
+func (c *busCollector) Collect(ch chan<- prometheus.Metric) {
+	for subscriber, n := range c.bus.Dropped() {
+		ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(n), subscriber)
+	}
+}
@@ -1,28 +1,54 @@
 package middleware
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sykell/url-crawler/internal/metrics"
+	"github.com/sykell/url-crawler/internal/service"
 )
 
 // UserContext represents user information in the request context
 type UserContext struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
+	IsAdmin  bool   `json:"is_admin"`
+}
+
+// JWTRequired middleware validates JWT tokens and extracts user information.
+// Tokens of disabled users, and those issued before the user's tokens were
+// revoked, are rejected.
+func JWTRequired(users service.UserRepository) gin.HandlerFunc {
+	return jwtRequired(users, false)
+}
+
+// StreamJWTRequired is JWTRequired for streaming endpoints. Browsers can't set
+// headers on EventSource or WebSocket connections, so the token may also be
+// passed as the access_token query parameter.
+func StreamJWTRequired(users service.UserRepository) gin.HandlerFunc {
+	return jwtRequired(users, true)
 }
 
-// JWTRequired middleware validates JWT tokens and extracts user information
-func JWTRequired() gin.HandlerFunc {
+// jwtRequired builds the JWT middleware, optionally accepting a query parameter token
+func jwtRequired(users service.UserRepository, allowQuery bool) gin.HandlerFunc {
 	secret := getJWTSecret()
-	
+
 	return func(c *gin.Context) {
 		// Extract token from Authorization header
 		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" && allowQuery {
+			if token := c.Query("access_token"); token != "" {
+				authHeader = "Bearer " + token
+			}
+		}
 		if authHeader == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "Authorization header required",
@@ -48,7 +74,8 @@ func JWTRequired() gin.HandlerFunc {
 		// Validate token
 		claims, err := validateToken(tokenStr, secret)
 		if err != nil {
-			log.Printf("JWT validation failed: %v", err)
+			slog.WarnContext(c.Request.Context(), "JWT validation failed", "error", err)
+			metrics.AuthAttempt("token", false)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid or expired token",
 			})
@@ -58,7 +85,7 @@ func JWTRequired() gin.HandlerFunc {
 		// Extract user information
 		userID, ok := claims["user_id"].(float64)
 		if !ok {
-			log.Printf("Invalid user_id in JWT claims")
+			slog.WarnContext(c.Request.Context(), "Invalid user_id in JWT claims")
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid token claims",
 			})
@@ -67,19 +94,76 @@ func JWTRequired() gin.HandlerFunc {
 
 		username, ok := claims["username"].(string)
 		if !ok {
-			log.Printf("Invalid username in JWT claims")
+			slog.WarnContext(c.Request.Context(), "Invalid username in JWT claims")
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid token claims",
 			})
 			return
 		}
 
+		// Tokens issued before revocation support carry no version
+		version, _ := claims["ver"].(float64)
+		account, err := users.GetByID(context.WithoutCancel(c.Request.Context()), uint(userID))
+		if err != nil && err != service.ErrNotFound {
+			slog.ErrorContext(c.Request.Context(), "Failed to load user for token check", "user_id", uint(userID), "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Internal server error",
+			})
+			return
+		}
+		if err == service.ErrNotFound || account.DisabledAt != nil || account.TokenVersion != uint(version) {
+			slog.WarnContext(c.Request.Context(), "Revoked JWT rejected", "username", username)
+			metrics.AuthAttempt("token", false)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Token has been revoked",
+			})
+			return
+		}
+
+		metrics.AuthAttempt("token", true)
+
 		// Set user context
 		userCtx := UserContext{
-			UserID:   uint(userID),
-			Username: username,
+			UserID:   account.ID,
+			Username: account.Username,
+			IsAdmin:  account.IsAdmin,
 		}
 		c.Set("user", userCtx)
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.Int("enduser.id", int(userID)))
+
+		c.Next()
+	}
+}
+
+// AdminRequired middleware rejects users who aren't admins. Privileges are
+// looked up in the database, so revoking them takes effect without waiting
+// for tokens to expire. It must run after JWTRequired.
+func AdminRequired(users service.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := GetUserFromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "User not authenticated",
+			})
+			return
+		}
+
+		account, err := users.GetByID(context.WithoutCancel(c.Request.Context()), user.UserID)
+		if err != nil && err != service.ErrNotFound {
+			slog.ErrorContext(c.Request.Context(), "Failed to load user for admin check", "user_id", user.UserID, "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Internal server error",
+			})
+			return
+		}
+
+		if err == service.ErrNotFound || !account.IsAdmin || account.DisabledAt != nil {
+			slog.WarnContext(c.Request.Context(), "Admin access denied", "username", user.Username)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Admin privileges required",
+			})
+			return
+		}
 
 		c.Next()
 	}
@@ -125,7 +209,7 @@ func validateToken(tokenString, secret string) (jwt.MapClaims, error) {
 func getJWTSecret() string {
 	secret := os.Getenv("JWT_SECRET")
 		if secret == "" {
-			log.Println("WARNING: JWT_SECRET not set, using default secret")
+			slog.Warn("JWT_SECRET not set, using default secret")
 		}
 	return secret
 }
@@ -151,7 +235,7 @@ func OptionalAuth() gin.HandlerFunc {
 		claims, err := validateToken(tokenStr, secret)
 		if err != nil {
 			// Log but don't fail the request
-			log.Printf("Optional JWT validation failed: %v", err)
+			slog.DebugContext(c.Request.Context(), "Optional JWT validation failed", "error", err)
 			c.Next()
 			return
 		}
@@ -175,9 +259,9 @@ func OptionalAuth() gin.HandlerFunc {
 func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		c.Header("Access-Control-Expose-Headers", "Content-Length")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Last-Event-ID, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "Content-Length, X-Request-ID")
 		c.Header("Access-Control-Allow-Credentials", "true")
 
 		if c.Request.Method == "OPTIONS" {
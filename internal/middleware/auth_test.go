@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+const testSecret = "test-secret"
+
+// newTestToken signs a token for user the way LoginHandler does
+func newTestToken(t *testing.T, user *db.User, isAdmin bool) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":  user.ID,
+		"username": user.Username,
+		"is_admin": isAdmin,
+		"ver":      user.TokenVersion,
+		"exp":      time.Now().Add(time.Hour).Unix(),
+		"iat":      time.Now().Unix(),
+	})
+	signed, err := token.SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// get requests path with token and returns the status code
+func get(r http.Handler, path, token string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAdminRequiredChecksTheDatabase(t *testing.T) {
+	t.Setenv("JWT_SECRET", testSecret)
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	users := service.NewMemoryUserRepository()
+	for _, username := range []string{"alice", "bob"} {
+		users.Create(ctx, username, "hash")
+	}
+	users.PromoteAdmins(ctx, []string{"alice"})
+	alice, _ := users.GetByUsername(ctx, "alice")
+	bob, _ := users.GetByUsername(ctx, "bob")
+
+	r := gin.New()
+	r.GET("/admin", JWTRequired(users), AdminRequired(users), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"admin", newTestToken(t, alice, true), http.StatusOK},
+		{"stale claim", newTestToken(t, bob, true), http.StatusForbidden},
+		{"unknown user", newTestToken(t, &db.User{ID: 99, Username: "mallory"}, true), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if code := get(r, "/admin", tt.token); code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	// Revoking admin applies to tokens already issued
+	users.SyncAdmins(ctx, []string{"bob"})
+	if code := get(r, "/admin", newTestToken(t, alice, true)); code != http.StatusForbidden {
+		t.Errorf("revoked admin: status %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestJWTRequiredRejectsRevokedTokens(t *testing.T) {
+	t.Setenv("JWT_SECRET", testSecret)
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	users := service.NewMemoryUserRepository()
+	users.Create(ctx, "alice", "hash")
+	alice, _ := users.GetByUsername(ctx, "alice")
+
+	r := gin.New()
+	r.GET("/urls", JWTRequired(users), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	issued := newTestToken(t, alice, false)
+	if code := get(r, "/urls", issued); code != http.StatusOK {
+		t.Fatalf("valid token: status %d, want %d", code, http.StatusOK)
+	}
+
+	// A token issued before the disable stays revoked once re-enabled
+	users.SetDisabled(ctx, "alice", true)
+	if code := get(r, "/urls", issued); code != http.StatusUnauthorized {
+		t.Errorf("token of a disabled user: status %d, want %d", code, http.StatusUnauthorized)
+	}
+	users.SetDisabled(ctx, "alice", false)
+	if code := get(r, "/urls", issued); code != http.StatusUnauthorized {
+		t.Errorf("token issued before the disable: status %d, want %d", code, http.StatusUnauthorized)
+	}
+	alice, _ = users.GetByUsername(ctx, "alice")
+	reissued := newTestToken(t, alice, false)
+	if code := get(r, "/urls", reissued); code != http.StatusOK {
+		t.Errorf("token issued after enabling: status %d, want %d", code, http.StatusOK)
+	}
+
+	users.UpdatePassword(ctx, "alice", "new-hash")
+	if code := get(r, "/urls", reissued); code != http.StatusUnauthorized {
+		t.Errorf("token issued before the password change: status %d, want %d", code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,348 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/events"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+// AlertEvents are the event types the alerter subscribes to
+var AlertEvents = []string{events.TypeCrawlSucceeded, events.TypeCrawlFailed}
+
+// AlerterConfig holds email alert configuration
+type AlerterConfig struct {
+	// DigestInterval is how often batched alerts are emailed
+	DigestInterval time.Duration
+}
+
+// NewAlerterConfig creates an alerter configuration from environment variables
+func NewAlerterConfig() *AlerterConfig {
+	config := &AlerterConfig{DigestInterval: time.Hour}
+
+	if v, err := time.ParseDuration(os.Getenv("ALERT_DIGEST_INTERVAL")); err == nil && v > 0 {
+		config.DigestInterval = v
+	}
+
+	return config
+}
+
+// Alerter evaluates users' alert rules against crawl outcomes and emails
+// them, either immediately or batched into a periodic digest
+type Alerter struct {
+	alerts    service.AlertRepository
+	urls      service.URLRepository
+	mailer    *Mailer
+	config    *AlerterConfig
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	isRunning bool
+}
+
+// NewAlerter creates an alerter
+func NewAlerter(alerts service.AlertRepository, urls service.URLRepository, mailer *Mailer, config *AlerterConfig) *Alerter {
+	if config == nil {
+		config = NewAlerterConfig()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Alerter{
+		alerts: alerts,
+		urls:   urls,
+		mailer: mailer,
+		config: config,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start starts the digest loop
+func (a *Alerter) Start() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.isRunning {
+		return fmt.Errorf("alerter is already running")
+	}
+
+	a.isRunning = true
+	a.wg.Add(1)
+	go a.run()
+
+	slog.Info("Email alerter started", "digest_interval", a.config.DigestInterval)
+	return nil
+}
+
+// Stop stops the digest loop; pending alerts are sent after restart
+func (a *Alerter) Stop() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.isRunning {
+		return nil
+	}
+
+	a.isRunning = false
+	a.cancel()
+	a.wg.Wait()
+
+	slog.Info("Email alerter stopped")
+	return nil
+}
+
+// HandleEvent is a bus handler checking crawl outcomes against alert rules
+func (a *Alerter) HandleEvent(event events.Event) {
+	ctx := context.Background()
+	var item *AlertItem
+	var runID uint
+	var err error
+
+	switch e := event.(type) {
+	case events.CrawlFailed:
+		runID = e.RunID
+		item, err = a.errorAlert(ctx, e)
+	case events.CrawlSucceeded:
+		runID = e.RunID
+		item, err = a.brokenLinksAlert(ctx, e)
+	default:
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to evaluate alerts", "event", event.EventType(), "error", err)
+		return
+	}
+	if item == nil {
+		return
+	}
+
+	rules, err := a.alerts.ListRulesForURL(ctx, event.Owner(), item.URL.ID)
+	if err != nil {
+		slog.Error("Failed to load alert rules", "user_id", event.Owner(), "error", err)
+		return
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if item.Kind == db.AlertKindError && !rule.OnError ||
+			item.Kind == db.AlertKindBrokenLinks && !rule.OnBrokenLinks {
+			continue
+		}
+
+		if rule.Digest {
+			if err := a.queueForDigest(ctx, rule, item, runID); err != nil {
+				slog.Error("Failed to queue alert", "rule_id", rule.ID, "error", err)
+			}
+			continue
+		}
+
+		if err := a.send(ctx, rule, &AlertData{Items: []AlertItem{*item}}); err != nil {
+			slog.Error("Failed to send alert", "rule_id", rule.ID, "email", rule.Email, "error", err)
+		}
+	}
+}
+
+// errorAlert returns an alert when a URL starts failing, i.e. its previous
+// finished run didn't fail as well
+func (a *Alerter) errorAlert(ctx context.Context, e events.CrawlFailed) (*AlertItem, error) {
+	previous, err := a.urls.PreviousFinishedRun(ctx, e.URLID, e.RunID)
+	if err != nil && err != service.ErrNotFound {
+		return nil, err
+	}
+	if previous != nil && previous.Status == db.StatusError {
+		return nil, nil
+	}
+
+	url, err := a.urls.GetByID(ctx, e.URLID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AlertItem{
+		URL:        url,
+		Kind:       db.AlertKindError,
+		Error:      e.Error,
+		OccurredAt: e.OccurredAt,
+	}, nil
+}
+
+// brokenLinksAlert returns an alert when a run found broken links that the
+// previous successful run didn't have
+func (a *Alerter) brokenLinksAlert(ctx context.Context, e events.CrawlSucceeded) (*AlertItem, error) {
+	if e.NotModified || e.BrokenLinks == 0 {
+		return nil, nil
+	}
+
+	run, err := a.urls.GetRun(ctx, e.URLID, e.RunID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without history every broken link is new
+	previous, err := a.urls.PreviousRun(ctx, e.URLID, e.RunID)
+	if err == service.ErrNotFound {
+		previous = &db.CrawlRun{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	diff, err := service.DiffCrawlRuns(previous, run, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(diff.NewlyBroken) == 0 {
+		return nil, nil
+	}
+
+	url, err := a.urls.GetByID(ctx, e.URLID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AlertItem{
+		URL:         url,
+		Kind:        db.AlertKindBrokenLinks,
+		BrokenLinks: diff.NewlyBroken,
+		OccurredAt:  e.OccurredAt,
+	}, nil
+}
+
+// queueForDigest stores an alert until the next digest
+func (a *Alerter) queueForDigest(ctx context.Context, rule *db.AlertRule, item *AlertItem, runID uint) error {
+	details, err := json.Marshal(pendingDetails{Error: item.Error, BrokenLinks: item.BrokenLinks})
+	if err != nil {
+		return err
+	}
+
+	return a.alerts.CreatePending(ctx, &db.PendingAlert{
+		RuleID:  rule.ID,
+		URLID:   item.URL.ID,
+		RunID:   runID,
+		Kind:    item.Kind,
+		Details: string(details),
+	})
+}
+
+// pendingDetails is the JSON stored in PendingAlert.Details
+type pendingDetails struct {
+	Error       string              `json:"error,omitempty"`
+	BrokenLinks []map[string]string `json:"broken_links,omitempty"`
+}
+
+// run sends digests every DigestInterval
+func (a *Alerter) run() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.config.DigestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.sendDigests()
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+// sendDigests emails one digest per rule with pending alerts
+func (a *Alerter) sendDigests() {
+	ctx := context.Background()
+	alerts, err := a.alerts.ListPending(ctx)
+	if err != nil {
+		slog.Error("Failed to list pending alerts", "error", err)
+		return
+	}
+
+	for start := 0; start < len(alerts); {
+		end := start
+		for end < len(alerts) && alerts[end].RuleID == alerts[start].RuleID {
+			end++
+		}
+		a.sendDigest(ctx, alerts[start].RuleID, alerts[start:end])
+		start = end
+	}
+}
+
+// sendDigest emails the pending alerts of one rule and removes them once sent
+func (a *Alerter) sendDigest(ctx context.Context, ruleID uint, alerts []db.PendingAlert) {
+	ids := make([]uint, len(alerts))
+	for i, alert := range alerts {
+		ids[i] = alert.ID
+	}
+
+	rule, err := a.alerts.GetRule(ctx, ruleID)
+	if err != nil {
+		slog.Error("Failed to load alert rule", "rule_id", ruleID, "error", err)
+		if err == service.ErrNotFound {
+			a.alerts.DeletePending(ctx, ids)
+		}
+		return
+	}
+
+	data := &AlertData{Digest: true}
+	for _, alert := range alerts {
+		url, err := a.urls.GetByID(ctx, alert.URLID)
+		if err == service.ErrNotFound {
+			continue // URL deleted since
+		} else if err != nil {
+			slog.Error("Failed to load URL for digest", "url_id", alert.URLID, "error", err)
+			return
+		}
+
+		var details pendingDetails
+		if alert.Details != "" {
+			if err := json.Unmarshal([]byte(alert.Details), &details); err != nil {
+				slog.Warn("Invalid details on pending alert", "alert_id", alert.ID, "error", err)
+			}
+		}
+
+		data.Items = append(data.Items, AlertItem{
+			URL:         url,
+			Kind:        alert.Kind,
+			Error:       details.Error,
+			BrokenLinks: details.BrokenLinks,
+			OccurredAt:  alert.CreatedAt,
+		})
+	}
+
+	if len(data.Items) > 0 {
+		if err := a.send(ctx, rule, data); err != nil {
+			slog.Error("Failed to send digest", "rule_id", rule.ID, "email", rule.Email, "error", err)
+			return
+		}
+	}
+
+	if err := a.alerts.DeletePending(ctx, ids); err != nil {
+		slog.Error("Failed to delete sent alerts", "rule_id", rule.ID, "error", err)
+	}
+}
+
+// send renders and emails alerts for a rule
+func (a *Alerter) send(ctx context.Context, rule *db.AlertRule, data *AlertData) error {
+	msg, err := RenderAlert(data)
+	if err != nil {
+		return err
+	}
+	msg.To = []string{rule.Email}
+
+	if err := a.mailer.Send(msg); err != nil {
+		return err
+	}
+
+	if err := a.alerts.MarkRuleSent(ctx, rule.ID, time.Now()); err != nil {
+		slog.Error("Failed to record alert sent", "rule_id", rule.ID, "error", err)
+	}
+	slog.Info("Sent alerts", "count", len(data.Items), "rule_id", rule.ID, "email", rule.Email)
+	return nil
+}
@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	textTemplate = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/alert.txt.tmpl"))
+	htmlTemplate = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/alert.html.tmpl"))
+)
+
+// AlertItem is a single triggered alert rendered into an email
+type AlertItem struct {
+	URL         *db.URL
+	Kind        string // db.AlertKindError or db.AlertKindBrokenLinks
+	Error       string
+	BrokenLinks []map[string]string // Newly broken links: {"url": ..., "code": ...}
+	OccurredAt  time.Time
+}
+
+// AlertData is the template data of an alert email
+type AlertData struct {
+	Digest bool
+	Items  []AlertItem
+}
+
+// RenderAlert renders the subject and the text and HTML bodies of an alert email
+func RenderAlert(data *AlertData) (*Message, error) {
+	var text, html bytes.Buffer
+	if err := textTemplate.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("failed to render text body: %w", err)
+	}
+	if err := htmlTemplate.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("failed to render HTML body: %w", err)
+	}
+
+	return &Message{
+		Subject: alertSubject(data),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// alertSubject summarizes the alerts in the subject line
+func alertSubject(data *AlertData) string {
+	if data.Digest || len(data.Items) != 1 {
+		return fmt.Sprintf("[URL Crawler] %d alerts for your monitored pages", len(data.Items))
+	}
+
+	item := data.Items[0]
+	if item.Kind == db.AlertKindError {
+		return fmt.Sprintf("[URL Crawler] %s is failing", item.URL.Address)
+	}
+	return fmt.Sprintf("[URL Crawler] %s has %d new broken links", item.URL.Address, len(item.BrokenLinks))
+}
@@ -0,0 +1,208 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/events"
+)
+
+// Event types streamed to clients
+const (
+	EventStatus   = "status"
+	EventProgress = "progress"
+	// EventReset tells a resuming client that events were missed, so it
+	// should reload the state of its URLs
+	EventReset = "reset"
+)
+
+// subscriberBuffer is how many events a subscriber may lag behind before it is dropped
+const subscriberBuffer = 64
+
+// Event is a crawl update delivered to the owning user
+type Event struct {
+	ID           uint64       `json:"id"`
+	Type         string       `json:"type"`
+	UserID       uint         `json:"-"`
+	URLID        uint         `json:"url_id"`
+	RunID        uint         `json:"run_id,omitempty"`
+	Status       db.URLStatus `json:"status,omitempty"`
+	Error        string       `json:"error,omitempty"`
+	LinksChecked int          `json:"links_checked,omitempty"`
+	LinksTotal   int          `json:"links_total,omitempty"`
+	Time         time.Time    `json:"time"`
+}
+
+// Subscription receives the events of a single user. C is closed when the
+// subscription ends, by Close, because the subscriber fell behind or
+// because the hub was closed.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	userID uint
+	hub    *Hub
+	once   sync.Once
+}
+
+// Hub fans out crawl events to per-user subscribers and keeps a short
+// per-user history so reconnecting clients can resume from Last-Event-ID
+type Hub struct {
+	mu sync.Mutex
+	// IDs count up from the boot time in microseconds, so they keep
+	// increasing across restarts and IDs of earlier runs are told apart
+	bootID      uint64
+	nextID      uint64
+	historySize int
+	history     map[uint][]Event
+	// trimmed is the ID of the newest event dropped from a user's history
+	trimmed     map[uint]uint64
+	subscribers map[uint]map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewHub creates a hub keeping up to historySize events per user
+func NewHub(historySize int) *Hub {
+	bootID := uint64(time.Now().UnixMicro())
+	return &Hub{
+		bootID:      bootID,
+		nextID:      bootID,
+		historySize: historySize,
+		history:     make(map[uint][]Event),
+		trimmed:     make(map[uint]uint64),
+		subscribers: make(map[uint]map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the event an ID and delivers it to the user's subscribers
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	event.ID = h.nextID
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	if h.historySize > 0 {
+		history := append(h.history[event.UserID], event)
+		if len(history) > h.historySize {
+			h.trimmed[event.UserID] = history[len(history)-h.historySize-1].ID
+			history = history[len(history)-h.historySize:]
+		}
+		h.history[event.UserID] = history
+	}
+
+	for sub := range h.subscribers[event.UserID] {
+		select {
+		case sub.ch <- event:
+		default:
+			// Drop slow subscribers; they can resume from the history
+			h.removeLocked(sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for a user and returns the buffered events
+// newer than lastEventID (none when lastEventID is 0). When events after
+// lastEventID can't be replayed, because it is from an earlier run of the
+// server or older than the history, the backlog is a single EventReset.
+func (h *Hub) Subscribe(userID uint, lastEventID uint64) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, userID: userID, hub: h}
+	if h.closed {
+		sub.once.Do(func() { close(ch) })
+		return sub, nil
+	}
+
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[userID][sub] = struct{}{}
+
+	if lastEventID > 0 && (lastEventID < h.bootID || lastEventID > h.nextID || lastEventID < h.trimmed[userID]) {
+		// The reset carries the latest ID so the client resumes from here
+		return sub, []Event{{ID: h.nextID, Type: EventReset, UserID: userID, Time: time.Now().UTC()}}
+	}
+
+	var backlog []Event
+	if lastEventID > 0 {
+		for _, event := range h.history[userID] {
+			if event.ID > lastEventID {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	return sub, backlog
+}
+
+// Close ends all subscriptions and refuses new ones, so streaming handlers
+// return and the HTTP server can shut down
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subscribers {
+		for sub := range subs {
+			h.removeLocked(sub)
+		}
+	}
+}
+
+// Closed reports whether the hub was closed
+func (h *Hub) Closed() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closed
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.removeLocked(s)
+}
+
+// removeLocked unregisters a subscription; the caller must hold h.mu
+func (h *Hub) removeLocked(sub *Subscription) {
+	sub.once.Do(func() {
+		delete(h.subscribers[sub.userID], sub)
+		if len(h.subscribers[sub.userID]) == 0 {
+			delete(h.subscribers, sub.userID)
+		}
+		close(sub.ch)
+	})
+}
+
+// HandleEvent is a bus handler translating crawl events into client events
+func (h *Hub) HandleEvent(event events.Event) {
+	switch e := event.(type) {
+	case events.URLQueued:
+		h.Publish(Event{Type: EventStatus, UserID: e.UserID, URLID: e.URLID, Status: db.StatusQueued, Time: e.OccurredAt})
+	case events.CrawlStarted:
+		h.Publish(Event{Type: EventStatus, UserID: e.UserID, URLID: e.URLID, RunID: e.RunID, Status: db.StatusRunning, Time: e.OccurredAt})
+	case events.CrawlProgress:
+		h.Publish(Event{
+			Type:         EventProgress,
+			UserID:       e.UserID,
+			URLID:        e.URLID,
+			RunID:        e.RunID,
+			Status:       db.StatusRunning,
+			LinksChecked: e.LinksChecked,
+			LinksTotal:   e.LinksTotal,
+			Time:         e.OccurredAt,
+		})
+	case events.CrawlSucceeded:
+		h.Publish(Event{Type: EventStatus, UserID: e.UserID, URLID: e.URLID, RunID: e.RunID, Status: db.StatusDone, Time: e.OccurredAt})
+	case events.CrawlFailed:
+		h.Publish(Event{Type: EventStatus, UserID: e.UserID, URLID: e.URLID, RunID: e.RunID, Status: db.StatusError, Error: e.Error, Time: e.OccurredAt})
+	case events.CrawlCancelled:
+		h.Publish(Event{Type: EventStatus, UserID: e.UserID, URLID: e.URLID, RunID: e.RunID, Status: db.StatusCancelled, Time: e.OccurredAt})
+	}
+}
@@ -0,0 +1,77 @@
+package realtime
+
+import "testing"
+
+func TestSubscribeBacklog(t *testing.T) {
+	hub := NewHub(3)
+	defer hub.Close()
+
+	// Alice's first event is dropped from her history of three
+	var ids []uint64
+	for i := 0; i < 4; i++ {
+		hub.Publish(Event{Type: EventStatus, UserID: 1, URLID: uint(i + 1)})
+		ids = append(ids, hub.nextID)
+	}
+	hub.Publish(Event{Type: EventStatus, UserID: 2, URLID: 10})
+
+	tests := []struct {
+		name        string
+		lastEventID uint64
+		want        []uint64 // IDs of the backlog
+		reset       bool
+	}{
+		{"new stream", 0, nil, false},
+		{"up to date", ids[3], nil, false},
+		{"missed events", ids[1], ids[2:], false},
+		{"saw the trimmed event", ids[0], ids[1:], false},
+		{"missed trimmed events", ids[0] - 1, nil, true},
+		{"earlier run", 42, nil, true},
+		{"unknown run", hub.nextID + 1, nil, true},
+	}
+	for _, tt := range tests {
+		sub, backlog := hub.Subscribe(1, tt.lastEventID)
+		sub.Close()
+
+		if tt.reset {
+			if len(backlog) != 1 || backlog[0].Type != EventReset || backlog[0].ID != hub.nextID {
+				t.Errorf("%s: backlog %+v, want a reset with the latest ID", tt.name, backlog)
+			}
+			continue
+		}
+		var got []uint64
+		for _, event := range backlog {
+			got = append(got, event.ID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: backlog IDs %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: backlog IDs %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestEventIDsIncreaseAcrossRestarts(t *testing.T) {
+	before := NewHub(1)
+	before.Publish(Event{Type: EventStatus, UserID: 1})
+	before.Publish(Event{Type: EventStatus, UserID: 1})
+	last := before.nextID
+	before.Close()
+
+	after := NewHub(1)
+	for after.bootID <= last {
+		after = NewHub(1) // Started within the previous run's last microseconds
+	}
+	defer after.Close()
+	if _, backlog := after.Subscribe(1, last); len(backlog) != 1 || backlog[0].Type != EventReset {
+		t.Errorf("resuming from the previous run = %+v, want a reset", backlog)
+	}
+	after.Publish(Event{Type: EventStatus, UserID: 1})
+	if after.nextID <= last {
+		t.Errorf("first ID after restart %d isn't above %d", after.nextID, last)
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"gorm.io/gorm"
+)
+
+// CreateAlertRule creates an email alert rule for a user
+func CreateAlertRule(dbConn *gorm.DB, rule *db.AlertRule) error {
+	if rule.UserID == 0 {
+		return fmt.Errorf("user ID cannot be zero")
+	}
+	if rule.Email == "" {
+		return fmt.Errorf("email cannot be empty")
+	}
+	return dbConn.Create(rule).Error
+}
+
+// ListAlertRules returns all alert rules of a user
+func ListAlertRules(dbConn *gorm.DB, userID uint) ([]db.AlertRule, error) {
+	var rules []db.AlertRule
+	err := dbConn.Where("user_id = ?", userID).Order("id asc").Find(&rules).Error
+	return rules, err
+}
+
+// GetAlertRuleByIDAndUser retrieves an alert rule owned by a user
+func GetAlertRuleByIDAndUser(dbConn *gorm.DB, id, userID uint) (*db.AlertRule, error) {
+	var rule db.AlertRule
+	err := dbConn.Where("id = ? AND user_id = ?", id, userID).First(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// GetAlertRuleByID retrieves an alert rule by ID
+func GetAlertRuleByID(dbConn *gorm.DB, id uint) (*db.AlertRule, error) {
+	var rule db.AlertRule
+	if err := dbConn.First(&rule, id).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// UpdateAlertRule saves the URL, email and triggers of a rule
+func UpdateAlertRule(dbConn *gorm.DB, rule *db.AlertRule) error {
+	return dbConn.Model(rule).Select("url_id", "email", "on_error", "on_broken_links", "digest").Updates(rule).Error
+}
+
+// DeleteAlertRule removes an alert rule together with its pending alerts
+func DeleteAlertRule(dbConn *gorm.DB, id uint) error {
+	return dbConn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("rule_id = ?", id).Delete(&db.PendingAlert{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&db.AlertRule{}, id).Error
+	})
+}
+
+// DeleteAlertRulesForURLs removes the URL-specific alert rules of the given URLs
+func DeleteAlertRulesForURLs(dbConn *gorm.DB, urlIDs []uint) error {
+	if err := dbConn.Where("url_id IN ?", urlIDs).Delete(&db.PendingAlert{}).Error; err != nil {
+		return err
+	}
+	return dbConn.Where("url_id IN ?", urlIDs).Delete(&db.AlertRule{}).Error
+}
+
+// ListAlertRulesForURL returns the user's rules that apply to a URL
+func ListAlertRulesForURL(dbConn *gorm.DB, userID, urlID uint) ([]db.AlertRule, error) {
+	var rules []db.AlertRule
+	err := dbConn.Where("user_id = ? AND (url_id IS NULL OR url_id = ?)", userID, urlID).
+		Order("id asc").Find(&rules).Error
+	return rules, err
+}
+
+// MarkAlertRuleSent records when an email was last sent for a rule
+func MarkAlertRuleSent(dbConn *gorm.DB, id uint, sentAt time.Time) error {
+	return dbConn.Model(&db.AlertRule{}).Where("id = ?", id).Update("last_sent_at", sentAt).Error
+}
+
+// CreatePendingAlert stores an alert for the next digest
+func CreatePendingAlert(dbConn *gorm.DB, alert *db.PendingAlert) error {
+	return dbConn.Create(alert).Error
+}
+
+// ListPendingAlerts returns all alerts waiting for a digest, grouped by rule
+func ListPendingAlerts(dbConn *gorm.DB) ([]db.PendingAlert, error) {
+	var alerts []db.PendingAlert
+	err := dbConn.Order("rule_id asc, id asc").Find(&alerts).Error
+	return alerts, err
+}
+
+// DeletePendingAlerts removes alerts once their digest has been sent
+func DeletePendingAlerts(dbConn *gorm.DB, ids []uint) error {
+	return dbConn.Where("id IN ?", ids).Delete(&db.PendingAlert{}).Error
+}
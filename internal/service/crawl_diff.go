@@ -0,0 +1,246 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"gorm.io/gorm"
+)
+
+// maxTextDiffCells bounds the line diff table so huge pages can't exhaust memory
+const maxTextDiffCells = 4_000_000
+
+// StringChange describes a changed string value
+type StringChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// CountChange describes a numeric value in both runs
+type CountChange struct {
+	From  int `json:"from"`
+	To    int `json:"to"`
+	Delta int `json:"delta"`
+}
+
+// BoolChange describes a changed boolean value
+type BoolChange struct {
+	From bool `json:"from"`
+	To   bool `json:"to"`
+}
+
+// LineChange is a single added or removed line of main content
+type LineChange struct {
+	Op   string `json:"op"` // "add" or "remove"
+	Text string `json:"text"`
+}
+
+// CrawlDiff describes what changed between two crawl runs of the same URL
+type CrawlDiff struct {
+	FromRunID     uint                   `json:"from_run_id"`
+	ToRunID       uint                   `json:"to_run_id"`
+	Changed       bool                   `json:"changed"`
+	Title         *StringChange          `json:"title,omitempty"`
+	HTMLVersion   *StringChange          `json:"html_version,omitempty"`
+	Headings      map[string]CountChange `json:"headings"`
+	InternalLinks CountChange            `json:"internal_links"`
+	ExternalLinks CountChange            `json:"external_links"`
+	BrokenLinks   CountChange            `json:"broken_links"`
+	NewlyBroken   []map[string]string    `json:"newly_broken"`
+	NewlyFixed    []map[string]string    `json:"newly_fixed"`
+	LoginForm     *BoolChange            `json:"login_form,omitempty"`
+	TextDiff      []LineChange           `json:"text_diff,omitempty"`
+}
+
+// GetPreviousCrawlRun retrieves the latest run with results of a URL older than the given run
+func GetPreviousCrawlRun(dbConn *gorm.DB, urlID, beforeRunID uint) (*db.CrawlRun, error) {
+	var run db.CrawlRun
+	err := dbConn.Where("url_id = ? AND id < ? AND status IN ?", urlID, beforeRunID, []db.URLStatus{db.StatusDone, db.StatusUnchanged}).
+		Order("id desc").First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// DiffCrawlRuns compares two runs, optionally including a line diff of the main content
+func DiffCrawlRuns(from, to *db.CrawlRun, includeText bool) (*CrawlDiff, error) {
+	fromHeadings, err := parseHeadingCounts(from)
+	if err != nil {
+		return nil, err
+	}
+	toHeadings, err := parseHeadingCounts(to)
+	if err != nil {
+		return nil, err
+	}
+	fromBroken, err := parseBrokenList(from)
+	if err != nil {
+		return nil, err
+	}
+	toBroken, err := parseBrokenList(to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &CrawlDiff{
+		FromRunID:     from.ID,
+		ToRunID:       to.ID,
+		Headings:      make(map[string]CountChange),
+		InternalLinks: newCountChange(from.InternalLinks, to.InternalLinks),
+		ExternalLinks: newCountChange(from.ExternalLinks, to.ExternalLinks),
+		BrokenLinks:   newCountChange(from.BrokenLinks, to.BrokenLinks),
+		NewlyBroken:   subtractLinks(toBroken, fromBroken),
+		NewlyFixed:    subtractLinks(fromBroken, toBroken),
+	}
+
+	if from.Title != to.Title {
+		diff.Title = &StringChange{From: from.Title, To: to.Title}
+	}
+	if from.HTMLVersion != to.HTMLVersion {
+		diff.HTMLVersion = &StringChange{From: from.HTMLVersion, To: to.HTMLVersion}
+	}
+	if from.HasLoginForm != to.HasLoginForm {
+		diff.LoginForm = &BoolChange{From: from.HasLoginForm, To: to.HasLoginForm}
+	}
+
+	// Only report heading levels whose count changed
+	for tag, count := range toHeadings {
+		if fromHeadings[tag] != count {
+			diff.Headings[tag] = newCountChange(fromHeadings[tag], count)
+		}
+	}
+	for tag, count := range fromHeadings {
+		if _, ok := toHeadings[tag]; !ok && count != 0 {
+			diff.Headings[tag] = newCountChange(count, 0)
+		}
+	}
+
+	if includeText {
+		diff.TextDiff = diffLines(splitLines(from.MainText), splitLines(to.MainText))
+	}
+
+	diff.Changed = diff.Title != nil || diff.HTMLVersion != nil || diff.LoginForm != nil ||
+		len(diff.Headings) > 0 || len(diff.NewlyBroken) > 0 || len(diff.NewlyFixed) > 0 ||
+		diff.InternalLinks.Delta != 0 || diff.ExternalLinks.Delta != 0 || len(diff.TextDiff) > 0
+
+	return diff, nil
+}
+
+// newCountChange builds a CountChange from two values
+func newCountChange(from, to int) CountChange {
+	return CountChange{From: from, To: to, Delta: to - from}
+}
+
+// parseHeadingCounts decodes the heading counts stored on a run
+func parseHeadingCounts(run *db.CrawlRun) (map[string]int, error) {
+	counts := make(map[string]int)
+	if run.HeadingCounts == "" {
+		return counts, nil
+	}
+	if err := json.Unmarshal([]byte(run.HeadingCounts), &counts); err != nil {
+		return nil, fmt.Errorf("failed to parse heading counts of run %d: %w", run.ID, err)
+	}
+	return counts, nil
+}
+
+// parseBrokenList decodes the broken links stored on a run
+func parseBrokenList(run *db.CrawlRun) ([]map[string]string, error) {
+	var links []map[string]string
+	if run.BrokenList == "" {
+		return links, nil
+	}
+	if err := json.Unmarshal([]byte(run.BrokenList), &links); err != nil {
+		return nil, fmt.Errorf("failed to parse broken list of run %d: %w", run.ID, err)
+	}
+	return links, nil
+}
+
+// subtractLinks returns the links in a whose URL does not appear in b, sorted by URL
+func subtractLinks(a, b []map[string]string) []map[string]string {
+	seen := make(map[string]bool, len(b))
+	for _, link := range b {
+		seen[link["url"]] = true
+	}
+
+	result := make([]map[string]string, 0)
+	for _, link := range a {
+		if !seen[link["url"]] {
+			result = append(result, link)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i]["url"] < result[j]["url"] })
+	return result
+}
+
+// splitLines splits main content into lines, treating empty content as no lines
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffLines computes the added and removed lines between a and b using an LCS table
+func diffLines(a, b []string) []LineChange {
+	// Common prefix and suffix never show up in the diff
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	changes := make([]LineChange, 0)
+	if len(a)*len(b) > maxTextDiffCells {
+		// Too large to align, report the differing region wholesale
+		for _, line := range a {
+			changes = append(changes, LineChange{Op: "remove", Text: line})
+		}
+		for _, line := range b {
+			changes = append(changes, LineChange{Op: "add", Text: line})
+		}
+		return changes
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			changes = append(changes, LineChange{Op: "remove", Text: a[i]})
+			i++
+		default:
+			changes = append(changes, LineChange{Op: "add", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		changes = append(changes, LineChange{Op: "remove", Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		changes = append(changes, LineChange{Op: "add", Text: b[j]})
+	}
+	return changes
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sykell/url-crawler/internal/db"
+)
+
+func TestDiffLines(t *testing.T) {
+	remove := func(text string) LineChange { return LineChange{Op: "remove", Text: text} }
+	add := func(text string) LineChange { return LineChange{Op: "add", Text: text} }
+
+	tests := []struct {
+		name string
+		a, b string
+		want []LineChange
+	}{
+		{"identical", "a\nb", "a\nb", []LineChange{}},
+		{"both empty", "", "", []LineChange{}},
+		{"from empty", "", "a\nb", []LineChange{add("a"), add("b")}},
+		{"to empty", "a\nb", "", []LineChange{remove("a"), remove("b")}},
+		{"changed line", "a\nb\nc", "a\nx\nc", []LineChange{remove("b"), add("x")}},
+		{"inserted line", "a\nc", "a\nb\nc", []LineChange{add("b")}},
+		{"removed lines", "a\nb\nc\nd", "a\nd", []LineChange{remove("b"), remove("c")}},
+		{"moved line", "a\nb\nc", "b\nc\na", []LineChange{remove("a"), add("a")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffLines(splitLines(tt.a), splitLines(tt.b))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLines(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesTooLargeToAlign(t *testing.T) {
+	// Past the table limit the differing region is replaced wholesale
+	lines := 2001
+	a := strings.Repeat("old\n", lines) + "end"
+	b := strings.Repeat("new\n", lines) + "end"
+	changes := diffLines(splitLines(a), splitLines(b))
+	if len(changes) != 2*lines || changes[0].Op != "remove" || changes[len(changes)-1].Op != "add" {
+		t.Errorf("diff of %d changed lines has %d changes", lines, len(changes))
+	}
+}
+
+func TestDiffCrawlRuns(t *testing.T) {
+	base := db.CrawlRun{
+		ID:            1,
+		Title:         "Shop",
+		HTMLVersion:   "HTML5",
+		HeadingCounts: `{"h1":1,"h2":3}`,
+		InternalLinks: 10,
+		ExternalLinks: 2,
+		BrokenLinks:   1,
+		BrokenList:    `[{"url":"https://example.com/gone","code":"404"}]`,
+		MainText:      "Fresh products\nEvery day",
+	}
+
+	tests := []struct {
+		name    string
+		change  func(run *db.CrawlRun)
+		changed bool
+		check   func(t *testing.T, diff *CrawlDiff)
+	}{
+		{
+			name:   "same results",
+			change: func(run *db.CrawlRun) {},
+			check: func(t *testing.T, diff *CrawlDiff) {
+				if diff.Title != nil || len(diff.Headings) != 0 || len(diff.NewlyBroken) != 0 || len(diff.TextDiff) != 0 {
+					t.Errorf("diff of equal runs = %+v", diff)
+				}
+			},
+		},
+		{
+			name:    "title and login form",
+			change:  func(run *db.CrawlRun) { run.Title = "Sale"; run.HasLoginForm = true },
+			changed: true,
+			check: func(t *testing.T, diff *CrawlDiff) {
+				if diff.Title == nil || *diff.Title != (StringChange{From: "Shop", To: "Sale"}) {
+					t.Errorf("title change = %v", diff.Title)
+				}
+				if diff.LoginForm == nil || !diff.LoginForm.To {
+					t.Errorf("login form change = %v", diff.LoginForm)
+				}
+			},
+		},
+		{
+			name:    "only changed heading levels",
+			change:  func(run *db.CrawlRun) { run.HeadingCounts = `{"h1":1,"h3":2}` },
+			changed: true,
+			check: func(t *testing.T, diff *CrawlDiff) {
+				want := map[string]CountChange{"h2": {From: 3, To: 0, Delta: -3}, "h3": {From: 0, To: 2, Delta: 2}}
+				if !reflect.DeepEqual(diff.Headings, want) {
+					t.Errorf("headings = %v, want %v", diff.Headings, want)
+				}
+			},
+		},
+		{
+			name: "broken links",
+			change: func(run *db.CrawlRun) {
+				run.BrokenList = `[{"url":"https://example.com/old","code":"500"},{"url":"https://example.com/a","code":"404"}]`
+				run.BrokenLinks = 2
+			},
+			changed: true,
+			check: func(t *testing.T, diff *CrawlDiff) {
+				if len(diff.NewlyBroken) != 2 || diff.NewlyBroken[0]["url"] != "https://example.com/a" {
+					t.Errorf("newly broken = %v, want both sorted by URL", diff.NewlyBroken)
+				}
+				if len(diff.NewlyFixed) != 1 || diff.NewlyFixed[0]["url"] != "https://example.com/gone" {
+					t.Errorf("newly fixed = %v", diff.NewlyFixed)
+				}
+				if diff.BrokenLinks != (CountChange{From: 1, To: 2, Delta: 1}) {
+					t.Errorf("broken links = %+v", diff.BrokenLinks)
+				}
+			},
+		},
+		{
+			name:    "link counts",
+			change:  func(run *db.CrawlRun) { run.InternalLinks = 7 },
+			changed: true,
+			check: func(t *testing.T, diff *CrawlDiff) {
+				if diff.InternalLinks.Delta != -3 || diff.ExternalLinks.Delta != 0 {
+					t.Errorf("link changes = %+v, %+v", diff.InternalLinks, diff.ExternalLinks)
+				}
+			},
+		},
+		{
+			name:    "main content",
+			change:  func(run *db.CrawlRun) { run.MainText = "Fresh products\nEvery week" },
+			changed: true,
+			check: func(t *testing.T, diff *CrawlDiff) {
+				want := []LineChange{{Op: "remove", Text: "Every day"}, {Op: "add", Text: "Every week"}}
+				if !reflect.DeepEqual(diff.TextDiff, want) {
+					t.Errorf("text diff = %v, want %v", diff.TextDiff, want)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := base, base
+			to.ID = 2
+			tt.change(&to)
+
+			diff, err := DiffCrawlRuns(&from, &to, true)
+			if err != nil {
+				t.Fatalf("DiffCrawlRuns: %v", err)
+			}
+			if diff.FromRunID != 1 || diff.ToRunID != 2 || diff.Changed != tt.changed {
+				t.Errorf("diff of runs %d to %d changed = %v, want %v", diff.FromRunID, diff.ToRunID, diff.Changed, tt.changed)
+			}
+			tt.check(t, diff)
+		})
+	}
+
+	// Text is only compared on request
+	to := base
+	to.MainText = "Something else"
+	if diff, _ := DiffCrawlRuns(&base, &to, false); diff.Changed || diff.TextDiff != nil {
+		t.Errorf("diff without text = %+v", diff)
+	}
+
+	to = base
+	to.HeadingCounts = "{"
+	if _, err := DiffCrawlRuns(&base, &to, false); err == nil {
+		t.Error("DiffCrawlRuns accepted malformed heading counts")
+	}
+}
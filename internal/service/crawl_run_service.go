@@ -0,0 +1,89 @@
+package service
+
+import (
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"gorm.io/gorm"
+)
+
+// CreateCrawlRun starts a new crawl run for a URL
+func CreateCrawlRun(dbConn *gorm.DB, urlID uint) (*db.CrawlRun, error) {
+	run := db.CrawlRun{
+		URLID:     urlID,
+		Status:    db.StatusRunning,
+		StartedAt: time.Now(),
+	}
+
+	if err := dbConn.Create(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetCrawlRun retrieves a single crawl run belonging to a URL
+func GetCrawlRun(dbConn *gorm.DB, urlID, runID uint) (*db.CrawlRun, error) {
+	var run db.CrawlRun
+	err := dbConn.Where("id = ? AND url_id = ?", runID, urlID).First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListCrawlRuns returns a page of crawl runs for a URL, newest first, with the total count
+func ListCrawlRuns(dbConn *gorm.DB, urlID uint, page, pageSize int) ([]db.CrawlRun, int64, error) {
+	query := dbConn.Model(&db.CrawlRun{}).Where("url_id = ?", urlID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var runs []db.CrawlRun
+	offset := (page - 1) * pageSize
+	if err := query.Order("id desc").Limit(pageSize).Offset(offset).Find(&runs).Error; err != nil {
+		return nil, 0, err
+	}
+	return runs, total, nil
+}
+
+// PruneCrawlRuns deletes all but the newest keep runs of a URL
+func PruneCrawlRuns(dbConn *gorm.DB, urlID uint, keep int) (int64, error) {
+	if keep <= 0 {
+		return 0, nil
+	}
+
+	// Find the oldest run that is still within the retention window
+	var cutoff db.CrawlRun
+	err := dbConn.Where("url_id = ?", urlID).Order("id desc").Offset(keep - 1).Limit(1).Select("id").Take(&cutoff).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, nil // Fewer runs than the retention limit
+		}
+		return 0, err
+	}
+
+	result := dbConn.Where("url_id = ? AND id < ?", urlID, cutoff.ID).Delete(&db.CrawlRun{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteCrawlRunsForURLs removes the crawl history of the given URLs
+func DeleteCrawlRunsForURLs(dbConn *gorm.DB, urlIDs []uint) error {
+	return dbConn.Where("url_id IN ?", urlIDs).Delete(&db.CrawlRun{}).Error
+}
+
+// GetPreviousFinishedRun retrieves the latest finished run of a URL, successful
+// or not, older than the given run. A zero beforeRunID considers all runs.
+func GetPreviousFinishedRun(dbConn *gorm.DB, urlID, beforeRunID uint) (*db.CrawlRun, error) {
+	query := dbConn.Where("url_id = ? AND status IN ?", urlID, []db.URLStatus{db.StatusDone, db.StatusUnchanged, db.StatusError})
+	if beforeRunID != 0 {
+		query = query.Where("id < ?", beforeRunID)
+	}
+
+	var run db.CrawlRun
+	if err := query.Order("id desc").First(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
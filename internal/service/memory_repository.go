@@ -0,0 +1,761 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"gorm.io/gorm"
+)
+
+// MemoryURLRepository is an in-memory URLRepository for tests
+type MemoryURLRepository struct {
+	mu        sync.Mutex
+	urls      map[uint]*db.URL
+	trash     map[uint]*db.URL // Soft-deleted URLs
+	runs      map[uint]*db.CrawlRun
+	nextURLID uint
+	nextRunID uint
+}
+
+// NewMemoryURLRepository creates an empty in-memory URL repository
+func NewMemoryURLRepository() *MemoryURLRepository {
+	return &MemoryURLRepository{
+		urls:  make(map[uint]*db.URL),
+		trash: make(map[uint]*db.URL),
+		runs:  make(map[uint]*db.CrawlRun),
+	}
+}
+
+func (r *MemoryURLRepository) Create(ctx context.Context, userID uint, address string) (*db.URL, error) {
+	if address == "" {
+		return nil, fmt.Errorf("address cannot be empty")
+	}
+	if userID == 0 {
+		return nil, fmt.Errorf("user ID cannot be zero")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextURLID++
+	now := time.Now()
+	url := &db.URL{
+		ID:        r.nextURLID,
+		UserID:    userID,
+		Address:   address,
+		Status:    db.StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.urls[url.ID] = url
+	copied := *url
+	return &copied, nil
+}
+
+func (r *MemoryURLRepository) GetByID(ctx context.Context, id uint) (*db.URL, error) {
+	return r.find(func(url *db.URL) bool { return url.ID == id })
+}
+
+func (r *MemoryURLRepository) GetForUser(ctx context.Context, id, userID uint) (*db.URL, error) {
+	return r.find(func(url *db.URL) bool { return url.ID == id && url.UserID == userID })
+}
+
+func (r *MemoryURLRepository) GetByAddress(ctx context.Context, userID uint, address string) (*db.URL, error) {
+	return r.find(func(url *db.URL) bool { return url.UserID == userID && url.Address == address })
+}
+
+// find returns a copy of the lowest ID URL matching
+func (r *MemoryURLRepository) find(match func(*db.URL) bool) (*db.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	urls := r.filter(match)
+	if len(urls) == 0 {
+		return nil, ErrNotFound
+	}
+	return &urls[0], nil
+}
+
+// filter returns copies of the matching URLs ordered by ID; callers hold r.mu
+func (r *MemoryURLRepository) filter(match func(*db.URL) bool) []db.URL {
+	var urls []db.URL
+	for _, url := range r.urls {
+		if match(url) {
+			urls = append(urls, *url)
+		}
+	}
+	sort.Slice(urls, func(i, j int) bool { return urls[i].ID < urls[j].ID })
+	return urls
+}
+
+func (r *MemoryURLRepository) List(ctx context.Context, filter URLFilter) ([]db.URL, int64, error) {
+	search := strings.ToLower(filter.Search)
+
+	r.mu.Lock()
+	urls := r.filter(func(url *db.URL) bool {
+		if url.UserID != filter.UserID {
+			return false
+		}
+		if filter.Status != "" && url.Status != filter.Status {
+			return false
+		}
+		return search == "" ||
+			strings.Contains(strings.ToLower(url.Address), search) ||
+			strings.Contains(strings.ToLower(url.Title), search)
+	})
+	r.mu.Unlock()
+
+	sortBy := filter.Sort
+	if !URLSorts[sortBy] {
+		sortBy = "created_at desc"
+	}
+	field, dir, _ := strings.Cut(sortBy, " ")
+	sort.SliceStable(urls, func(i, j int) bool {
+		a, b := urls[i], urls[j]
+		if dir == "desc" {
+			a, b = b, a
+		}
+		switch field {
+		case "updated_at":
+			return a.UpdatedAt.Before(b.UpdatedAt)
+		case "status":
+			return a.Status < b.Status
+		default:
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+	})
+
+	total := int64(len(urls))
+	offset := (filter.Page - 1) * filter.Size
+	if offset >= len(urls) {
+		return []db.URL{}, total, nil
+	}
+	end := offset + filter.Size
+	if end > len(urls) {
+		end = len(urls)
+	}
+	return urls[offset:end], total, nil
+}
+
+func (r *MemoryURLRepository) Delete(ctx context.Context, userID uint, ids []uint) ([]uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := []uint{}
+	for _, id := range ids {
+		url, ok := r.urls[id]
+		if !ok || url.UserID != userID {
+			continue
+		}
+		if url.Status == db.StatusQueued || url.Status == db.StatusRunning {
+			r.setStatus(url, db.StatusCancelled, url.Error)
+		}
+		url.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		delete(r.urls, id)
+		r.trash[id] = url
+		deleted = append(deleted, id)
+	}
+	return deleted, nil
+}
+
+func (r *MemoryURLRepository) ListTrash(ctx context.Context, userID uint, page, pageSize int) ([]db.URL, int64, error) {
+	r.mu.Lock()
+	var urls []db.URL
+	for _, url := range r.trash {
+		if url.UserID == userID {
+			urls = append(urls, *url)
+		}
+	}
+	r.mu.Unlock()
+
+	sort.Slice(urls, func(i, j int) bool {
+		a, b := urls[i], urls[j]
+		if !a.DeletedAt.Time.Equal(b.DeletedAt.Time) {
+			return a.DeletedAt.Time.After(b.DeletedAt.Time)
+		}
+		return a.ID > b.ID
+	})
+
+	total := int64(len(urls))
+	offset := (page - 1) * pageSize
+	if offset >= len(urls) {
+		return []db.URL{}, total, nil
+	}
+	end := offset + pageSize
+	if end > len(urls) {
+		end = len(urls)
+	}
+	return urls[offset:end], total, nil
+}
+
+func (r *MemoryURLRepository) Restore(ctx context.Context, userID uint, ids []uint) ([]uint, []uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	restored, conflicts := []uint{}, []uint{}
+	for _, id := range ids {
+		url, ok := r.trash[id]
+		if !ok || url.UserID != userID {
+			continue
+		}
+		if len(r.filter(func(u *db.URL) bool { return u.UserID == userID && u.Address == url.Address })) > 0 {
+			conflicts = append(conflicts, id)
+			continue
+		}
+		url.DeletedAt = gorm.DeletedAt{}
+		delete(r.trash, id)
+		r.urls[id] = url
+		restored = append(restored, id)
+	}
+	return restored, conflicts, nil
+}
+
+func (r *MemoryURLRepository) Purge(ctx context.Context, deletedBefore time.Time, limit int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ids []uint
+	for id, url := range r.trash {
+		if url.DeletedAt.Time.Before(deletedBefore) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	for _, id := range ids {
+		delete(r.trash, id)
+		for runID, run := range r.runs {
+			if run.URLID == id {
+				delete(r.runs, runID)
+			}
+		}
+	}
+	return int64(len(ids)), nil
+}
+
+func (r *MemoryURLRepository) UpdateStatus(ctx context.Context, id uint, status db.URLStatus, errorMsg string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if url, ok := r.urls[id]; ok {
+		r.setStatus(url, status, errorMsg)
+	}
+	return nil
+}
+
+// setStatus updates a stored URL's status; callers hold r.mu
+func (r *MemoryURLRepository) setStatus(url *db.URL, status db.URLStatus, errorMsg string) {
+	url.Status = status
+	url.Error = errorMsg
+	url.UpdatedAt = time.Now()
+}
+
+// transition moves a URL from one status to another, reporting whether it
+// was in the from status
+func (r *MemoryURLRepository) transition(id uint, from, to db.URLStatus) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.urls[id]
+	if !ok || url.Status != from {
+		return false
+	}
+	r.setStatus(url, to, "")
+	return true
+}
+
+func (r *MemoryURLRepository) ClaimQueued(ctx context.Context, id uint) (bool, error) {
+	return r.transition(id, db.StatusQueued, db.StatusRunning), nil
+}
+
+func (r *MemoryURLRepository) CancelQueued(ctx context.Context, id uint) (bool, error) {
+	return r.transition(id, db.StatusQueued, db.StatusCancelled), nil
+}
+
+func (r *MemoryURLRepository) Requeue(ctx context.Context, userID uint, ids []uint) ([]uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	requeued := []uint{}
+	for _, id := range ids {
+		if url, ok := r.urls[id]; ok && url.UserID == userID {
+			r.setStatus(url, db.StatusQueued, "")
+			url.QueuePriority = 0
+			requeued = append(requeued, id)
+		}
+	}
+	return requeued, nil
+}
+
+func (r *MemoryURLRepository) RequeueIdle(ctx context.Context, id uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.urls[id]
+	if !ok || url.Status == db.StatusQueued || url.Status == db.StatusRunning {
+		return false, nil
+	}
+	r.setStatus(url, db.StatusQueued, "")
+	url.QueuePriority = 0
+	return true, nil
+}
+
+func (r *MemoryURLRepository) UpdateAddress(ctx context.Context, id uint, address string) (bool, error) {
+	if address == "" {
+		return false, fmt.Errorf("address cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.updateAddress(id, address), nil
+}
+
+// updateAddress changes a URL's address like UpdateAddress; r.mu must be held
+func (r *MemoryURLRepository) updateAddress(id uint, address string) bool {
+	url, ok := r.urls[id]
+	if !ok || url.Status == db.StatusRunning {
+		return false
+	}
+	*url = db.URL{
+		ID:               url.ID,
+		UserID:           url.UserID,
+		Address:          address,
+		ScheduleInterval: url.ScheduleInterval,
+		ScheduleCron:     url.ScheduleCron,
+		ScheduleTimezone: url.ScheduleTimezone,
+		SchedulePaused:   url.SchedulePaused,
+		NextRunAt:        url.NextRunAt,
+		CreatedAt:        url.CreatedAt,
+		DeletedAt:        url.DeletedAt,
+	}
+	r.setStatus(url, db.StatusQueued, "")
+	for runID, run := range r.runs {
+		if run.URLID == id {
+			delete(r.runs, runID)
+		}
+	}
+	return true
+}
+
+func (r *MemoryURLRepository) Update(ctx context.Context, url *db.URL, address *string) (bool, error) {
+	if address != nil && *address == "" {
+		return false, fmt.Errorf("address cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if address != nil && !r.updateAddress(url.ID, *address) {
+		return false, nil
+	}
+	r.saveSchedule(url)
+	return true, nil
+}
+
+func (r *MemoryURLRepository) RequeueByStatus(ctx context.Context, status db.URLStatus) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var reset int64
+	for _, url := range r.urls {
+		if url.Status == status {
+			r.setStatus(url, db.StatusQueued, "")
+			reset++
+		}
+	}
+	return reset, nil
+}
+
+func (r *MemoryURLRepository) ListQueued(ctx context.Context, limit int) ([]db.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	urls := r.filter(func(url *db.URL) bool { return url.Status == db.StatusQueued })
+	sort.SliceStable(urls, func(i, j int) bool { return urls[i].QueuePriority > urls[j].QueuePriority })
+	if len(urls) > limit {
+		urls = urls[:limit]
+	}
+	return urls, nil
+}
+
+func (r *MemoryURLRepository) SetQueuePriority(ctx context.Context, id uint, priority int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if url, ok := r.urls[id]; ok && url.Status == db.StatusQueued {
+		url.QueuePriority = priority
+	}
+	return nil
+}
+
+func (r *MemoryURLRepository) SaveSchedule(ctx context.Context, url *db.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.saveSchedule(url)
+	return nil
+}
+
+// saveSchedule copies the schedule fields of url; r.mu must be held
+func (r *MemoryURLRepository) saveSchedule(url *db.URL) {
+	stored, ok := r.urls[url.ID]
+	if !ok {
+		return
+	}
+	stored.ScheduleInterval = url.ScheduleInterval
+	stored.ScheduleCron = url.ScheduleCron
+	stored.ScheduleTimezone = url.ScheduleTimezone
+	stored.SchedulePaused = url.SchedulePaused
+	stored.NextRunAt = copyTime(url.NextRunAt)
+	stored.UpdatedAt = time.Now()
+}
+
+func (r *MemoryURLRepository) ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]db.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	urls := r.filter(func(url *db.URL) bool {
+		return url.HasSchedule() && !url.SchedulePaused &&
+			url.NextRunAt != nil && !url.NextRunAt.After(now) &&
+			url.Status != db.StatusQueued && url.Status != db.StatusRunning
+	})
+	sort.SliceStable(urls, func(i, j int) bool { return urls[i].NextRunAt.Before(*urls[j].NextRunAt) })
+	if len(urls) > limit {
+		urls = urls[:limit]
+	}
+	return urls, nil
+}
+
+func (r *MemoryURLRepository) ClaimScheduledRun(ctx context.Context, url *db.URL, next time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.urls[url.ID]
+	if !ok || stored.NextRunAt == nil || url.NextRunAt == nil || !stored.NextRunAt.Equal(*url.NextRunAt) {
+		return false, nil
+	}
+	stored.NextRunAt = &next
+	r.setStatus(stored, db.StatusQueued, "")
+	stored.QueuePriority = 0
+	return true, nil
+}
+
+func (r *MemoryURLRepository) CreateRun(ctx context.Context, urlID uint) (*db.CrawlRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextRunID++
+	now := time.Now()
+	run := &db.CrawlRun{
+		ID:        r.nextRunID,
+		URLID:     urlID,
+		Status:    db.StatusRunning,
+		StartedAt: now,
+		CreatedAt: now,
+	}
+	r.runs[run.ID] = run
+	copied := *run
+	return &copied, nil
+}
+
+// urlRuns returns copies of a URL's runs matching, newest first; callers hold r.mu
+func (r *MemoryURLRepository) urlRuns(urlID uint, match func(*db.CrawlRun) bool) []db.CrawlRun {
+	var runs []db.CrawlRun
+	for _, run := range r.runs {
+		if run.URLID == urlID && match(run) {
+			runs = append(runs, *run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ID > runs[j].ID })
+	return runs
+}
+
+func (r *MemoryURLRepository) GetRun(ctx context.Context, urlID, runID uint) (*db.CrawlRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	runs := r.urlRuns(urlID, func(run *db.CrawlRun) bool { return run.ID == runID })
+	if len(runs) == 0 {
+		return nil, ErrNotFound
+	}
+	return &runs[0], nil
+}
+
+func (r *MemoryURLRepository) ListRuns(ctx context.Context, urlID uint, page, pageSize int) ([]db.CrawlRun, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	runs := r.urlRuns(urlID, func(*db.CrawlRun) bool { return true })
+	total := int64(len(runs))
+	offset := (page - 1) * pageSize
+	if offset >= len(runs) {
+		return []db.CrawlRun{}, total, nil
+	}
+	end := offset + pageSize
+	if end > len(runs) {
+		end = len(runs)
+	}
+	return runs[offset:end], total, nil
+}
+
+func (r *MemoryURLRepository) PreviousRun(ctx context.Context, urlID, beforeRunID uint) (*db.CrawlRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	runs := r.urlRuns(urlID, func(run *db.CrawlRun) bool { return run.ID < beforeRunID && run.HasResults() })
+	if len(runs) == 0 {
+		return nil, ErrNotFound
+	}
+	return &runs[0], nil
+}
+
+func (r *MemoryURLRepository) PreviousFinishedRun(ctx context.Context, urlID, beforeRunID uint) (*db.CrawlRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	runs := r.urlRuns(urlID, func(run *db.CrawlRun) bool {
+		if beforeRunID != 0 && run.ID >= beforeRunID {
+			return false
+		}
+		return run.Status == db.StatusDone || run.Status == db.StatusUnchanged || run.Status == db.StatusError
+	})
+	if len(runs) == 0 {
+		return nil, ErrNotFound
+	}
+	return &runs[0], nil
+}
+
+func (r *MemoryURLRepository) FinishRun(ctx context.Context, run *db.CrawlRun, update URLRunUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.runs[run.ID]
+	if !ok {
+		return nil
+	}
+	stored.Status = run.Status
+	stored.Error = run.Error
+	stored.FinishedAt = copyTime(run.FinishedAt)
+	stored.DurationMs = run.DurationMs
+	if run.HasResults() {
+		stored.SetResults(run)
+		stored.ContentChanged = run.ContentChanged
+	}
+
+	url, ok := r.urls[run.URLID]
+	if !ok {
+		return nil
+	}
+	r.setStatus(url, update.Status, update.Error)
+	if update.LastRunID != nil {
+		id := *update.LastRunID
+		url.LastRunID = &id
+	}
+	if update.LastRunAt != nil {
+		url.LastRunAt = copyTime(update.LastRunAt)
+	}
+	if update.Results {
+		url.Title = run.Title
+		url.HTMLVersion = run.HTMLVersion
+		url.HeadingCounts = run.HeadingCounts
+		url.InternalLinks = run.InternalLinks
+		url.ExternalLinks = run.ExternalLinks
+		url.BrokenLinks = run.BrokenLinks
+		url.BrokenList = run.BrokenList
+		url.HasLoginForm = run.HasLoginForm
+		url.ETag = update.ETag
+		url.LastModified = update.LastModified
+	}
+	if update.ContentChanged != nil {
+		url.ContentChanged = *update.ContentChanged
+	}
+	if update.ContentChangedAt != nil {
+		url.ContentChangedAt = copyTime(update.ContentChangedAt)
+	}
+	return nil
+}
+
+func (r *MemoryURLRepository) PruneRuns(ctx context.Context, urlID uint, keep int) (int64, error) {
+	if keep <= 0 {
+		return 0, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	runs := r.urlRuns(urlID, func(*db.CrawlRun) bool { return true })
+	if len(runs) <= keep {
+		return 0, nil
+	}
+	for _, run := range runs[keep:] {
+		delete(r.runs, run.ID)
+	}
+	return int64(len(runs) - keep), nil
+}
+
+// MemoryUserRepository is an in-memory UserRepository for tests
+type MemoryUserRepository struct {
+	mu     sync.Mutex
+	users  map[string]*db.User
+	nextID uint
+}
+
+// NewMemoryUserRepository creates an empty in-memory user repository
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{users: make(map[string]*db.User)}
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, username, password string) error {
+	if username == "" || password == "" {
+		return fmt.Errorf("username and password cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.users[username]; exists {
+		return fmt.Errorf("username %q already exists", username)
+	}
+	r.nextID++
+	now := time.Now()
+	r.users[username] = &db.User{
+		ID:        r.nextID,
+		Username:  username,
+		Password:  password,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) GetByUsername(ctx context.Context, username string) (*db.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[username]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *user
+	copied.DisabledAt = copyTime(user.DisabledAt)
+	return &copied, nil
+}
+
+func (r *MemoryUserRepository) GetByID(ctx context.Context, id uint) (*db.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.ID == id {
+			copied := *user
+			copied.DisabledAt = copyTime(user.DisabledAt)
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryUserRepository) List(ctx context.Context) ([]db.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users := make([]db.User, 0, len(r.users))
+	for _, user := range r.users {
+		copied := *user
+		copied.DisabledAt = copyTime(user.DisabledAt)
+		users = append(users, copied)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (r *MemoryUserRepository) PromoteAdmins(ctx context.Context, usernames []string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var promoted int64
+	for _, username := range usernames {
+		if user, ok := r.users[username]; ok && !user.IsAdmin {
+			user.IsAdmin = true
+			user.UpdatedAt = time.Now()
+			promoted++
+		}
+	}
+	return promoted, nil
+}
+
+func (r *MemoryUserRepository) SyncAdmins(ctx context.Context, usernames []string) (int64, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var granted, revoked int64
+	for _, user := range r.users {
+		listed := slices.Contains(usernames, user.Username)
+		if listed == user.IsAdmin {
+			continue
+		}
+		user.IsAdmin = listed
+		user.UpdatedAt = time.Now()
+		if listed {
+			granted++
+		} else {
+			revoked++
+		}
+	}
+	return granted, revoked, nil
+}
+
+func (r *MemoryUserRepository) SetDisabled(ctx context.Context, username string, disabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[username]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	user.DisabledAt = nil
+	if disabled {
+		user.DisabledAt = &now
+		user.TokenVersion++
+	}
+	user.UpdatedAt = now
+	return nil
+}
+
+func (r *MemoryUserRepository) UpdatePassword(ctx context.Context, username, password string) error {
+	if password == "" {
+		return fmt.Errorf("password cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[username]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Password = password
+	user.TokenVersion++
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// copyTime returns a copy of t so stored records don't alias caller memory
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	copied := *t
+	return &copied
+}
@@ -0,0 +1,606 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by repositories when no record matches
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// URLSorts lists the orderings accepted by URLFilter.Sort
+var URLSorts = map[string]bool{
+	"created_at desc": true,
+	"created_at asc":  true,
+	"updated_at desc": true,
+	"updated_at asc":  true,
+	"status asc":      true,
+	"status desc":     true,
+}
+
+// URLFilter selects a page of a user's URLs
+type URLFilter struct {
+	UserID uint
+	Search string // Case-insensitive match on address or title
+	Status db.URLStatus
+	Sort   string // One of URLSorts
+	Page   int
+	Size   int
+}
+
+// URLRepository stores URLs together with their crawl runs
+type URLRepository interface {
+	Create(ctx context.Context, userID uint, address string) (*db.URL, error)
+	GetByID(ctx context.Context, id uint) (*db.URL, error)
+	GetForUser(ctx context.Context, id, userID uint) (*db.URL, error)
+	GetByAddress(ctx context.Context, userID uint, address string) (*db.URL, error)
+	// List returns a page of URLs matching the filter and the total match count
+	List(ctx context.Context, filter URLFilter) ([]db.URL, int64, error)
+	// Delete moves the given URLs owned by a user to the trash, cancelling
+	// their pending crawls, and returns the IDs that were deleted
+	Delete(ctx context.Context, userID uint, ids []uint) ([]uint, error)
+	// ListTrash returns a page of a user's deleted URLs, most recently
+	// deleted first, and their total count
+	ListTrash(ctx context.Context, userID uint, page, pageSize int) ([]db.URL, int64, error)
+	// Restore takes the given URLs owned by a user out of the trash and
+	// returns the IDs that were restored. URLs whose address the user has
+	// added again since stay in the trash and are returned as conflicts.
+	Restore(ctx context.Context, userID uint, ids []uint) (restored, conflicts []uint, err error)
+	// Purge permanently removes up to limit URLs deleted before the given
+	// time along with their history and returns how many were removed
+	Purge(ctx context.Context, deletedBefore time.Time, limit int) (int64, error)
+
+	UpdateStatus(ctx context.Context, id uint, status db.URLStatus, errorMsg string) error
+	ClaimQueued(ctx context.Context, id uint) (bool, error)
+	CancelQueued(ctx context.Context, id uint) (bool, error)
+	Requeue(ctx context.Context, userID uint, ids []uint) ([]uint, error)
+	// RequeueIdle queues a URL unless it is already queued or running
+	RequeueIdle(ctx context.Context, id uint) (bool, error)
+	// UpdateAddress changes a URL's address and queues it, unless it is
+	// running. The results and run history of the old address are dropped.
+	UpdateAddress(ctx context.Context, id uint, address string) (bool, error)
+	// Update saves the schedule fields of url and, when address is set,
+	// changes its address like UpdateAddress, atomically. It reports false
+	// without saving anything when the URL is running and the address can't
+	// change.
+	Update(ctx context.Context, url *db.URL, address *string) (bool, error)
+	RequeueByStatus(ctx context.Context, status db.URLStatus) (int64, error)
+	// ListQueued returns up to limit queued URLs, highest queue priority first
+	ListQueued(ctx context.Context, limit int) ([]db.URL, error)
+	// SetQueuePriority records the crawl queue priority of a queued URL.
+	// URLs queued anew start at 0 until the crawler enqueues them.
+	SetQueuePriority(ctx context.Context, id uint, priority int) error
+
+	// SaveSchedule persists the schedule fields of url
+	SaveSchedule(ctx context.Context, url *db.URL) error
+	ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]db.URL, error)
+	ClaimScheduledRun(ctx context.Context, url *db.URL, next time.Time) (bool, error)
+
+	CreateRun(ctx context.Context, urlID uint) (*db.CrawlRun, error)
+	GetRun(ctx context.Context, urlID, runID uint) (*db.CrawlRun, error)
+	ListRuns(ctx context.Context, urlID uint, page, pageSize int) ([]db.CrawlRun, int64, error)
+	PreviousRun(ctx context.Context, urlID, beforeRunID uint) (*db.CrawlRun, error)
+	// PreviousFinishedRun returns the latest finished run, successful or
+	// not, older than beforeRunID. A zero beforeRunID considers all runs.
+	PreviousFinishedRun(ctx context.Context, urlID, beforeRunID uint) (*db.CrawlRun, error)
+	// FinishRun stores the final status, error and timing of a run, plus its
+	// results when it has any, and applies update to its URL atomically
+	FinishRun(ctx context.Context, run *db.CrawlRun, update URLRunUpdate) error
+	PruneRuns(ctx context.Context, urlID uint, keep int) (int64, error)
+}
+
+// URLRunUpdate describes how a finished run changes its URL. Nil fields are
+// left as they are.
+type URLRunUpdate struct {
+	Status    db.URLStatus
+	Error     string
+	LastRunID *uint
+	LastRunAt *time.Time
+	// Results makes the run's results and the validators below the URL's own
+	Results          bool
+	ETag             string
+	LastModified     string
+	ContentChanged   *bool
+	ContentChangedAt *time.Time
+}
+
+// UserRepository stores user accounts
+type UserRepository interface {
+	Create(ctx context.Context, username, password string) error
+	GetByUsername(ctx context.Context, username string) (*db.User, error)
+	GetByID(ctx context.Context, id uint) (*db.User, error)
+	List(ctx context.Context) ([]db.User, error)
+	PromoteAdmins(ctx context.Context, usernames []string) (int64, error)
+	SyncAdmins(ctx context.Context, usernames []string) (granted, revoked int64, err error)
+	SetDisabled(ctx context.Context, username string, disabled bool) error
+	UpdatePassword(ctx context.Context, username, password string) error
+}
+
+// WebhookRepository stores webhooks together with their deliveries
+type WebhookRepository interface {
+	Create(ctx context.Context, userID uint, url, secret string, eventTypes []string) (*db.Webhook, error)
+	List(ctx context.Context, userID uint) ([]db.Webhook, error)
+	GetByID(ctx context.Context, id uint) (*db.Webhook, error)
+	GetForUser(ctx context.Context, id, userID uint) (*db.Webhook, error)
+	Update(ctx context.Context, id uint, update WebhookUpdate) error
+	Delete(ctx context.Context, id uint) error
+	ListActiveForEvent(ctx context.Context, userID uint, eventType string) ([]db.Webhook, error)
+	ResetFailures(ctx context.Context, id uint) error
+	// RecordFailure counts a failed attempt and deactivates the webhook when
+	// disable is set
+	RecordFailure(ctx context.Context, id uint, disable bool) error
+
+	CreateDelivery(ctx context.Context, webhookID uint, eventType, payload string) (*db.WebhookDelivery, error)
+	GetDelivery(ctx context.Context, webhookID, deliveryID uint) (*db.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, webhookID uint, page, pageSize int) ([]db.WebhookDelivery, int64, error)
+	// ClaimDueDeliveries leases up to limit due deliveries to the caller, see
+	// ClaimDueWebhookDeliveries
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]db.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, id uint, attempt DeliveryAttempt) error
+	// ReleaseDelivery gives up a delivery's claim without an attempt, making
+	// it due again
+	ReleaseDelivery(ctx context.Context, id uint) error
+}
+
+// AlertRepository stores alert rules and the alerts waiting for a digest
+type AlertRepository interface {
+	CreateRule(ctx context.Context, rule *db.AlertRule) error
+	ListRules(ctx context.Context, userID uint) ([]db.AlertRule, error)
+	GetRule(ctx context.Context, id uint) (*db.AlertRule, error)
+	GetRuleForUser(ctx context.Context, id, userID uint) (*db.AlertRule, error)
+	// UpdateRule saves the URL, email and triggers of rule
+	UpdateRule(ctx context.Context, rule *db.AlertRule) error
+	DeleteRule(ctx context.Context, id uint) error
+	// ListRulesForURL returns the user's rules for the URL and for all URLs
+	ListRulesForURL(ctx context.Context, userID, urlID uint) ([]db.AlertRule, error)
+	MarkRuleSent(ctx context.Context, id uint, sentAt time.Time) error
+
+	CreatePending(ctx context.Context, alert *db.PendingAlert) error
+	ListPending(ctx context.Context) ([]db.PendingAlert, error)
+	DeletePending(ctx context.Context, ids []uint) error
+}
+
+var (
+	_ URLRepository     = (*GormURLRepository)(nil)
+	_ URLRepository     = (*MemoryURLRepository)(nil)
+	_ UserRepository    = (*GormUserRepository)(nil)
+	_ UserRepository    = (*MemoryUserRepository)(nil)
+	_ WebhookRepository = (*GormWebhookRepository)(nil)
+	_ AlertRepository   = (*GormAlertRepository)(nil)
+)
+
+// GormURLRepository is a URLRepository backed by the database
+type GormURLRepository struct {
+	db *gorm.DB
+}
+
+// NewGormURLRepository creates a URL repository using dbConn
+func NewGormURLRepository(dbConn *gorm.DB) *GormURLRepository {
+	return &GormURLRepository{db: dbConn}
+}
+
+// conn scopes the connection to ctx
+func (r *GormURLRepository) conn(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx)
+}
+
+func (r *GormURLRepository) Create(ctx context.Context, userID uint, address string) (*db.URL, error) {
+	return CreateURL(r.conn(ctx), userID, address)
+}
+
+func (r *GormURLRepository) GetByID(ctx context.Context, id uint) (*db.URL, error) {
+	return GetURLByID(r.conn(ctx), id)
+}
+
+func (r *GormURLRepository) GetForUser(ctx context.Context, id, userID uint) (*db.URL, error) {
+	return GetURLByIDAndUser(r.conn(ctx), id, userID)
+}
+
+func (r *GormURLRepository) GetByAddress(ctx context.Context, userID uint, address string) (*db.URL, error) {
+	return GetURLByAddress(r.conn(ctx), userID, address)
+}
+
+func (r *GormURLRepository) List(ctx context.Context, filter URLFilter) ([]db.URL, int64, error) {
+	query := r.conn(ctx).Model(&db.URL{}).Where("user_id = ?", filter.UserID)
+
+	if filter.Search != "" {
+		pattern := db.ContainsPattern(filter.Search)
+		query = query.Where(db.ContainsCondition("address")+" OR "+db.ContainsCondition("title"), pattern, pattern)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	sort := filter.Sort
+	if !URLSorts[sort] {
+		sort = "created_at desc"
+	}
+
+	var urls []db.URL
+	offset := (filter.Page - 1) * filter.Size
+	if err := query.Order(sort).Limit(filter.Size).Offset(offset).Find(&urls).Error; err != nil {
+		return nil, 0, err
+	}
+	return urls, total, nil
+}
+
+func (r *GormURLRepository) Delete(ctx context.Context, userID uint, ids []uint) ([]uint, error) {
+	var ownedIDs []uint
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the rows so workers can't claim URLs being deleted
+		if err := db.ForUpdate(tx).Model(&db.URL{}).Where("id IN ? AND user_id = ?", ids, userID).Pluck("id", &ownedIDs).Error; err != nil {
+			return err
+		}
+		if len(ownedIDs) == 0 {
+			return nil
+		}
+		err := tx.Model(&db.URL{}).Where("id IN ? AND status IN ?", ownedIDs, []db.URLStatus{db.StatusQueued, db.StatusRunning}).
+			Update("status", db.StatusCancelled).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ownedIDs).Delete(&db.URL{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ownedIDs, nil
+}
+
+func (r *GormURLRepository) ListTrash(ctx context.Context, userID uint, page, pageSize int) ([]db.URL, int64, error) {
+	return ListDeletedURLs(r.conn(ctx), userID, page, pageSize)
+}
+
+func (r *GormURLRepository) Restore(ctx context.Context, userID uint, ids []uint) ([]uint, []uint, error) {
+	restored, conflicts := []uint{}, []uint{}
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		var deleted []db.URL
+		err := db.ForUpdate(tx).Unscoped().Select("id", "address").
+			Where("id IN ? AND user_id = ? AND deleted_at IS NOT NULL", ids, userID).Find(&deleted).Error
+		if err != nil {
+			return err
+		}
+
+		for _, url := range deleted {
+			if _, err := GetURLByAddress(tx, userID, url.Address); err == nil {
+				conflicts = append(conflicts, url.ID)
+				continue
+			} else if err != ErrNotFound {
+				return err
+			}
+			if err := tx.Unscoped().Model(&db.URL{}).Where("id = ?", url.ID).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+			restored = append(restored, url.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return restored, conflicts, nil
+}
+
+func (r *GormURLRepository) Purge(ctx context.Context, deletedBefore time.Time, limit int) (int64, error) {
+	var purged int64
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := db.ForUpdate(tx).Unscoped().Model(&db.URL{}).Where("deleted_at < ?", deletedBefore).
+			Order("id").Limit(limit).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		if err := DeleteCrawlRunsForURLs(tx, ids); err != nil {
+			return err
+		}
+		if err := DeleteAlertRulesForURLs(tx, ids); err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&db.URL{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
+}
+
+func (r *GormURLRepository) UpdateStatus(ctx context.Context, id uint, status db.URLStatus, errorMsg string) error {
+	return UpdateURLStatus(r.conn(ctx), id, status, errorMsg)
+}
+
+func (r *GormURLRepository) ClaimQueued(ctx context.Context, id uint) (bool, error) {
+	return ClaimQueuedURL(r.conn(ctx), id)
+}
+
+func (r *GormURLRepository) CancelQueued(ctx context.Context, id uint) (bool, error) {
+	return CancelQueuedURL(r.conn(ctx), id)
+}
+
+func (r *GormURLRepository) Requeue(ctx context.Context, userID uint, ids []uint) ([]uint, error) {
+	return RequeueURLs(r.conn(ctx), userID, ids)
+}
+
+func (r *GormURLRepository) RequeueIdle(ctx context.Context, id uint) (bool, error) {
+	return RequeueIdleURL(r.conn(ctx), id)
+}
+
+func (r *GormURLRepository) UpdateAddress(ctx context.Context, id uint, address string) (bool, error) {
+	return UpdateURLAddress(r.conn(ctx), id, address)
+}
+
+func (r *GormURLRepository) Update(ctx context.Context, url *db.URL, address *string) (bool, error) {
+	return UpdateURL(r.conn(ctx), url, address)
+}
+
+func (r *GormURLRepository) RequeueByStatus(ctx context.Context, status db.URLStatus) (int64, error) {
+	return RequeueURLsByStatus(r.conn(ctx), status)
+}
+
+func (r *GormURLRepository) ListQueued(ctx context.Context, limit int) ([]db.URL, error) {
+	return ListQueuedURLs(r.conn(ctx), limit)
+}
+
+func (r *GormURLRepository) SetQueuePriority(ctx context.Context, id uint, priority int) error {
+	return SetURLQueuePriority(r.conn(ctx), id, priority)
+}
+
+func (r *GormURLRepository) SaveSchedule(ctx context.Context, url *db.URL) error {
+	return SaveURLSchedule(r.conn(ctx), url)
+}
+
+func (r *GormURLRepository) ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]db.URL, error) {
+	return ListDueScheduledURLs(r.conn(ctx), now, limit)
+}
+
+func (r *GormURLRepository) ClaimScheduledRun(ctx context.Context, url *db.URL, next time.Time) (bool, error) {
+	return ClaimScheduledRun(r.conn(ctx), url, next)
+}
+
+func (r *GormURLRepository) CreateRun(ctx context.Context, urlID uint) (*db.CrawlRun, error) {
+	return CreateCrawlRun(r.conn(ctx), urlID)
+}
+
+func (r *GormURLRepository) GetRun(ctx context.Context, urlID, runID uint) (*db.CrawlRun, error) {
+	return GetCrawlRun(r.conn(ctx), urlID, runID)
+}
+
+func (r *GormURLRepository) ListRuns(ctx context.Context, urlID uint, page, pageSize int) ([]db.CrawlRun, int64, error) {
+	return ListCrawlRuns(r.conn(ctx), urlID, page, pageSize)
+}
+
+func (r *GormURLRepository) PreviousRun(ctx context.Context, urlID, beforeRunID uint) (*db.CrawlRun, error) {
+	return GetPreviousCrawlRun(r.conn(ctx), urlID, beforeRunID)
+}
+
+func (r *GormURLRepository) PreviousFinishedRun(ctx context.Context, urlID, beforeRunID uint) (*db.CrawlRun, error) {
+	return GetPreviousFinishedRun(r.conn(ctx), urlID, beforeRunID)
+}
+
+func (r *GormURLRepository) FinishRun(ctx context.Context, run *db.CrawlRun, update URLRunUpdate) error {
+	runColumns := []string{"status", "error", "finished_at", "duration_ms"}
+	if run.HasResults() {
+		runColumns = append(runColumns, "content_changed", "title", "html_version", "heading_counts", "internal_links", "external_links",
+			"broken_links", "broken_list", "has_login_form", "main_text", "body_hash", "text_hash", "text_simhash")
+	}
+
+	urlUpdates := map[string]interface{}{
+		"status": update.Status,
+		"error":  update.Error,
+	}
+	if update.LastRunID != nil {
+		urlUpdates["last_run_id"] = *update.LastRunID
+	}
+	if update.LastRunAt != nil {
+		urlUpdates["last_run_at"] = *update.LastRunAt
+	}
+	if update.Results {
+		urlUpdates["title"] = run.Title
+		urlUpdates["html_version"] = run.HTMLVersion
+		urlUpdates["heading_counts"] = run.HeadingCounts
+		urlUpdates["internal_links"] = run.InternalLinks
+		urlUpdates["external_links"] = run.ExternalLinks
+		urlUpdates["broken_links"] = run.BrokenLinks
+		urlUpdates["broken_list"] = run.BrokenList
+		urlUpdates["has_login_form"] = run.HasLoginForm
+		urlUpdates["etag"] = update.ETag
+		urlUpdates["last_modified"] = update.LastModified
+	}
+	if update.ContentChanged != nil {
+		urlUpdates["content_changed"] = *update.ContentChanged
+	}
+	if update.ContentChangedAt != nil {
+		urlUpdates["content_changed_at"] = *update.ContentChangedAt
+	}
+
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&db.CrawlRun{}).Where("id = ?", run.ID).Select(runColumns).Updates(run).Error; err != nil {
+			return err
+		}
+		return tx.Model(&db.URL{}).Where("id = ?", run.URLID).Updates(urlUpdates).Error
+	})
+}
+
+func (r *GormURLRepository) PruneRuns(ctx context.Context, urlID uint, keep int) (int64, error) {
+	return PruneCrawlRuns(r.conn(ctx), urlID, keep)
+}
+
+// GormUserRepository is a UserRepository backed by the database
+type GormUserRepository struct {
+	db *gorm.DB
+}
+
+// NewGormUserRepository creates a user repository using dbConn
+func NewGormUserRepository(dbConn *gorm.DB) *GormUserRepository {
+	return &GormUserRepository{db: dbConn}
+}
+
+func (r *GormUserRepository) Create(ctx context.Context, username, password string) error {
+	return CreateUser(r.db.WithContext(ctx), username, password)
+}
+
+func (r *GormUserRepository) GetByUsername(ctx context.Context, username string) (*db.User, error) {
+	return GetUserByUsername(r.db.WithContext(ctx), username)
+}
+
+func (r *GormUserRepository) GetByID(ctx context.Context, id uint) (*db.User, error) {
+	return GetUserByID(r.db.WithContext(ctx), id)
+}
+
+func (r *GormUserRepository) List(ctx context.Context) ([]db.User, error) {
+	return ListUsers(r.db.WithContext(ctx))
+}
+
+func (r *GormUserRepository) PromoteAdmins(ctx context.Context, usernames []string) (int64, error) {
+	return PromoteAdmins(r.db.WithContext(ctx), usernames)
+}
+
+func (r *GormUserRepository) SyncAdmins(ctx context.Context, usernames []string) (int64, int64, error) {
+	return SyncAdmins(r.db.WithContext(ctx), usernames)
+}
+
+func (r *GormUserRepository) SetDisabled(ctx context.Context, username string, disabled bool) error {
+	return SetUserDisabled(r.db.WithContext(ctx), username, disabled)
+}
+
+func (r *GormUserRepository) UpdatePassword(ctx context.Context, username, password string) error {
+	return UpdateUserPassword(r.db.WithContext(ctx), username, password)
+}
+
+// GormWebhookRepository is a WebhookRepository backed by the database
+type GormWebhookRepository struct {
+	db *gorm.DB
+}
+
+// NewGormWebhookRepository creates a webhook repository using dbConn
+func NewGormWebhookRepository(dbConn *gorm.DB) *GormWebhookRepository {
+	return &GormWebhookRepository{db: dbConn}
+}
+
+func (r *GormWebhookRepository) conn(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx)
+}
+
+func (r *GormWebhookRepository) Create(ctx context.Context, userID uint, url, secret string, eventTypes []string) (*db.Webhook, error) {
+	return CreateWebhook(r.conn(ctx), userID, url, secret, eventTypes)
+}
+
+func (r *GormWebhookRepository) List(ctx context.Context, userID uint) ([]db.Webhook, error) {
+	return ListWebhooks(r.conn(ctx), userID)
+}
+
+func (r *GormWebhookRepository) GetByID(ctx context.Context, id uint) (*db.Webhook, error) {
+	return GetWebhookByID(r.conn(ctx), id)
+}
+
+func (r *GormWebhookRepository) GetForUser(ctx context.Context, id, userID uint) (*db.Webhook, error) {
+	return GetWebhookByIDAndUser(r.conn(ctx), id, userID)
+}
+
+func (r *GormWebhookRepository) Update(ctx context.Context, id uint, update WebhookUpdate) error {
+	return UpdateWebhook(r.conn(ctx), id, update)
+}
+
+func (r *GormWebhookRepository) Delete(ctx context.Context, id uint) error {
+	return DeleteWebhook(r.conn(ctx), id)
+}
+
+func (r *GormWebhookRepository) ListActiveForEvent(ctx context.Context, userID uint, eventType string) ([]db.Webhook, error) {
+	return ListActiveWebhooksForEvent(r.conn(ctx), userID, eventType)
+}
+
+func (r *GormWebhookRepository) ResetFailures(ctx context.Context, id uint) error {
+	return ResetWebhookFailures(r.conn(ctx), id)
+}
+
+func (r *GormWebhookRepository) RecordFailure(ctx context.Context, id uint, disable bool) error {
+	return RecordWebhookFailure(r.conn(ctx), id, disable)
+}
+
+func (r *GormWebhookRepository) CreateDelivery(ctx context.Context, webhookID uint, eventType, payload string) (*db.WebhookDelivery, error) {
+	return CreateWebhookDelivery(r.conn(ctx), webhookID, eventType, payload)
+}
+
+func (r *GormWebhookRepository) GetDelivery(ctx context.Context, webhookID, deliveryID uint) (*db.WebhookDelivery, error) {
+	return GetWebhookDelivery(r.conn(ctx), webhookID, deliveryID)
+}
+
+func (r *GormWebhookRepository) ListDeliveries(ctx context.Context, webhookID uint, page, pageSize int) ([]db.WebhookDelivery, int64, error) {
+	return ListWebhookDeliveries(r.conn(ctx), webhookID, page, pageSize)
+}
+
+func (r *GormWebhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]db.WebhookDelivery, error) {
+	return ClaimDueWebhookDeliveries(r.conn(ctx), now, lease, limit)
+}
+
+func (r *GormWebhookRepository) RecordAttempt(ctx context.Context, id uint, attempt DeliveryAttempt) error {
+	return RecordWebhookAttempt(r.conn(ctx), id, attempt)
+}
+
+func (r *GormWebhookRepository) ReleaseDelivery(ctx context.Context, id uint) error {
+	return ReleaseWebhookDelivery(r.conn(ctx), id, time.Now())
+}
+
+// GormAlertRepository is an AlertRepository backed by the database
+type GormAlertRepository struct {
+	db *gorm.DB
+}
+
+// NewGormAlertRepository creates an alert repository using dbConn
+func NewGormAlertRepository(dbConn *gorm.DB) *GormAlertRepository {
+	return &GormAlertRepository{db: dbConn}
+}
+
+func (r *GormAlertRepository) conn(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx)
+}
+
+func (r *GormAlertRepository) CreateRule(ctx context.Context, rule *db.AlertRule) error {
+	return CreateAlertRule(r.conn(ctx), rule)
+}
+
+func (r *GormAlertRepository) ListRules(ctx context.Context, userID uint) ([]db.AlertRule, error) {
+	return ListAlertRules(r.conn(ctx), userID)
+}
+
+func (r *GormAlertRepository) GetRule(ctx context.Context, id uint) (*db.AlertRule, error) {
+	return GetAlertRuleByID(r.conn(ctx), id)
+}
+
+func (r *GormAlertRepository) GetRuleForUser(ctx context.Context, id, userID uint) (*db.AlertRule, error) {
+	return GetAlertRuleByIDAndUser(r.conn(ctx), id, userID)
+}
+
+func (r *GormAlertRepository) UpdateRule(ctx context.Context, rule *db.AlertRule) error {
+	return UpdateAlertRule(r.conn(ctx), rule)
+}
+
+func (r *GormAlertRepository) DeleteRule(ctx context.Context, id uint) error {
+	return DeleteAlertRule(r.conn(ctx), id)
+}
+
+func (r *GormAlertRepository) ListRulesForURL(ctx context.Context, userID, urlID uint) ([]db.AlertRule, error) {
+	return ListAlertRulesForURL(r.conn(ctx), userID, urlID)
+}
+
+func (r *GormAlertRepository) MarkRuleSent(ctx context.Context, id uint, sentAt time.Time) error {
+	return MarkAlertRuleSent(r.conn(ctx), id, sentAt)
+}
+
+func (r *GormAlertRepository) CreatePending(ctx context.Context, alert *db.PendingAlert) error {
+	return CreatePendingAlert(r.conn(ctx), alert)
+}
+
+func (r *GormAlertRepository) ListPending(ctx context.Context) ([]db.PendingAlert, error) {
+	return ListPendingAlerts(r.conn(ctx))
+}
+
+func (r *GormAlertRepository) DeletePending(ctx context.Context, ids []uint) error {
+	return DeletePendingAlerts(r.conn(ctx), ids)
+}
@@ -0,0 +1,540 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/sykell/url-crawler/internal/db"
+)
+
+// newTestDB opens a migrated in-memory SQLite database
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dbConn, err := db.Open(&db.Config{
+		Driver:      db.DriverSQLite,
+		DSN:         ":memory:",
+		MaxOpen:     1,
+		MaxIdle:     1,
+		Timeout:     time.Minute,
+		AutoMigrate: true,
+	})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := dbConn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return dbConn
+}
+
+// newTestUser creates a user and returns its ID
+func newTestUser(t *testing.T, dbConn *gorm.DB, username string) uint {
+	t.Helper()
+	users := NewGormUserRepository(dbConn)
+	if err := users.Create(context.Background(), username, "password123"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := users.GetByUsername(context.Background(), username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+// finishTestRun claims a URL and finishes a run of it with status. Runs
+// with results get title as theirs.
+func finishTestRun(t *testing.T, urls URLRepository, urlID uint, status db.URLStatus, title string) *db.CrawlRun {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := urls.RequeueIdle(ctx, urlID); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, err := urls.ClaimQueued(ctx, urlID); err != nil || !claimed {
+		t.Fatalf("ClaimQueued = %v, %v", claimed, err)
+	}
+	run, err := urls.CreateRun(ctx, urlID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finishedAt := time.Now()
+	run.Status = status
+	run.FinishedAt = &finishedAt
+	run.Title = title
+	run.BrokenLinks = 1
+	run.BrokenList = `[{"url":"https://example.com/gone","code":"404"}]`
+	update := URLRunUpdate{Status: status, LastRunID: &run.ID, LastRunAt: &run.StartedAt}
+	if run.HasResults() {
+		update.Results = true
+		update.ETag = `"v1"`
+	} else {
+		run.Error = "status 500"
+		update.Error = run.Error
+	}
+	if err := urls.FinishRun(ctx, run, update); err != nil {
+		t.Fatalf("FinishRun: %v", err)
+	}
+	return run
+}
+
+func TestGormURLRepositoryFinishRun(t *testing.T) {
+	dbConn := newTestDB(t)
+	urls := NewGormURLRepository(dbConn)
+	ctx := context.Background()
+
+	url, err := urls.Create(ctx, newTestUser(t, dbConn, "alice"), "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := finishTestRun(t, urls, url.ID, db.StatusDone, "Example")
+
+	url, _ = urls.GetByID(ctx, url.ID)
+	if url.Status != db.StatusDone || url.Title != "Example" || url.BrokenLinks != 1 || url.ETag != `"v1"` {
+		t.Errorf("URL after a successful run = %+v", url)
+	}
+	if url.LastRunID == nil || *url.LastRunID != done.ID {
+		t.Errorf("URL last run = %v, want %d", url.LastRunID, done.ID)
+	}
+	if run, _ := urls.GetRun(ctx, url.ID, done.ID); run.Title != "Example" || run.FinishedAt == nil {
+		t.Errorf("stored run = %+v", run)
+	}
+
+	// A failed run stores no results, even partial ones, and keeps the URL's
+	failed := finishTestRun(t, urls, url.ID, db.StatusError, "Partial")
+	url, _ = urls.GetByID(ctx, url.ID)
+	if url.Status != db.StatusError || url.Error == "" || url.Title != "Example" || url.ETag != `"v1"` {
+		t.Errorf("URL after a failed run = %+v", url)
+	}
+	run, err := urls.GetRun(ctx, url.ID, failed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != db.StatusError || run.Title != "" || run.BrokenLinks != 0 {
+		t.Errorf("failed run = %+v, want no results", run)
+	}
+
+	if previous, err := urls.PreviousRun(ctx, url.ID, failed.ID); err != nil || previous.ID != done.ID {
+		t.Errorf("PreviousRun = %v, %v; want run %d", previous, err, done.ID)
+	}
+	if previous, err := urls.PreviousFinishedRun(ctx, url.ID, 0); err != nil || previous.ID != failed.ID {
+		t.Errorf("PreviousFinishedRun = %v, %v; want run %d", previous, err, failed.ID)
+	}
+	if _, err := urls.PreviousFinishedRun(ctx, url.ID, done.ID); err != ErrNotFound {
+		t.Errorf("PreviousFinishedRun before the first run = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGormURLRepositoryUpdateAddress(t *testing.T) {
+	dbConn := newTestDB(t)
+	urls := NewGormURLRepository(dbConn)
+	ctx := context.Background()
+
+	url, _ := urls.Create(ctx, newTestUser(t, dbConn, "alice"), "https://example.com")
+	finishTestRun(t, urls, url.ID, db.StatusDone, "Example")
+
+	nextRun := time.Now().Add(time.Hour).Truncate(time.Second)
+	url.ScheduleInterval = "1h"
+	url.NextRunAt = &nextRun
+	if err := urls.SaveSchedule(ctx, url); err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, err := urls.UpdateAddress(ctx, url.ID, "https://example.org"); err != nil || !changed {
+		t.Fatalf("UpdateAddress = %v, %v", changed, err)
+	}
+	url, _ = urls.GetByID(ctx, url.ID)
+	if url.Address != "https://example.org" || url.Status != db.StatusQueued {
+		t.Errorf("URL = %s %s", url.Address, url.Status)
+	}
+	if url.Title != "" || url.BrokenLinks != 0 || url.BrokenList != "" || url.ETag != "" || url.LastRunID != nil || url.LastRunAt != nil {
+		t.Errorf("URL kept results of the old address: %+v", url)
+	}
+	if url.ScheduleInterval != "1h" || url.NextRunAt == nil || !url.NextRunAt.Equal(nextRun) {
+		t.Errorf("schedule = %q next %v, want it kept", url.ScheduleInterval, url.NextRunAt)
+	}
+	if _, total, _ := urls.ListRuns(ctx, url.ID, 1, 10); total != 0 {
+		t.Errorf("runs after address change = %d, want 0", total)
+	}
+
+	// Running URLs keep their address
+	urls.ClaimQueued(ctx, url.ID)
+	if changed, err := urls.UpdateAddress(ctx, url.ID, "https://example.net"); err != nil || changed {
+		t.Errorf("UpdateAddress of a running URL = %v, %v", changed, err)
+	}
+}
+
+func TestGormURLRepositoryUpdate(t *testing.T) {
+	dbConn := newTestDB(t)
+	urls := NewGormURLRepository(dbConn)
+	ctx := context.Background()
+
+	url, _ := urls.Create(ctx, newTestUser(t, dbConn, "alice"), "https://example.com")
+	finishTestRun(t, urls, url.ID, db.StatusDone, "Example")
+
+	// Schedule changes alone keep the results
+	changed := *url
+	if err := ApplySchedule(&changed, "2h", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if updated, err := urls.Update(ctx, &changed, nil); err != nil || !updated {
+		t.Fatalf("Update = %v, %v", updated, err)
+	}
+	if stored, _ := urls.GetByID(ctx, url.ID); stored.ScheduleInterval != "2h" || stored.NextRunAt == nil || stored.Title != "Example" {
+		t.Errorf("URL after schedule change = %+v", stored)
+	}
+
+	// A running URL keeps both its address and its schedule
+	urls.RequeueIdle(ctx, url.ID)
+	urls.ClaimQueued(ctx, url.ID)
+	address := "https://example.org"
+	changed.ScheduleInterval = "3h"
+	if updated, err := urls.Update(ctx, &changed, &address); err != nil || updated {
+		t.Fatalf("Update of a running URL = %v, %v", updated, err)
+	}
+	if stored, _ := urls.GetByID(ctx, url.ID); stored.Address != url.Address || stored.ScheduleInterval != "2h" {
+		t.Errorf("rejected update changed the URL to %s every %s", stored.Address, stored.ScheduleInterval)
+	}
+}
+
+func TestGormURLRepositoryQueuePriority(t *testing.T) {
+	dbConn := newTestDB(t)
+	urls := NewGormURLRepository(dbConn)
+	ctx := context.Background()
+
+	userID := newTestUser(t, dbConn, "alice")
+	var ids []uint
+	for _, address := range []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"} {
+		url, _ := urls.Create(ctx, userID, address)
+		ids = append(ids, url.ID)
+	}
+	urls.SetQueuePriority(ctx, ids[1], 1)
+	urls.SetQueuePriority(ctx, ids[2], 2)
+
+	tests := []struct {
+		limit int
+		want  []uint
+	}{
+		{10, []uint{ids[2], ids[1], ids[0]}},
+		{2, []uint{ids[2], ids[1]}},
+	}
+	for _, tt := range tests {
+		queued, err := urls.ListQueued(ctx, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []uint
+		for _, url := range queued {
+			got = append(got, url.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ListQueued(%d) = %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+
+	// Only queued URLs take a priority, and queueing a URL anew resets it
+	finishTestRun(t, urls, ids[1], db.StatusDone, "Example")
+	urls.SetQueuePriority(ctx, ids[1], 2)
+	if url, _ := urls.GetByID(ctx, ids[1]); url.QueuePriority != 1 {
+		t.Errorf("priority of a crawled URL = %d, want it unchanged", url.QueuePriority)
+	}
+	urls.RequeueIdle(ctx, ids[1])
+	if url, _ := urls.GetByID(ctx, ids[1]); url.QueuePriority != 0 {
+		t.Errorf("priority after requeueing = %d, want 0", url.QueuePriority)
+	}
+}
+
+func TestGormURLRepositoryDeleteAndRestore(t *testing.T) {
+	dbConn := newTestDB(t)
+	urls := NewGormURLRepository(dbConn)
+	ctx := context.Background()
+	alice := newTestUser(t, dbConn, "alice")
+	bob := newTestUser(t, dbConn, "bob")
+
+	first, _ := urls.Create(ctx, alice, "https://example.com")
+	second, _ := urls.Create(ctx, alice, "https://example.org")
+
+	if deleted, err := urls.Delete(ctx, bob, []uint{first.ID}); err != nil || len(deleted) != 0 {
+		t.Errorf("Delete of another user's URL = %v, %v", deleted, err)
+	}
+	deleted, err := urls.Delete(ctx, alice, []uint{first.ID, second.ID})
+	if err != nil || len(deleted) != 2 {
+		t.Fatalf("Delete = %v, %v", deleted, err)
+	}
+	if _, err := urls.GetForUser(ctx, first.ID, alice); err != ErrNotFound {
+		t.Errorf("GetForUser of a deleted URL = %v, want ErrNotFound", err)
+	}
+	if trash, total, _ := urls.ListTrash(ctx, alice, 1, 10); total != 2 || trash[0].Status != db.StatusCancelled {
+		t.Errorf("trash = %d URLs, first %+v", total, trash)
+	}
+
+	// The first address is added again, so that URL can't come back
+	urls.Create(ctx, alice, "https://example.com")
+	restored, conflicts, err := urls.Restore(ctx, alice, []uint{first.ID, second.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 1 || restored[0] != second.ID || len(conflicts) != 1 || conflicts[0] != first.ID {
+		t.Errorf("Restore = restored %v, conflicts %v", restored, conflicts)
+	}
+	if _, err := urls.GetForUser(ctx, second.ID, alice); err != nil {
+		t.Errorf("restored URL: %v", err)
+	}
+}
+
+func TestGormURLRepositoryListSearch(t *testing.T) {
+	dbConn := newTestDB(t)
+	urls := NewGormURLRepository(dbConn)
+	ctx := context.Background()
+	alice := newTestUser(t, dbConn, "alice")
+
+	for _, address := range []string{"https://shop.example.com/50%_off", "https://shop.example.com/50-off", "https://blog.example.com"} {
+		urls.Create(ctx, alice, address)
+	}
+	urls.Create(ctx, newTestUser(t, dbConn, "bob"), "https://shop.example.com/bob")
+
+	tests := []struct {
+		search string
+		want   int64
+	}{
+		{"", 3},
+		{"SHOP", 2},
+		{"50%_", 1},
+		{"_", 1}, // Unescaped, it would match every address
+	}
+	for _, tt := range tests {
+		_, total, err := urls.List(ctx, URLFilter{UserID: alice, Search: tt.search, Sort: "created_at desc", Page: 1, Size: 10})
+		if err != nil {
+			t.Fatalf("List %q: %v", tt.search, err)
+		}
+		if total != tt.want {
+			t.Errorf("List %q = %d URLs, want %d", tt.search, total, tt.want)
+		}
+	}
+}
+
+func TestGormWebhookRepository(t *testing.T) {
+	dbConn := newTestDB(t)
+	webhooks := NewGormWebhookRepository(dbConn)
+	ctx := context.Background()
+	alice := newTestUser(t, dbConn, "alice")
+
+	all, err := webhooks.Create(ctx, alice, "https://hooks.example.com/all", "secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed, _ := webhooks.Create(ctx, alice, "https://hooks.example.com/failed", "secret", []string{"crawl.failed"})
+
+	active, err := webhooks.ListActiveForEvent(ctx, alice, "crawl.succeeded")
+	if err != nil || len(active) != 1 || active[0].ID != all.ID {
+		t.Errorf("webhooks for crawl.succeeded = %v, %v", active, err)
+	}
+	if _, err := webhooks.GetForUser(ctx, all.ID, alice+1); err != ErrNotFound {
+		t.Errorf("GetForUser of another user = %v, want ErrNotFound", err)
+	}
+
+	// Failures are counted until the webhook is disabled
+	webhooks.RecordFailure(ctx, failed.ID, false)
+	webhooks.RecordFailure(ctx, failed.ID, true)
+	hook, _ := webhooks.GetByID(ctx, failed.ID)
+	if hook.FailureCount != 2 || hook.Active || hook.DisabledAt == nil {
+		t.Errorf("webhook after failures = %+v", hook)
+	}
+	if active, _ := webhooks.ListActiveForEvent(ctx, alice, "crawl.failed"); len(active) != 1 {
+		t.Errorf("disabled webhook still listed for events: %v", active)
+	}
+
+	// Activating it again clears the failures
+	url, events, enable := "https://hooks.example.com/new", []string{"crawl.failed", "crawl.succeeded"}, true
+	if err := webhooks.Update(ctx, failed.ID, WebhookUpdate{URL: &url, Events: &events, Active: &enable}); err != nil {
+		t.Fatal(err)
+	}
+	hook, _ = webhooks.GetByID(ctx, failed.ID)
+	if !hook.Active || hook.FailureCount != 0 || hook.DisabledAt != nil || hook.URL != url || hook.Events != "crawl.failed,crawl.succeeded" {
+		t.Errorf("webhook after update = %+v", hook)
+	}
+
+	webhooks.RecordFailure(ctx, failed.ID, false)
+	webhooks.ResetFailures(ctx, failed.ID)
+	if hook, _ = webhooks.GetByID(ctx, failed.ID); hook.FailureCount != 0 {
+		t.Errorf("failure count after reset = %d", hook.FailureCount)
+	}
+
+	webhooks.CreateDelivery(ctx, failed.ID, "crawl.failed", "{}")
+	if err := webhooks.Delete(ctx, failed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := webhooks.ListDeliveries(ctx, failed.ID, 1, 10); total != 0 {
+		t.Errorf("deliveries of a deleted webhook = %d", total)
+	}
+}
+
+func TestGormWebhookRepositoryClaimDueDeliveries(t *testing.T) {
+	dbConn := newTestDB(t)
+	webhooks := NewGormWebhookRepository(dbConn)
+	ctx := context.Background()
+	alice := newTestUser(t, dbConn, "alice")
+
+	hook, _ := webhooks.Create(ctx, alice, "https://hooks.example.com", "secret", nil)
+	disabled, _ := webhooks.Create(ctx, alice, "https://hooks.example.com/disabled", "secret", nil)
+	first, _ := webhooks.CreateDelivery(ctx, hook.ID, "crawl.failed", `{"n":1}`)
+	webhooks.CreateDelivery(ctx, hook.ID, "crawl.failed", `{"n":2}`)
+	webhooks.CreateDelivery(ctx, disabled.ID, "crawl.failed", `{"n":3}`)
+	webhooks.RecordFailure(ctx, disabled.ID, true)
+
+	now := time.Now().Add(time.Second)
+	claimed, err := webhooks.ClaimDueDeliveries(ctx, now, time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 2 || claimed[0].WebhookID != hook.ID || claimed[1].WebhookID != hook.ID {
+		t.Fatalf("claimed %+v, want the two deliveries of the active webhook", claimed)
+	}
+
+	// Claimed deliveries are leased until they are recorded or it expires
+	if again, _ := webhooks.ClaimDueDeliveries(ctx, now, time.Minute, 10); len(again) != 0 {
+		t.Errorf("claimed %d leased deliveries", len(again))
+	}
+	if expired, _ := webhooks.ClaimDueDeliveries(ctx, now.Add(2*time.Minute), time.Minute, 1); len(expired) != 1 {
+		t.Errorf("claimed %d deliveries with an expired lease, want 1 (the limit)", len(expired))
+	}
+
+	deliveredAt := time.Now()
+	err = webhooks.RecordAttempt(ctx, first.ID, DeliveryAttempt{
+		Status:       db.DeliverySucceeded,
+		Attempts:     1,
+		ResponseCode: 204,
+		DeliveredAt:  &deliveredAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivery, _ := webhooks.GetDelivery(ctx, hook.ID, first.ID)
+	if delivery.Status != db.DeliverySucceeded || delivery.Attempts != 1 || delivery.ResponseCode != 204 ||
+		delivery.DeliveredAt == nil || delivery.NextAttemptAt != nil {
+		t.Errorf("delivery after success = %+v", delivery)
+	}
+	if later, _ := webhooks.ClaimDueDeliveries(ctx, now.Add(time.Hour), time.Minute, 10); len(later) != 1 || later[0].ID == first.ID {
+		t.Errorf("claimed %+v after delivery, want only the pending one", later)
+	}
+}
+
+func TestGormAlertRepository(t *testing.T) {
+	dbConn := newTestDB(t)
+	alerts := NewGormAlertRepository(dbConn)
+	urls := NewGormURLRepository(dbConn)
+	ctx := context.Background()
+	alice := newTestUser(t, dbConn, "alice")
+
+	url, _ := urls.Create(ctx, alice, "https://example.com")
+	other, _ := urls.Create(ctx, alice, "https://example.org")
+	global := db.AlertRule{UserID: alice, Email: "alice@example.com", OnError: true}
+	specific := db.AlertRule{UserID: alice, URLID: &url.ID, Email: "alice@example.com", OnBrokenLinks: true, Digest: true}
+	unrelated := db.AlertRule{UserID: alice, URLID: &other.ID, Email: "alice@example.com", OnError: true}
+	for _, rule := range []*db.AlertRule{&global, &specific, &unrelated} {
+		if err := alerts.CreateRule(ctx, rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules, err := alerts.ListRulesForURL(ctx, alice, url.ID)
+	if err != nil || len(rules) != 2 || rules[0].ID != global.ID || rules[1].ID != specific.ID {
+		t.Errorf("rules for URL = %+v, %v", rules, err)
+	}
+
+	// Updates save false and nil values too
+	specific.URLID = nil
+	specific.Email = "ops@example.com"
+	specific.OnBrokenLinks = false
+	specific.OnError = true
+	specific.Digest = false
+	if err := alerts.UpdateRule(ctx, &specific); err != nil {
+		t.Fatal(err)
+	}
+	rule, _ := alerts.GetRuleForUser(ctx, specific.ID, alice)
+	if rule.URLID != nil || rule.Email != "ops@example.com" || rule.OnBrokenLinks || !rule.OnError || rule.Digest {
+		t.Errorf("rule after update = %+v", rule)
+	}
+
+	sentAt := time.Now()
+	alerts.MarkRuleSent(ctx, global.ID, sentAt)
+	if rule, _ := alerts.GetRule(ctx, global.ID); rule.LastSentAt == nil || rule.Email != global.Email {
+		t.Errorf("rule after sending = %+v", rule)
+	}
+
+	alerts.CreatePending(ctx, &db.PendingAlert{RuleID: global.ID, URLID: url.ID, Kind: db.AlertKindError})
+	alerts.CreatePending(ctx, &db.PendingAlert{RuleID: unrelated.ID, URLID: other.ID, Kind: db.AlertKindError})
+	pending, _ := alerts.ListPending(ctx)
+	if len(pending) != 2 {
+		t.Fatalf("pending alerts = %d, want 2", len(pending))
+	}
+	alerts.DeletePending(ctx, []uint{pending[0].ID})
+	if err := alerts.DeleteRule(ctx, unrelated.ID); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := alerts.ListPending(ctx); len(pending) != 0 {
+		t.Errorf("pending alerts left = %+v", pending)
+	}
+	if _, err := alerts.GetRule(ctx, unrelated.ID); err != ErrNotFound {
+		t.Errorf("GetRule of a deleted rule = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGormUserRepositorySyncAdmins(t *testing.T) {
+	dbConn := newTestDB(t)
+	users := NewGormUserRepository(dbConn)
+	ctx := context.Background()
+	for _, username := range []string{"alice", "bob", "carol"} {
+		newTestUser(t, dbConn, username)
+	}
+	users.PromoteAdmins(ctx, []string{"alice", "bob"})
+
+	// Bob is no longer listed and dave isn't registered yet
+	granted, revoked, err := users.SyncAdmins(ctx, []string{"alice", "carol", "dave"})
+	if err != nil || granted != 1 || revoked != 1 {
+		t.Fatalf("SyncAdmins = %d granted, %d revoked, %v; want 1 and 1", granted, revoked, err)
+	}
+	list, _ := users.List(ctx)
+	for _, user := range list {
+		if want := user.Username != "bob"; user.IsAdmin != want {
+			t.Errorf("%s admin = %v, want %v", user.Username, user.IsAdmin, want)
+		}
+	}
+
+	if granted, revoked, _ := users.SyncAdmins(ctx, []string{"alice", "carol"}); granted != 0 || revoked != 0 {
+		t.Errorf("second SyncAdmins = %d granted, %d revoked; want no changes", granted, revoked)
+	}
+}
+
+func TestGormUserRepositoryTokenVersion(t *testing.T) {
+	dbConn := newTestDB(t)
+	users := NewGormUserRepository(dbConn)
+	ctx := context.Background()
+	newTestUser(t, dbConn, "alice")
+
+	steps := []struct {
+		name   string
+		change func() error
+		want   uint
+	}{
+		{"disable", func() error { return users.SetDisabled(ctx, "alice", true) }, 1},
+		{"enable", func() error { return users.SetDisabled(ctx, "alice", false) }, 1},
+		{"reset password", func() error { return users.UpdatePassword(ctx, "alice", "new-hash") }, 2},
+	}
+	for _, step := range steps {
+		if err := step.change(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if user, _ := users.GetByUsername(ctx, "alice"); user.TokenVersion != step.want {
+			t.Errorf("token version after %s = %d, want %d", step.name, user.TokenVersion, step.want)
+		}
+	}
+	if err := users.SetDisabled(ctx, "bob", true); err != ErrNotFound {
+		t.Errorf("disabling an unknown user = %v, want ErrNotFound", err)
+	}
+}
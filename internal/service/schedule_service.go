@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sykell/url-crawler/internal/db"
+	"gorm.io/gorm"
+)
+
+// MinScheduleInterval is the shortest allowed time between scheduled crawls
+const MinScheduleInterval = time.Minute
+
+// ValidateSchedule checks a schedule definition; exactly one of interval and cronExpr must be set
+func ValidateSchedule(interval, cronExpr, timezone string) error {
+	if (interval == "") == (cronExpr == "") {
+		return fmt.Errorf("exactly one of interval or cron must be set")
+	}
+
+	if interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("invalid interval: %w", err)
+		}
+		if d < MinScheduleInterval {
+			return fmt.Errorf("interval must be at least %s", MinScheduleInterval)
+		}
+	}
+
+	if cronExpr != "" {
+		if _, err := cron.ParseStandard(cronExpr); err != nil {
+			return fmt.Errorf("invalid cron expression: %w", err)
+		}
+	}
+
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// NextRunTime computes when a scheduled URL should next be crawled after the given time
+func NextRunTime(url *db.URL, after time.Time) (time.Time, error) {
+	if url.ScheduleInterval != "" {
+		d, err := time.ParseDuration(url.ScheduleInterval)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid interval: %w", err)
+		}
+		return after.Add(d), nil
+	}
+
+	if url.ScheduleCron != "" {
+		schedule, err := cron.ParseStandard(url.ScheduleCron)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid cron expression: %w", err)
+		}
+		loc := time.UTC
+		if url.ScheduleTimezone != "" {
+			if loc, err = time.LoadLocation(url.ScheduleTimezone); err != nil {
+				return time.Time{}, fmt.Errorf("invalid timezone: %w", err)
+			}
+		}
+		return schedule.Next(after.In(loc)).UTC(), nil
+	}
+
+	return time.Time{}, fmt.Errorf("URL %d has no schedule", url.ID)
+}
+
+// SetURLSchedule stores a validated schedule on a URL and computes its next run
+func SetURLSchedule(ctx context.Context, urls URLRepository, url *db.URL, interval, cronExpr, timezone string) error {
+	if err := ApplySchedule(url, interval, cronExpr, timezone); err != nil {
+		return err
+	}
+	return urls.SaveSchedule(ctx, url)
+}
+
+// ApplySchedule validates a schedule and sets it on url, resuming it and
+// computing its next run, without saving it
+func ApplySchedule(url *db.URL, interval, cronExpr, timezone string) error {
+	if err := ValidateSchedule(interval, cronExpr, timezone); err != nil {
+		return err
+	}
+
+	url.ScheduleInterval = interval
+	url.ScheduleCron = cronExpr
+	url.ScheduleTimezone = timezone
+	url.SchedulePaused = false
+
+	next, err := NextRunTime(url, time.Now())
+	if err != nil {
+		return err
+	}
+	url.NextRunAt = &next
+	return nil
+}
+
+// ClearURLSchedule removes the recurring schedule of a URL
+func ClearURLSchedule(ctx context.Context, urls URLRepository, url *db.URL) error {
+	url.ScheduleInterval = ""
+	url.ScheduleCron = ""
+	url.ScheduleTimezone = ""
+	url.SchedulePaused = false
+	url.NextRunAt = nil
+
+	return urls.SaveSchedule(ctx, url)
+}
+
+// SetSchedulePaused pauses or resumes a URL's schedule. Resuming recomputes the
+// next run from now so missed runs are not replayed.
+func SetSchedulePaused(ctx context.Context, urls URLRepository, url *db.URL, paused bool) error {
+	if err := ApplySchedulePaused(url, paused); err != nil {
+		return err
+	}
+	return urls.SaveSchedule(ctx, url)
+}
+
+// ApplySchedulePaused is SetSchedulePaused without saving the change
+func ApplySchedulePaused(url *db.URL, paused bool) error {
+	if !url.HasSchedule() {
+		return fmt.Errorf("URL %d has no schedule", url.ID)
+	}
+
+	if !paused {
+		next, err := NextRunTime(url, time.Now())
+		if err != nil {
+			return err
+		}
+		url.NextRunAt = &next
+	}
+	url.SchedulePaused = paused
+	return nil
+}
+
+// SaveURLSchedule persists the schedule fields of url
+func SaveURLSchedule(dbConn *gorm.DB, url *db.URL) error {
+	return dbConn.Model(&db.URL{}).Where("id = ?", url.ID).Updates(map[string]interface{}{
+		"schedule_interval": url.ScheduleInterval,
+		"schedule_cron":     url.ScheduleCron,
+		"schedule_timezone": url.ScheduleTimezone,
+		"schedule_paused":   url.SchedulePaused,
+		"next_run_at":       url.NextRunAt,
+	}).Error
+}
+
+// ListDueScheduledURLs returns active scheduled URLs whose next run is due and
+// that are not already waiting for or undergoing a crawl
+func ListDueScheduledURLs(dbConn *gorm.DB, now time.Time, limit int) ([]db.URL, error) {
+	var urls []db.URL
+	err := dbConn.Where("(schedule_interval <> '' OR schedule_cron <> '') AND schedule_paused = ? AND next_run_at <= ?", false, now).
+		Where("status NOT IN ?", []db.URLStatus{db.StatusQueued, db.StatusRunning}).
+		Order("next_run_at asc").Limit(limit).Find(&urls).Error
+	return urls, err
+}
+
+// ClaimScheduledRun advances a due URL's next run and marks it queued. The
+// update is conditional on the previous next_run_at so only one scheduler
+// instance claims each run; it reports whether this caller won the claim.
+func ClaimScheduledRun(dbConn *gorm.DB, url *db.URL, next time.Time) (bool, error) {
+	result := dbConn.Model(&db.URL{}).
+		Where("id = ? AND next_run_at = ?", url.ID, url.NextRunAt).
+		Updates(map[string]interface{}{
+			"next_run_at":    next,
+			"status":         db.StatusQueued,
+			"error":          "",
+			"queue_priority": 0,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
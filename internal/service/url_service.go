@@ -16,6 +16,142 @@ func UpdateURLStatus(dbConn *gorm.DB, id uint, status db.URLStatus, errorMsg str
 	return dbConn.Model(&db.URL{}).Where("id = ?", id).Updates(updates).Error
 }
 
+// ClaimQueuedURL moves a queued URL to running. It reports false when the URL
+// is no longer queued, e.g. because it was cancelled while waiting.
+func ClaimQueuedURL(dbConn *gorm.DB, id uint) (bool, error) {
+	result := dbConn.Model(&db.URL{}).Where("id = ? AND status = ?", id, db.StatusQueued).Updates(map[string]interface{}{
+		"status": db.StatusRunning,
+		"error":  "",
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// CancelQueuedURL marks a queued URL as cancelled so workers skip it. It
+// reports false when the URL wasn't queued.
+func CancelQueuedURL(dbConn *gorm.DB, id uint) (bool, error) {
+	result := dbConn.Model(&db.URL{}).Where("id = ? AND status = ?", id, db.StatusQueued).Updates(map[string]interface{}{
+		"status": db.StatusCancelled,
+		"error":  "",
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RequeueIdleURL moves a URL that is neither queued nor running back to
+// queued. It reports false when a crawl is already pending.
+func RequeueIdleURL(dbConn *gorm.DB, id uint) (bool, error) {
+	result := dbConn.Model(&db.URL{}).Where("id = ? AND status NOT IN ?", id, []db.URLStatus{db.StatusQueued, db.StatusRunning}).
+		Updates(map[string]interface{}{
+			"status":         db.StatusQueued,
+			"error":          "",
+			"queue_priority": 0,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateURLAddress points a URL at a new address and queues it. Results,
+// validators, change detection and run history of the old address are
+// dropped so the next crawl starts from scratch. It reports false while the
+// URL is running.
+func UpdateURLAddress(dbConn *gorm.DB, id uint, address string) (bool, error) {
+	if address == "" {
+		return false, fmt.Errorf("address cannot be empty")
+	}
+
+	var updated bool
+	err := dbConn.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&db.URL{}).Where("id = ? AND status <> ?", id, db.StatusRunning).Updates(map[string]interface{}{
+			"address":            address,
+			"status":             db.StatusQueued,
+			"error":              "",
+			"queue_priority":     0,
+			"title":              "",
+			"html_version":       "",
+			"heading_counts":     "",
+			"internal_links":     0,
+			"external_links":     0,
+			"broken_links":       0,
+			"broken_list":        "",
+			"has_login_form":     false,
+			"last_run_id":        nil,
+			"last_run_at":        nil,
+			"etag":               "",
+			"last_modified":      "",
+			"content_changed":    false,
+			"content_changed_at": nil,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		updated = true
+		return DeleteCrawlRunsForURLs(tx, []uint{id})
+	})
+	return updated, err
+}
+
+// UpdateURL saves the schedule fields of url and, when address is set,
+// changes its address like UpdateURLAddress, in one transaction. Nothing is
+// saved when the address can't change because the URL is running.
+func UpdateURL(dbConn *gorm.DB, url *db.URL, address *string) (bool, error) {
+	updated := true
+	err := dbConn.Transaction(func(tx *gorm.DB) error {
+		if address != nil {
+			var err error
+			if updated, err = UpdateURLAddress(tx, url.ID, *address); err != nil || !updated {
+				return err
+			}
+		}
+		return SaveURLSchedule(tx, url)
+	})
+	return updated, err
+}
+
+// RequeueURLsByStatus moves all URLs with the given status back to queued and
+// returns how many were reset, e.g. those left running by an unclean shutdown
+func RequeueURLsByStatus(dbConn *gorm.DB, status db.URLStatus) (int64, error) {
+	result := dbConn.Model(&db.URL{}).Where("status = ?", status).Updates(map[string]interface{}{
+		"status": db.StatusQueued,
+		"error":  "",
+	})
+	return result.RowsAffected, result.Error
+}
+
+// ListQueuedURLs returns the IDs, owners and queue priorities of up to limit
+// queued URLs, highest priority first and oldest first within a priority
+func ListQueuedURLs(dbConn *gorm.DB, limit int) ([]db.URL, error) {
+	var urls []db.URL
+	err := dbConn.Select("id", "user_id", "queue_priority").Where("status = ?", db.StatusQueued).
+		Order("queue_priority desc, id").Limit(limit).Find(&urls).Error
+	return urls, err
+}
+
+// SetURLQueuePriority records the crawl queue priority of a queued URL
+func SetURLQueuePriority(dbConn *gorm.DB, id uint, priority int) error {
+	return dbConn.Model(&db.URL{}).Where("id = ? AND status = ?", id, db.StatusQueued).
+		Update("queue_priority", priority).Error
+}
+
+// RequeueURLs resets the given URLs owned by a user to queued status and
+// returns the IDs that were requeued
+func RequeueURLs(dbConn *gorm.DB, userID uint, ids []uint) ([]uint, error) {
+	var ownedIDs []uint
+	if err := dbConn.Model(&db.URL{}).Where("id IN ? AND user_id = ?", ids, userID).Pluck("id", &ownedIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(ownedIDs) == 0 {
+		return ownedIDs, nil
+	}
+
+	err := dbConn.Model(&db.URL{}).Where("id IN ?", ownedIDs).Updates(map[string]interface{}{
+		"status":         db.StatusQueued,
+		"error":          "",
+		"queue_priority": 0,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return ownedIDs, nil
+}
+
 // GetURLByID retrieves a URL by ID
 func GetURLByID(dbConn *gorm.DB, id uint) (*db.URL, error) {
 	var url db.URL
@@ -94,4 +230,22 @@ func GetURLByAddressLegacy(dbConn *gorm.DB, address string) (*db.URL, error) {
 		return nil, err
 	}
 	return &url, nil
-} 
\ No newline at end of file
+}
+
+// ListDeletedURLs retrieves a page of a user's soft-deleted URLs, most
+// recently deleted first, and their total count
+func ListDeletedURLs(dbConn *gorm.DB, userID uint, page, pageSize int) ([]db.URL, int64, error) {
+	query := dbConn.Unscoped().Model(&db.URL{}).Where("user_id = ? AND deleted_at IS NOT NULL", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var urls []db.URL
+	offset := (page - 1) * pageSize
+	if err := query.Order("deleted_at desc, id desc").Limit(pageSize).Offset(offset).Find(&urls).Error; err != nil {
+		return nil, 0, err
+	}
+	return urls, total, nil
+}
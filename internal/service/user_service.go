@@ -2,11 +2,25 @@ package service
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/sykell/url-crawler/internal/db"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// MinPasswordLength is the shortest password accepted for an account
+const MinPasswordLength = 6
+
+// HashPassword hashes a password for storage
+func HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
 // CreateUser creates a new user with proper validation
 func CreateUser(dbConn *gorm.DB, username, password string) error {
 	if username == "" || password == "" {
@@ -21,6 +35,36 @@ func CreateUser(dbConn *gorm.DB, username, password string) error {
 	return dbConn.Create(&user).Error
 }
 
+// PromoteAdmins grants admin privileges to the given usernames and returns
+// how many users were updated
+func PromoteAdmins(dbConn *gorm.DB, usernames []string) (int64, error) {
+	if len(usernames) == 0 {
+		return 0, nil
+	}
+	result := dbConn.Model(&db.User{}).Where("username IN ? AND is_admin = ?", usernames, false).Update("is_admin", true)
+	return result.RowsAffected, result.Error
+}
+
+// SyncAdmins makes the given usernames the only admins, granting and revoking
+// privileges as needed, and returns how many users were granted and revoked
+func SyncAdmins(dbConn *gorm.DB, usernames []string) (granted, revoked int64, err error) {
+	err = dbConn.Transaction(func(tx *gorm.DB) error {
+		revoke := tx.Model(&db.User{}).Where("is_admin = ?", true)
+		if len(usernames) > 0 {
+			revoke = revoke.Where("username NOT IN ?", usernames)
+		}
+		result := revoke.Update("is_admin", false)
+		if result.Error != nil {
+			return result.Error
+		}
+		revoked = result.RowsAffected
+
+		granted, err = PromoteAdmins(tx, usernames)
+		return err
+	})
+	return granted, revoked, err
+}
+
 // GetUserByUsername retrieves a user by username
 func GetUserByUsername(dbConn *gorm.DB, username string) (*db.User, error) {
 	var user db.User
@@ -29,4 +73,55 @@ func GetUserByUsername(dbConn *gorm.DB, username string) (*db.User, error) {
 		return nil, err
 	}
 	return &user, nil
-} 
\ No newline at end of file
+}
+
+// GetUserByID retrieves a user by ID
+func GetUserByID(dbConn *gorm.DB, id uint) (*db.User, error) {
+	var user db.User
+	err := dbConn.First(&user, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers returns all users ordered by ID
+func ListUsers(dbConn *gorm.DB) ([]db.User, error) {
+	var users []db.User
+	err := dbConn.Order("id").Find(&users).Error
+	return users, err
+}
+
+// SetUserDisabled disables or re-enables a user's login. Disabling revokes
+// the user's tokens.
+func SetUserDisabled(dbConn *gorm.DB, username string, disabled bool) error {
+	updates := map[string]interface{}{"disabled_at": nil}
+	if disabled {
+		updates["disabled_at"] = time.Now()
+		updates["token_version"] = gorm.Expr("token_version + 1")
+	}
+	result := dbConn.Model(&db.User{}).Where("username = ?", username).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateUserPassword replaces a user's password hash and revokes their tokens
+func UpdateUserPassword(dbConn *gorm.DB, username, password string) error {
+	if password == "" {
+		return fmt.Errorf("password cannot be empty")
+	}
+	result := dbConn.Model(&db.User{}).Where("username = ?", username).
+		Updates(map[string]interface{}{"password": password, "token_version": gorm.Expr("token_version + 1")})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
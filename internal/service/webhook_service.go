@@ -0,0 +1,249 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"gorm.io/gorm"
+)
+
+// CreateWebhook registers a webhook endpoint for a user
+func CreateWebhook(dbConn *gorm.DB, userID uint, url, secret string, eventTypes []string) (*db.Webhook, error) {
+	if url == "" || secret == "" {
+		return nil, fmt.Errorf("url and secret cannot be empty")
+	}
+	if userID == 0 {
+		return nil, fmt.Errorf("user ID cannot be zero")
+	}
+
+	webhook := db.Webhook{
+		UserID: userID,
+		URL:    url,
+		Secret: secret,
+		Events: strings.Join(eventTypes, ","),
+		Active: true,
+	}
+
+	if err := dbConn.Create(&webhook).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// ListWebhooks returns all webhooks of a user
+func ListWebhooks(dbConn *gorm.DB, userID uint) ([]db.Webhook, error) {
+	var webhooks []db.Webhook
+	err := dbConn.Where("user_id = ?", userID).Order("id asc").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// GetWebhookByIDAndUser retrieves a webhook owned by a user
+func GetWebhookByIDAndUser(dbConn *gorm.DB, id, userID uint) (*db.Webhook, error) {
+	var webhook db.Webhook
+	err := dbConn.Where("id = ? AND user_id = ?", id, userID).First(&webhook).Error
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// GetWebhookByID retrieves a webhook by ID
+func GetWebhookByID(dbConn *gorm.DB, id uint) (*db.Webhook, error) {
+	var webhook db.Webhook
+	if err := dbConn.First(&webhook, id).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// WebhookUpdate changes a webhook's settings. Nil fields are left as they
+// are.
+type WebhookUpdate struct {
+	URL    *string
+	Events *[]string
+	Active *bool
+}
+
+// UpdateWebhook applies an update to a webhook. Activating a webhook clears
+// its failure count and disabled time.
+func UpdateWebhook(dbConn *gorm.DB, id uint, update WebhookUpdate) error {
+	updates := map[string]interface{}{}
+	if update.URL != nil {
+		updates["url"] = *update.URL
+	}
+	if update.Events != nil {
+		updates["events"] = strings.Join(*update.Events, ",")
+	}
+	if update.Active != nil {
+		updates["active"] = *update.Active
+		if *update.Active {
+			updates["failure_count"] = 0
+			updates["disabled_at"] = nil
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return dbConn.Model(&db.Webhook{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// ResetWebhookFailures clears a webhook's consecutive failure count
+func ResetWebhookFailures(dbConn *gorm.DB, id uint) error {
+	return dbConn.Model(&db.Webhook{}).Where("id = ?", id).Update("failure_count", 0).Error
+}
+
+// RecordWebhookFailure counts a failed attempt of a webhook and, when
+// disable is set, deactivates it
+func RecordWebhookFailure(dbConn *gorm.DB, id uint, disable bool) error {
+	updates := map[string]interface{}{"failure_count": gorm.Expr("failure_count + 1")}
+	if disable {
+		updates["active"] = false
+		updates["disabled_at"] = time.Now()
+	}
+	return dbConn.Model(&db.Webhook{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// DeleteWebhook removes a webhook together with its delivery log
+func DeleteWebhook(dbConn *gorm.DB, id uint) error {
+	return dbConn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", id).Delete(&db.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&db.Webhook{}, id).Error
+	})
+}
+
+// ListActiveWebhooksForEvent returns the user's active webhooks subscribed to an event type
+func ListActiveWebhooksForEvent(dbConn *gorm.DB, userID uint, eventType string) ([]db.Webhook, error) {
+	var webhooks []db.Webhook
+	if err := dbConn.Where("user_id = ? AND active = ?", userID, true).Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+
+	matching := webhooks[:0]
+	for _, webhook := range webhooks {
+		if WebhookWantsEvent(&webhook, eventType) {
+			matching = append(matching, webhook)
+		}
+	}
+	return matching, nil
+}
+
+// WebhookWantsEvent reports whether a webhook's event filter includes an event type
+func WebhookWantsEvent(webhook *db.Webhook, eventType string) bool {
+	if webhook.Events == "" {
+		return true
+	}
+	for _, t := range strings.Split(webhook.Events, ",") {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhookDelivery queues a payload for delivery to a webhook
+func CreateWebhookDelivery(dbConn *gorm.DB, webhookID uint, eventType, payload string) (*db.WebhookDelivery, error) {
+	now := time.Now()
+	delivery := db.WebhookDelivery{
+		WebhookID:     webhookID,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        db.DeliveryPending,
+		NextAttemptAt: &now,
+	}
+
+	if err := dbConn.Create(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// GetWebhookDelivery retrieves a delivery of a webhook
+func GetWebhookDelivery(dbConn *gorm.DB, webhookID, deliveryID uint) (*db.WebhookDelivery, error) {
+	var delivery db.WebhookDelivery
+	err := dbConn.Where("id = ? AND webhook_id = ?", deliveryID, webhookID).First(&delivery).Error
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ListWebhookDeliveries returns a page of a webhook's deliveries, newest first, with the total count
+func ListWebhookDeliveries(dbConn *gorm.DB, webhookID uint, page, pageSize int) ([]db.WebhookDelivery, int64, error) {
+	query := dbConn.Model(&db.WebhookDelivery{}).Where("webhook_id = ?", webhookID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var deliveries []db.WebhookDelivery
+	offset := (page - 1) * pageSize
+	if err := query.Order("id desc").Limit(pageSize).Offset(offset).Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+// ClaimDueWebhookDeliveries claims up to limit pending deliveries of active
+// webhooks whose next attempt is due by moving that attempt lease into the
+// future, so other instances skip them while they are sent. Recording the
+// outcome replaces the lease; a delivery whose sender died is retried once
+// it expires.
+func ClaimDueWebhookDeliveries(dbConn *gorm.DB, now time.Time, lease time.Duration, limit int) ([]db.WebhookDelivery, error) {
+	var deliveries []db.WebhookDelivery
+	err := dbConn.Transaction(func(tx *gorm.DB) error {
+		err := db.ForUpdateSkipLocked(tx).Model(&db.WebhookDelivery{}).
+			Where("status = ? AND next_attempt_at <= ?", db.DeliveryPending, now).
+			Where("webhook_id IN (?)", tx.Model(&db.Webhook{}).Select("id").Where("active = ?", true)).
+			Order("next_attempt_at asc").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+		}
+		return tx.Model(&db.WebhookDelivery{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// DeliveryAttempt is the outcome of sending a delivery
+type DeliveryAttempt struct {
+	Status        db.WebhookDeliveryStatus
+	Attempts      int
+	ResponseCode  int
+	Error         string
+	DeliveredAt   *time.Time
+	NextAttemptAt *time.Time // Nil when no further attempt is made
+}
+
+// RecordWebhookAttempt stores the outcome of a delivery attempt, replacing
+// the delivery's claim
+func RecordWebhookAttempt(dbConn *gorm.DB, id uint, attempt DeliveryAttempt) error {
+	return dbConn.Model(&db.WebhookDelivery{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          attempt.Status,
+		"attempts":        attempt.Attempts,
+		"response_code":   attempt.ResponseCode,
+		"error":           attempt.Error,
+		"delivered_at":    attempt.DeliveredAt,
+		"next_attempt_at": attempt.NextAttemptAt,
+	}).Error
+}
+
+// ReleaseWebhookDelivery makes a claimed delivery due at now again, keeping
+// its attempts, e.g. when the attempt was aborted by a shutdown
+func ReleaseWebhookDelivery(dbConn *gorm.DB, id uint, now time.Time) error {
+	return dbConn.Model(&db.WebhookDelivery{}).Where("id = ? AND status = ?", id, db.DeliveryPending).
+		Update("next_attempt_at", now).Error
+}
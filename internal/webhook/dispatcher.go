@@ -0,0 +1,346 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/events"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+// SupportedEvents are the event types webhooks can subscribe to
+var SupportedEvents = []string{events.TypeCrawlSucceeded, events.TypeCrawlFailed}
+
+// Config holds webhook delivery configuration
+type Config struct {
+	// Timeout bounds a single delivery attempt
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts before a delivery is failed
+	MaxAttempts int
+	// DisableAfter consecutive failed attempts deactivate a webhook
+	DisableAfter int
+	// PollInterval is how often due retries are picked up
+	PollInterval time.Duration
+	// Concurrency is the number of deliveries sent in parallel
+	Concurrency int
+}
+
+// DefaultConfig returns default webhook configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Timeout:      10 * time.Second,
+		MaxAttempts:  6,
+		DisableAfter: 20,
+		PollInterval: 5 * time.Second,
+		Concurrency:  4,
+	}
+}
+
+// NewConfig creates a webhook configuration from environment variables
+func NewConfig() *Config {
+	config := DefaultConfig()
+
+	if v, err := time.ParseDuration(os.Getenv("WEBHOOK_TIMEOUT")); err == nil && v > 0 {
+		config.Timeout = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); err == nil && v > 0 {
+		config.MaxAttempts = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("WEBHOOK_DISABLE_AFTER")); err == nil && v > 0 {
+		config.DisableAfter = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("WEBHOOK_POLL_INTERVAL")); err == nil && v > 0 {
+		config.PollInterval = v
+	}
+
+	return config
+}
+
+// Payload is the JSON body POSTed to webhook endpoints
+type Payload struct {
+	Event      string       `json:"event"`
+	OccurredAt time.Time    `json:"occurred_at"`
+	Data       events.Event `json:"data"`
+}
+
+// Dispatcher turns crawl events into webhook deliveries and sends them,
+// retrying failed attempts with exponential backoff
+type Dispatcher struct {
+	webhooks  service.WebhookRepository
+	client    *http.Client
+	config    *Config
+	wake      chan struct{}
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	isRunning bool
+}
+
+// NewDispatcher creates a webhook dispatcher
+func NewDispatcher(webhooks service.WebhookRepository, config *Config) *Dispatcher {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Dispatcher{
+		webhooks: webhooks,
+		client:   &http.Client{Timeout: config.Timeout},
+		config:   config,
+		wake:     make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start starts the delivery loop
+func (d *Dispatcher) Start() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.isRunning {
+		return fmt.Errorf("webhook dispatcher is already running")
+	}
+
+	d.isRunning = true
+	d.wg.Add(1)
+	go d.run()
+
+	slog.Info("Webhook dispatcher started")
+	return nil
+}
+
+// Stop stops the delivery loop; pending deliveries resume on the next start
+func (d *Dispatcher) Stop() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.isRunning {
+		return nil
+	}
+
+	d.isRunning = false
+	d.cancel()
+	d.wg.Wait()
+
+	slog.Info("Webhook dispatcher stopped")
+	return nil
+}
+
+// HandleEvent is a bus handler creating a delivery for every matching webhook
+func (d *Dispatcher) HandleEvent(event events.Event) {
+	webhooks, err := d.webhooks.ListActiveForEvent(context.Background(), event.Owner(), event.EventType())
+	if err != nil {
+		slog.Error("Failed to load webhooks", "user_id", event.Owner(), "error", err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(Payload{
+		Event:      event.EventType(),
+		OccurredAt: time.Now().UTC(),
+		Data:       event,
+	})
+	if err != nil {
+		slog.Error("Failed to marshal webhook payload", "event", event.EventType(), "error", err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if _, err := d.webhooks.CreateDelivery(context.Background(), webhook.ID, event.EventType(), string(body)); err != nil {
+			slog.Error("Failed to create webhook delivery", "webhook_id", webhook.ID, "error", err)
+		}
+	}
+	d.Wake()
+}
+
+// Wake makes the delivery loop check for due deliveries immediately
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Sign computes the signature sent in X-Webhook-Signature: the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// run sends due deliveries whenever woken or on every poll interval
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue()
+
+		select {
+		case <-ticker.C:
+		case <-d.wake:
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// deliverDue sends all due deliveries with bounded concurrency. Each batch
+// is claimed before sending so other instances don't send it as well.
+func (d *Dispatcher) deliverDue() {
+	// A claim must outlast the attempts of its batch, which run in parallel
+	lease := d.config.Timeout + time.Minute
+
+	for d.ctx.Err() == nil {
+		deliveries, err := d.webhooks.ClaimDueDeliveries(context.Background(), time.Now(), lease, d.config.Concurrency)
+		if err != nil {
+			slog.Error("Failed to claim due webhook deliveries", "error", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for i := range deliveries {
+			wg.Add(1)
+			go func(delivery *db.WebhookDelivery) {
+				defer wg.Done()
+				d.attempt(delivery)
+			}(&deliveries[i])
+		}
+		wg.Wait()
+
+		if len(deliveries) < d.config.Concurrency {
+			return
+		}
+	}
+}
+
+// attempt sends a delivery once and records the outcome
+func (d *Dispatcher) attempt(delivery *db.WebhookDelivery) {
+	// Outcomes are recorded even while stopping, so sent deliveries aren't sent twice
+	ctx := context.Background()
+
+	webhook, err := d.webhooks.GetByID(ctx, delivery.WebhookID)
+	if err != nil {
+		slog.Error("Failed to load webhook for delivery", "webhook_id", delivery.WebhookID, "delivery_id", delivery.ID, "error", err)
+		return
+	}
+
+	code, sendErr := d.send(webhook, delivery)
+	if sendErr != nil && d.ctx.Err() != nil {
+		// Stopping aborted the request, which says nothing about the
+		// endpoint; the next start sends the delivery again
+		slog.Info("Webhook delivery interrupted by shutdown", "delivery_id", delivery.ID)
+		if err := d.webhooks.ReleaseDelivery(ctx, delivery.ID); err != nil {
+			slog.Error("Failed to release webhook delivery", "delivery_id", delivery.ID, "error", err)
+		}
+		return
+	}
+	now := time.Now()
+	attempt := service.DeliveryAttempt{
+		Status:       db.DeliveryPending,
+		Attempts:     delivery.Attempts + 1,
+		ResponseCode: code,
+	}
+
+	if sendErr == nil {
+		attempt.Status = db.DeliverySucceeded
+		attempt.DeliveredAt = &now
+		if err := d.webhooks.RecordAttempt(ctx, delivery.ID, attempt); err != nil {
+			slog.Error("Failed to record webhook delivery", "delivery_id", delivery.ID, "error", err)
+		}
+		if webhook.FailureCount > 0 {
+			if err := d.webhooks.ResetFailures(ctx, webhook.ID); err != nil {
+				slog.Error("Failed to reset webhook failure count", "webhook_id", webhook.ID, "error", err)
+			}
+		}
+		return
+	}
+
+	attempt.Error = sendErr.Error()
+	if attempt.Attempts >= d.config.MaxAttempts {
+		attempt.Status = db.DeliveryFailed
+		slog.Warn("Webhook delivery failed permanently", "delivery_id", delivery.ID, "url", webhook.URL, "attempts", attempt.Attempts, "error", sendErr)
+	} else {
+		next := now.Add(backoff(attempt.Attempts))
+		attempt.NextAttemptAt = &next
+		slog.Warn("Webhook delivery failed", "delivery_id", delivery.ID, "url", webhook.URL, "attempt", attempt.Attempts, "error", sendErr)
+	}
+	if err := d.webhooks.RecordAttempt(ctx, delivery.ID, attempt); err != nil {
+		slog.Error("Failed to record webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+
+	// Consecutive failures eventually disable the webhook
+	disable := webhook.FailureCount+1 >= d.config.DisableAfter
+	if disable {
+		slog.Warn("Disabling webhook after consecutive failures", "webhook_id", webhook.ID, "failures", webhook.FailureCount+1)
+	}
+	if err := d.webhooks.RecordFailure(ctx, webhook.ID, disable); err != nil {
+		slog.Error("Failed to update webhook failure count", "webhook_id", webhook.ID, "error", err)
+	}
+}
+
+// send POSTs a signed payload and returns the response status code
+func (d *Dispatcher) send(webhook *db.Webhook, delivery *db.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "URL-Crawler-Webhook/1.0")
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt: 30s doubling per
+// attempt, capped at one hour, plus up to 10% jitter
+func backoff(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay/10)+1))
+}
@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sykell/url-crawler/internal/db"
+	"github.com/sykell/url-crawler/internal/events"
+	"github.com/sykell/url-crawler/internal/service"
+)
+
+// newTestRepository opens a migrated in-memory SQLite database with a user
+// and returns its webhook repository and the user's ID
+func newTestRepository(t *testing.T) (*service.GormWebhookRepository, uint) {
+	t.Helper()
+	dbConn, err := db.Open(&db.Config{
+		Driver:      db.DriverSQLite,
+		DSN:         ":memory:",
+		MaxOpen:     1,
+		MaxIdle:     1,
+		Timeout:     time.Minute,
+		AutoMigrate: true,
+	})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := dbConn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	users := service.NewGormUserRepository(dbConn)
+	if err := users.Create(context.Background(), "alice", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := users.GetByUsername(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service.NewGormWebhookRepository(dbConn), user.ID
+}
+
+// statusServer answers webhook requests with the status in the status query parameter
+func statusServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, _ := strconv.Atoi(r.URL.Query().Get("status"))
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"crawl.succeeded"}`)
+	want := "sha256=48121bb8b4a5d4c2440118e51cce452fe6c01a55c833c97851223c1505b0120e"
+	if got := Sign("secret", 1700000000, body); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+	}{
+		{"secret", "other", 1700000000, string(body)},
+		{"timestamp", "secret", 1700000001, string(body)},
+		{"body", "secret", 1700000000, `{"event":"crawl.failed"}`},
+	}
+	for _, tt := range tests {
+		if Sign(tt.secret, tt.timestamp, []byte(tt.body)) == want {
+			t.Errorf("changing the %s keeps the signature", tt.name)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		min      time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := backoff(tt.attempts); got < tt.min || got > tt.min+tt.min/10 {
+				t.Errorf("backoff(%d) = %s, want %s plus up to 10%%", tt.attempts, got, tt.min)
+				break
+			}
+		}
+	}
+}
+
+func TestAttempt(t *testing.T) {
+	webhooks, userID := newTestRepository(t)
+	server := statusServer(t)
+	ctx := context.Background()
+	d := NewDispatcher(webhooks, &Config{Timeout: time.Second, MaxAttempts: 2, DisableAfter: 2, PollInterval: time.Hour, Concurrency: 1})
+
+	tests := []struct {
+		name         string
+		status       int
+		attempts     int // Made before this one
+		failures     int // Consecutive failures before this attempt
+		wantStatus   db.WebhookDeliveryStatus
+		wantRetry    bool
+		wantFailures int
+		wantDisabled bool
+	}{
+		{"success", http.StatusOK, 0, 1, db.DeliverySucceeded, false, 0, false},
+		{"retry", http.StatusInternalServerError, 0, 0, db.DeliveryPending, true, 1, false},
+		{"last attempt", http.StatusInternalServerError, 1, 0, db.DeliveryFailed, false, 1, false},
+		{"disable", http.StatusBadGateway, 0, 1, db.DeliveryPending, true, 2, true},
+	}
+	for _, tt := range tests {
+		url := server.URL + "/?status=" + strconv.Itoa(tt.status)
+		webhook, err := webhooks.Create(ctx, userID, url, "secret", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < tt.failures; i++ {
+			webhooks.RecordFailure(ctx, webhook.ID, false)
+		}
+		delivery, err := webhooks.CreateDelivery(ctx, webhook.ID, events.TypeCrawlSucceeded, `{}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		delivery.Attempts = tt.attempts
+
+		d.attempt(delivery)
+
+		delivery, _ = webhooks.GetDelivery(ctx, webhook.ID, delivery.ID)
+		if delivery.Status != tt.wantStatus || delivery.Attempts != tt.attempts+1 || delivery.ResponseCode != tt.status {
+			t.Errorf("%s: delivery %s after %d attempts with code %d", tt.name, delivery.Status, delivery.Attempts, delivery.ResponseCode)
+		}
+		if retry := delivery.NextAttemptAt != nil && delivery.NextAttemptAt.After(time.Now()); retry != tt.wantRetry {
+			t.Errorf("%s: next attempt at %v, want a retry %v", tt.name, delivery.NextAttemptAt, tt.wantRetry)
+		}
+		webhook, _ = webhooks.GetByID(ctx, webhook.ID)
+		if webhook.FailureCount != tt.wantFailures || webhook.Active == tt.wantDisabled {
+			t.Errorf("%s: webhook has %d failures, active %v", tt.name, webhook.FailureCount, webhook.Active)
+		}
+	}
+}
+
+func TestStopReleasesInterruptedDelivery(t *testing.T) {
+	webhooks, userID := newTestRepository(t)
+	ctx := context.Background()
+
+	received := make(chan struct{})
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-unblock
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	webhook, _ := webhooks.Create(ctx, userID, server.URL, "secret", nil)
+	delivery, _ := webhooks.CreateDelivery(ctx, webhook.ID, events.TypeCrawlSucceeded, `{}`)
+
+	d := NewDispatcher(webhooks, &Config{Timeout: time.Minute, MaxAttempts: 6, DisableAfter: 1, PollInterval: time.Hour, Concurrency: 1})
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery wasn't sent")
+	}
+	d.Stop()
+
+	delivery, _ = webhooks.GetDelivery(ctx, webhook.ID, delivery.ID)
+	if delivery.Status != db.DeliveryPending || delivery.Attempts != 0 || delivery.Error != "" {
+		t.Errorf("delivery after stop = %s, %d attempts, error %q; want it untouched", delivery.Status, delivery.Attempts, delivery.Error)
+	}
+	if delivery.NextAttemptAt == nil || delivery.NextAttemptAt.After(time.Now()) {
+		t.Errorf("delivery is due at %v, want it released", delivery.NextAttemptAt)
+	}
+	if webhook, _ = webhooks.GetByID(ctx, webhook.ID); webhook.FailureCount != 0 || !webhook.Active {
+		t.Errorf("webhook has %d failures, active %v; want the interruption not counted", webhook.FailureCount, webhook.Active)
+	}
+}